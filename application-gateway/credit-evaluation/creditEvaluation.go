@@ -10,6 +10,7 @@ const MinAge = 18
 const MaxCreditScore = 850
 const MinCreditScore = 300
 const MinDTI = 0.01
+const MaxDTI = 1.0
 
 const W0 = 0.5
 const W1 = 0.5
@@ -29,10 +30,8 @@ func calcScore(creditScore float64, dti float64) float64 {
 	// Normalize credit score
 	normalizedCreditScore := (creditScore - MinCreditScore) / (MaxCreditScore - MinCreditScore)
 
-	// Normalize DTI (ensure DTI is not too small)
-	if dti < MinDTI {
-		dti = MinDTI
-	}
+	// Normalize DTI (ensure DTI is not too small, nor too large, like the encrypted evaluation)
+	dti = math.Min(math.Max(dti, MinDTI), MaxDTI)
 
 	score := (W0 * normalizedCreditScore) + (W1 * (1 / dti))
 	return score
@@ -0,0 +1,246 @@
+package credit_evaluation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Normalization names how a feature is rescaled before it is weighted
+type Normalization string
+
+const (
+	NormalizationNone   Normalization = ""
+	NormalizationMinMax Normalization = "minmax"
+	NormalizationZScore Normalization = "zscore"
+)
+
+var (
+	ErrInvalidModel      = errors.New("invalid scoring model")
+	ErrFeatureMismatch   = errors.New("number of features does not match the model")
+	ErrFeatureOutOfRange = errors.New("feature outside of its declared range")
+)
+
+// FeatureRange declares a feature of a model and the range of its raw values
+// LinearModel, TreeEnsemble and Scorecard are evaluated in plaintext here and on encrypted features by the encryption
+// package, which sizes its approximations from these ranges: the logits a sigmoid must cover, or the inputs of the
+// comparisons, which are only decided away from their thresholds.
+type FeatureRange struct {
+	Name string  `json:"Name"`
+	Min  float64 `json:"Min"`
+	Max  float64 `json:"Max"`
+}
+
+// Range returns the range itself, so that a FeatureRange declares the features of a TreeEnsemble on its own
+func (f FeatureRange) Range() FeatureRange {
+	return f
+}
+
+// validate checks that the range is not empty
+func (f FeatureRange) validate() error {
+	if !(f.Max > f.Min) {
+		return fmt.Errorf("%w: feature %s has an empty range [%g, %g]", ErrInvalidModel, f.Name, f.Min, f.Max)
+	}
+	return nil
+}
+
+// rangedFeature is a declared feature of a model
+type rangedFeature interface {
+	Range() FeatureRange
+}
+
+// checkFeatures checks that raw features match the declared features of a model and lie in their ranges
+func checkFeatures[F rangedFeature](features []F, x []float64) error {
+	if len(x) != len(features) {
+		return fmt.Errorf("%w: got %d, expected %d", ErrFeatureMismatch, len(x), len(features))
+	}
+	for i, feature := range features {
+		if f := feature.Range(); x[i] < f.Min || x[i] > f.Max {
+			return fmt.Errorf("%w: %s = %g not in [%g, %g]", ErrFeatureOutOfRange, f.Name, x[i], f.Min, f.Max)
+		}
+	}
+	return nil
+}
+
+// Feature is one input of a LinearModel
+// Min and Max declare the range of the raw values, which bounds the logit and lets the encrypted evaluation size its
+// approximations. They also serve as the bounds of the min/max normalization.
+type Feature struct {
+	Name          string        `json:"Name"`
+	Weight        float64       `json:"Weight"`
+	Normalization Normalization `json:"Normalization,omitempty"`
+	Min           float64       `json:"Min"`
+	Max           float64       `json:"Max"`
+	Mean          float64       `json:"Mean,omitempty"`
+	StdDev        float64       `json:"StdDev,omitempty"`
+}
+
+// LinearModel scores an applicant as bias + sum of weight * normalized feature, the logit, and turns the logit
+// into a probability with the logistic function
+type LinearModel struct {
+	Name     string    `json:"Name"`
+	Features []Feature `json:"Features"`
+	Bias     float64   `json:"Bias"`
+}
+
+// Range returns the declared range of the feature
+func (f Feature) Range() FeatureRange {
+	return FeatureRange{Name: f.Name, Min: f.Min, Max: f.Max}
+}
+
+// Affine returns the scale and offset of the normalization, so that normalized = scale * x + offset
+func (f Feature) Affine() (scale float64, offset float64) {
+	switch f.Normalization {
+	case NormalizationMinMax:
+		return 1 / (f.Max - f.Min), -f.Min / (f.Max - f.Min)
+	case NormalizationZScore:
+		return 1 / f.StdDev, -f.Mean / f.StdDev
+	default:
+		return 1, 0
+	}
+}
+
+// Normalize rescales a raw value of the feature
+func (f Feature) Normalize(x float64) float64 {
+	scale, offset := f.Affine()
+	return scale*x + offset
+}
+
+// Validate checks the declared ranges and the normalizations of the features
+func (m *LinearModel) Validate() error {
+	if len(m.Features) == 0 {
+		return fmt.Errorf("%w: no features", ErrInvalidModel)
+	}
+	for _, f := range m.Features {
+		if err := f.Range().validate(); err != nil {
+			return err
+		}
+		switch f.Normalization {
+		case NormalizationNone, NormalizationMinMax:
+		case NormalizationZScore:
+			if !(f.StdDev > 0) {
+				return fmt.Errorf("%w: feature %s has a standard deviation of %g", ErrInvalidModel, f.Name, f.StdDev)
+			}
+		default:
+			return fmt.Errorf("%w: feature %s has an unknown normalization %q", ErrInvalidModel, f.Name, f.Normalization)
+		}
+	}
+	return nil
+}
+
+// Coefficients folds the normalizations into the weights and the bias, so that the logit is
+// bias + sum of weights[i] * x[i] on the raw features
+func (m *LinearModel) Coefficients() (weights []float64, bias float64) {
+	weights = make([]float64, len(m.Features))
+	bias = m.Bias
+	for i, f := range m.Features {
+		scale, offset := f.Affine()
+		weights[i] = f.Weight * scale
+		bias += f.Weight * offset
+	}
+	return weights, bias
+}
+
+// LogitRange returns the smallest and largest logit over the declared ranges of the features
+func (m *LinearModel) LogitRange() (lo float64, hi float64) {
+	weights, bias := m.Coefficients()
+	lo, hi = bias, bias
+	for i, f := range m.Features {
+		a, b := weights[i]*f.Min, weights[i]*f.Max
+		lo += math.Min(a, b)
+		hi += math.Max(a, b)
+	}
+	return lo, hi
+}
+
+// CheckFeatures checks that raw features match the model and lie in their declared ranges
+func (m *LinearModel) CheckFeatures(x []float64) error {
+	return checkFeatures(m.Features, x)
+}
+
+// Logit returns bias + sum of weight * normalized feature
+func (m *LinearModel) Logit(x []float64) (float64, error) {
+	if len(x) != len(m.Features) {
+		return 0, fmt.Errorf("%w: got %d, expected %d", ErrFeatureMismatch, len(x), len(m.Features))
+	}
+	logit := m.Bias
+	for i, f := range m.Features {
+		logit += f.Weight * f.Normalize(x[i])
+	}
+	return logit, nil
+}
+
+// Probability returns the logistic function of the logit
+func (m *LinearModel) Probability(x []float64) (float64, error) {
+	logit, err := m.Logit(x)
+	if err != nil {
+		return 0, err
+	}
+	return Sigmoid(logit), nil
+}
+
+// ReadModelFile reads a model written by WriteModelFile and validates it
+func ReadModelFile(path string) (*LinearModel, error) {
+	model := &LinearModel{}
+	if err := readJSONFile(path, model); err != nil {
+		return nil, err
+	}
+	return model, model.Validate()
+}
+
+// WriteModelFile writes a model as indented JSON
+func WriteModelFile(path string, model *LinearModel) error {
+	return writeJSONFile(path, model)
+}
+
+// ReadEnsembleFile reads a tree ensemble written by WriteEnsembleFile, or exported in the same format by the
+// training pipeline of a lender, and validates it
+func ReadEnsembleFile(path string) (*TreeEnsemble, error) {
+	ensemble := &TreeEnsemble{}
+	if err := readJSONFile(path, ensemble); err != nil {
+		return nil, err
+	}
+	return ensemble, ensemble.Validate()
+}
+
+// WriteEnsembleFile writes a tree ensemble as indented JSON
+func WriteEnsembleFile(path string, ensemble *TreeEnsemble) error {
+	return writeJSONFile(path, ensemble)
+}
+
+// ReadScorecardFile reads a scorecard written by WriteScorecardFile and validates it
+func ReadScorecardFile(path string) (*Scorecard, error) {
+	scorecard := &Scorecard{}
+	if err := readJSONFile(path, scorecard); err != nil {
+		return nil, err
+	}
+	return scorecard, scorecard.Validate()
+}
+
+// WriteScorecardFile writes a scorecard as indented JSON
+func WriteScorecardFile(path string, scorecard *Scorecard) error {
+	return writeJSONFile(path, scorecard)
+}
+
+// readJSONFile decodes a model file
+func readJSONFile(path string, model any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, model); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidModel, err)
+	}
+	return nil
+}
+
+// writeJSONFile encodes a model file as indented JSON
+func writeJSONFile(path string, model any) error {
+	data, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,121 @@
+package credit_evaluation
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// testModel uses every kind of normalization
+var testModel = LinearModel{
+	Name: "test",
+	Features: []Feature{
+		{Name: "CreditScore", Weight: 2.5, Normalization: NormalizationMinMax, Min: MinCreditScore, Max: MaxCreditScore},
+		{Name: "DTI", Weight: -3, Min: 0, Max: 1},
+		{Name: "Salary", Weight: 0.8, Normalization: NormalizationZScore, Min: 0, Max: 200, Mean: 60, StdDev: 30},
+	},
+	Bias: -0.4,
+}
+
+// Test Feature.Normalize function
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		feature Feature
+		x       float64
+		want    float64
+	}{
+		{"None", testModel.Features[1], 0.4, 0.4},
+		{"MinMax Lower Bound", testModel.Features[0], MinCreditScore, 0},
+		{"MinMax Upper Bound", testModel.Features[0], MaxCreditScore, 1},
+		{"ZScore Mean", testModel.Features[2], 60, 0},
+		{"ZScore One Deviation", testModel.Features[2], 90, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.feature.Normalize(tt.x)
+			if math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Normalize(%f) = %f, want %f", tt.x, got, tt.want)
+			}
+		})
+	}
+}
+
+// Test LinearModel.Logit and LinearModel.Probability functions
+func TestLinearModel(t *testing.T) {
+	tests := []struct {
+		name string
+		x    []float64
+		want float64
+	}{
+		{"Good Applicant", []float64{800, 0.2, 150}, -0.4 + 2.5*(800-MinCreditScore)/(MaxCreditScore-MinCreditScore) - 3*0.2 + 0.8*(150-60)/30.0},
+		{"Poor Applicant", []float64{420, 0.9, 12}, -0.4 + 2.5*(420-MinCreditScore)/(MaxCreditScore-MinCreditScore) - 3*0.9 + 0.8*(12-60)/30.0},
+	}
+
+	weights, bias := testModel.Coefficients()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testModel.Logit(tt.x)
+			if err != nil || math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Logit(%v) = %f, %v, want %f", tt.x, got, err, tt.want)
+			}
+
+			// The folded coefficients give the same logit on the raw features
+			folded := bias
+			for i := range weights {
+				folded += weights[i] * tt.x[i]
+			}
+			if math.Abs(folded-tt.want) > 1e-9 {
+				t.Errorf("Coefficients give %f, want %f", folded, tt.want)
+			}
+
+			probability, err := testModel.Probability(tt.x)
+			if err != nil || probability != Sigmoid(got) {
+				t.Errorf("Probability(%v) = %f, %v, want %f", tt.x, probability, err, Sigmoid(got))
+			}
+		})
+	}
+
+	if _, err := testModel.Logit([]float64{800}); !errors.Is(err, ErrFeatureMismatch) {
+		t.Errorf("Logit with a missing feature returned %v, expected %v", err, ErrFeatureMismatch)
+	}
+}
+
+// Test LinearModel.LogitRange function
+func TestLogitRange(t *testing.T) {
+	lo, hi := testModel.LogitRange()
+	wantLo := -0.4 + 0 - 3 + 0.8*(0-60)/30.0
+	wantHi := -0.4 + 2.5 + 0 + 0.8*(200-60)/30.0
+	if math.Abs(lo-wantLo) > 1e-9 || math.Abs(hi-wantHi) > 1e-9 {
+		t.Errorf("LogitRange() = [%f, %f], want [%f, %f]", lo, hi, wantLo, wantHi)
+	}
+}
+
+// Test LinearModel.Validate and LinearModel.CheckFeatures functions
+func TestValidateModel(t *testing.T) {
+	if err := testModel.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name    string
+		feature Feature
+	}{
+		{"Empty Range", Feature{Name: "x", Min: 1, Max: 1}},
+		{"Zero Deviation", Feature{Name: "x", Normalization: NormalizationZScore, Min: 0, Max: 1}},
+		{"Unknown Normalization", Feature{Name: "x", Normalization: "log", Min: 0, Max: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := LinearModel{Features: []Feature{tt.feature}}
+			if err := model.Validate(); !errors.Is(err, ErrInvalidModel) {
+				t.Errorf("Validate() = %v, want %v", err, ErrInvalidModel)
+			}
+		})
+	}
+
+	if err := testModel.CheckFeatures([]float64{900, 0.2, 150}); !errors.Is(err, ErrFeatureOutOfRange) {
+		t.Errorf("CheckFeatures of a credit score of 900 returned %v, expected %v", err, ErrFeatureOutOfRange)
+	}
+}
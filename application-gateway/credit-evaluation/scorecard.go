@@ -0,0 +1,126 @@
+package credit_evaluation
+
+import (
+	"fmt"
+	"math"
+)
+
+// ScorecardBin awards points to the values of a feature up to Upper, and above the Upper of the previous bin
+type ScorecardBin struct {
+	Upper  float64 `json:"Upper"`
+	Points float64 `json:"Points"`
+}
+
+// ScorecardFeature splits the declared range of a feature into bins, in increasing order
+// The first bin starts at Min and the last one ends at Max, so that every value in the range falls in exactly one bin.
+type ScorecardFeature struct {
+	Name string         `json:"Name"`
+	Min  float64        `json:"Min"`
+	Max  float64        `json:"Max"`
+	Bins []ScorecardBin `json:"Bins"`
+}
+
+// Scorecard scores an applicant as the base points plus the points of the bin each feature falls in, like the
+// points-based scorecards of credit bureaus
+type Scorecard struct {
+	Name       string             `json:"Name"`
+	Features   []ScorecardFeature `json:"Features"`
+	BasePoints float64            `json:"BasePoints"`
+}
+
+// Range returns the declared range of the feature
+func (f ScorecardFeature) Range() FeatureRange {
+	return FeatureRange{Name: f.Name, Min: f.Min, Max: f.Max}
+}
+
+// Points returns the points of the bin a raw value falls in
+func (f ScorecardFeature) Points(x float64) float64 {
+	for _, bin := range f.Bins[:len(f.Bins)-1] {
+		if x <= bin.Upper {
+			return bin.Points
+		}
+	}
+	return f.Bins[len(f.Bins)-1].Points
+}
+
+// Steps decomposes the bins into the points of the first bin plus a step at every inner edge, so that
+// Points(x) = base + sum of deltas[i] where x > edges[i]
+func (f ScorecardFeature) Steps() (base float64, edges []float64, deltas []float64) {
+	for i := 1; i < len(f.Bins); i++ {
+		edges = append(edges, f.Bins[i-1].Upper)
+		deltas = append(deltas, f.Bins[i].Points-f.Bins[i-1].Points)
+	}
+	return f.Bins[0].Points, edges, deltas
+}
+
+// Validate checks the declared ranges and the bins of the features
+func (s *Scorecard) Validate() error {
+	if len(s.Features) == 0 {
+		return fmt.Errorf("%w: no features", ErrInvalidModel)
+	}
+	for _, f := range s.Features {
+		if err := f.Range().validate(); err != nil {
+			return err
+		}
+		if len(f.Bins) == 0 {
+			return fmt.Errorf("%w: feature %s has no bins", ErrInvalidModel, f.Name)
+		}
+
+		lower := f.Min
+		for i, bin := range f.Bins {
+			if !(bin.Upper > lower) {
+				return fmt.Errorf("%w: bin %d of %s ends at %g, not above %g", ErrInvalidModel, i, f.Name, bin.Upper, lower)
+			}
+			lower = bin.Upper
+		}
+		if lower != f.Max {
+			return fmt.Errorf("%w: the last bin of %s ends at %g instead of %g", ErrInvalidModel, f.Name, lower, f.Max)
+		}
+	}
+	return nil
+}
+
+// ScoreRange returns the smallest and largest score of the scorecard
+func (s *Scorecard) ScoreRange() (lo float64, hi float64) {
+	lo, hi = s.BasePoints, s.BasePoints
+	for _, f := range s.Features {
+		featureLo, featureHi := math.Inf(1), math.Inf(-1)
+		for _, bin := range f.Bins {
+			featureLo = math.Min(featureLo, bin.Points)
+			featureHi = math.Max(featureHi, bin.Points)
+		}
+		lo += featureLo
+		hi += featureHi
+	}
+	return lo, hi
+}
+
+// CheckFeatures checks that raw features match the scorecard and lie in their declared ranges
+func (s *Scorecard) CheckFeatures(x []float64) error {
+	return checkFeatures(s.Features, x)
+}
+
+// FeaturePoints returns the points awarded to each feature, from which the reasons of a low score can be given
+func (s *Scorecard) FeaturePoints(x []float64) ([]float64, error) {
+	if len(x) != len(s.Features) {
+		return nil, fmt.Errorf("%w: got %d, expected %d", ErrFeatureMismatch, len(x), len(s.Features))
+	}
+	points := make([]float64, len(x))
+	for i, f := range s.Features {
+		points[i] = f.Points(x[i])
+	}
+	return points, nil
+}
+
+// Score returns the base points plus the points of every feature
+func (s *Scorecard) Score(x []float64) (float64, error) {
+	points, err := s.FeaturePoints(x)
+	if err != nil {
+		return 0, err
+	}
+	score := s.BasePoints
+	for _, p := range points {
+		score += p
+	}
+	return score, nil
+}
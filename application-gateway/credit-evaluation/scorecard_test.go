@@ -0,0 +1,112 @@
+package credit_evaluation
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// testScorecard awards points for the credit score and the DTI
+var testScorecard = Scorecard{
+	Name: "test",
+	Features: []ScorecardFeature{
+		{Name: "CreditScore", Min: MinCreditScore, Max: MaxCreditScore, Bins: []ScorecardBin{
+			{Upper: 580, Points: -20}, {Upper: 670, Points: 0}, {Upper: 740, Points: 15}, {Upper: MaxCreditScore, Points: 30},
+		}},
+		{Name: "DTI", Min: 0, Max: 1, Bins: []ScorecardBin{
+			{Upper: 0.2, Points: 25}, {Upper: 0.36, Points: 10}, {Upper: 1, Points: -30},
+		}},
+	},
+	BasePoints: 600,
+}
+
+// Test Scorecard.Score function
+func TestScorecard(t *testing.T) {
+	tests := []struct {
+		name string
+		x    []float64
+		want float64
+	}{
+		{"Good Applicant", []float64{800, 0.1}, 600 + 30 + 25},
+		{"Poor Applicant", []float64{420, 0.9}, 600 - 20 - 30},
+		{"On The Edges", []float64{580, 0.36}, 600 - 20 + 10},
+		{"Range Bounds", []float64{MinCreditScore, 1}, 600 - 20 - 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testScorecard.Score(tt.x)
+			if err != nil || got != tt.want {
+				t.Errorf("Score(%v) = %f, %v, want %f", tt.x, got, err, tt.want)
+			}
+		})
+	}
+
+	if lo, hi := testScorecard.ScoreRange(); lo != 550 || hi != 655 {
+		t.Errorf("ScoreRange() = [%f, %f], want [550, 655]", lo, hi)
+	}
+	if _, err := testScorecard.Score([]float64{800}); !errors.Is(err, ErrFeatureMismatch) {
+		t.Errorf("Score() with a missing feature: error = %v, want %v", err, ErrFeatureMismatch)
+	}
+}
+
+// Test ScorecardFeature.Steps function
+func TestScorecardSteps(t *testing.T) {
+	f := testScorecard.Features[0]
+	base, edges, deltas := f.Steps()
+	for _, x := range []float64{300, 500, 600, 700, 800} {
+		got := base
+		for i, edge := range edges {
+			if x > edge {
+				got += deltas[i]
+			}
+		}
+		if want := f.Points(x); got != want {
+			t.Errorf("steps at %f sum to %f, want %f", x, got, want)
+		}
+	}
+}
+
+// Test Scorecard.Validate function
+func TestValidateScorecard(t *testing.T) {
+	if err := testScorecard.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		bins []ScorecardBin
+	}{
+		{"No Bins", nil},
+		{"Unsorted Bins", []ScorecardBin{{Upper: 0.5, Points: 1}, {Upper: 0.3, Points: 2}, {Upper: 1, Points: 3}}},
+		{"Short Of The Range", []ScorecardBin{{Upper: 0.5, Points: 1}, {Upper: 0.9, Points: 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorecard := testScorecard
+			scorecard.Features = []ScorecardFeature{{Name: "DTI", Min: 0, Max: 1, Bins: tt.bins}}
+			if err := scorecard.Validate(); !errors.Is(err, ErrInvalidModel) {
+				t.Errorf("Validate() = %v, want %v", err, ErrInvalidModel)
+			}
+		})
+	}
+}
+
+// Test WriteScorecardFile and ReadScorecardFile functions
+func TestScorecardFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scorecard.json")
+	if err := WriteScorecardFile(path, &testScorecard); err != nil {
+		t.Fatal(err)
+	}
+	scorecard, err := ReadScorecardFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range [][]float64{{800, 0.1}, {420, 0.9}, {600, 0.3}} {
+		want, _ := testScorecard.Score(x)
+		if got, _ := scorecard.Score(x); got != want {
+			t.Errorf("Score(%v) = %f after a round trip, want %f", x, got, want)
+		}
+	}
+}
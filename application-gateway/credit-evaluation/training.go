@@ -0,0 +1,319 @@
+package credit_evaluation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// DefaultMaxLogit bounds the logits of a trained model, keeping the interval of the encrypted sigmoid small enough
+// for a low degree approximation
+const DefaultMaxLogit = 16
+
+// MaxFeatureMagnitude bounds the raw values of a trained feature
+// The encrypted evaluation encodes the folded weights with an absolute precision of about 2^-34, so that a raw value
+// of 1e6 already shifts the logit by about 1e-4; larger features must be expressed in larger units.
+const MaxFeatureMagnitude = 1e6
+
+var (
+	ErrTrainingData     = errors.New("invalid training data")
+	ErrLogitRange       = errors.New("logits of the trained model exceed the allowed range")
+	ErrFeatureMagnitude = errors.New("feature too large for the encrypted evaluation")
+)
+
+// TrainingData holds labeled samples: one row of raw features per applicant and its outcome, 1 for a default
+type TrainingData struct {
+	Names  []string
+	X      [][]float64
+	Labels []float64
+}
+
+// TrainOptions configures the logistic regression fitted by Train
+type TrainOptions struct {
+	// Normalization is applied to every feature before fitting, NormalizationZScore when empty
+	Normalization Normalization
+
+	// L2 is the strength of the ridge penalty on the weights, the bias is not penalized
+	L2 float64
+
+	// LearningRate and Epochs drive the full-batch gradient descent, 0.5 and 2000 when zero
+	LearningRate float64
+	Epochs       int
+
+	// ValidationFraction is the share of the samples held out to compute the validation metrics, 0.2 when zero
+	// NoValidation keeps every sample for training, the metrics being then computed on the training samples.
+	ValidationFraction float64
+
+	// Seed makes the split between training and validation samples reproducible
+	Seed int64
+
+	// MaxLogit bounds the logits of the model over the declared feature ranges, DefaultMaxLogit when zero
+	MaxLogit float64
+}
+
+// NoValidation is the ValidationFraction that holds out no sample
+const NoValidation = -1
+
+// Metrics measures how well a model predicts the outcomes of labeled samples, with a threshold of 0.5
+// TrainingSamples is only set by Train, to the number of samples the model was fitted on.
+type Metrics struct {
+	TrainingSamples int
+	Samples         int
+	Accuracy        float64
+	Precision       float64
+	Recall          float64
+	LogLoss         float64
+	AUC             float64
+}
+
+// ReadTrainingData reads labeled samples from a CSV with a header row
+// The label column holds the outcome, 0 or 1; every other column is a numeric feature.
+func ReadTrainingData(r io.Reader, label string) (*TrainingData, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%w: no samples", ErrTrainingData)
+	}
+
+	labelColumn := -1
+	data := &TrainingData{}
+	for i, name := range records[0] {
+		if name == label {
+			labelColumn = i
+		} else {
+			data.Names = append(data.Names, name)
+		}
+	}
+	if labelColumn < 0 {
+		return nil, fmt.Errorf("%w: no %s column", ErrTrainingData, label)
+	}
+
+	for line, record := range records[1:] {
+		row := make([]float64, 0, len(data.Names))
+		for i, field := range record {
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrTrainingData, line+2, err)
+			}
+			if i != labelColumn {
+				row = append(row, value)
+			} else if value != 0 && value != 1 {
+				return nil, fmt.Errorf("%w: line %d: label %g is neither 0 nor 1", ErrTrainingData, line+2, value)
+			} else {
+				data.Labels = append(data.Labels, value)
+			}
+		}
+		data.X = append(data.X, row)
+	}
+	return data, nil
+}
+
+// Train fits a regularized logistic regression on the training share of the data and returns the model with its
+// metrics on the held out validation share.
+// The declared range of every feature is the range of its values, which bounds the inputs the encrypted evaluation
+// accepts; the features are normalized so that the fit is well conditioned whatever their units.
+func Train(data *TrainingData, opts TrainOptions) (*LinearModel, Metrics, error) {
+	opts = opts.withDefaults()
+	if len(data.X) == 0 || len(data.X) != len(data.Labels) {
+		return nil, Metrics{}, fmt.Errorf("%w: %d samples for %d labels", ErrTrainingData, len(data.X), len(data.Labels))
+	}
+
+	// Hold out the validation samples
+	order := rand.New(rand.NewSource(opts.Seed)).Perm(len(data.X))
+	validation := int(math.Round(math.Max(opts.ValidationFraction, 0) * float64(len(data.X))))
+	if validation >= len(data.X) {
+		return nil, Metrics{}, fmt.Errorf("%w: no samples left for training", ErrTrainingData)
+	}
+	trainRows, validationRows := order[validation:], order[:validation]
+
+	model, err := newNormalizedModel(data, trainRows, opts.Normalization)
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+
+	// Fit the weights of the normalized features by gradient descent on the penalized log loss
+	normalized := make([][]float64, len(trainRows))
+	for k, row := range trainRows {
+		normalized[k] = make([]float64, len(model.Features))
+		for i, f := range model.Features {
+			normalized[k][i] = f.Normalize(data.X[row][i])
+		}
+	}
+	n := float64(len(trainRows))
+	gradient := make([]float64, len(model.Features))
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		for i := range gradient {
+			gradient[i] = opts.L2 * model.Features[i].Weight
+		}
+		biasGradient := 0.0
+		for k, row := range trainRows {
+			logit := model.Bias
+			for i, f := range model.Features {
+				logit += f.Weight * normalized[k][i]
+			}
+			residual := (Sigmoid(logit) - data.Labels[row]) / n
+			for i := range gradient {
+				gradient[i] += residual * normalized[k][i]
+			}
+			biasGradient += residual
+		}
+		for i := range model.Features {
+			model.Features[i].Weight -= opts.LearningRate * gradient[i]
+		}
+		model.Bias -= opts.LearningRate * biasGradient
+	}
+
+	if lo, hi := model.LogitRange(); lo < -opts.MaxLogit || hi > opts.MaxLogit {
+		return nil, Metrics{}, fmt.Errorf("%w: [%.2f, %.2f] outside of [-%g, %g], increase the L2 penalty", ErrLogitRange, lo, hi, opts.MaxLogit, opts.MaxLogit)
+	}
+
+	// Without a validation share, report the metrics on the training samples
+	if len(validationRows) == 0 {
+		validationRows = trainRows
+	}
+	metrics, err := model.Evaluate(rows(data.X, validationRows), rows(data.Labels, validationRows))
+	metrics.TrainingSamples = len(trainRows)
+	return model, metrics, err
+}
+
+// withDefaults fills the unset options
+func (o TrainOptions) withDefaults() TrainOptions {
+	if o.Normalization == NormalizationNone {
+		o.Normalization = NormalizationZScore
+	}
+	if o.LearningRate <= 0 {
+		o.LearningRate = 0.5
+	}
+	if o.Epochs <= 0 {
+		o.Epochs = 2000
+	}
+	if o.ValidationFraction == 0 {
+		o.ValidationFraction = 0.2
+	}
+	if o.MaxLogit <= 0 {
+		o.MaxLogit = DefaultMaxLogit
+	}
+	return o
+}
+
+// newNormalizedModel declares the range of every feature and fits its normalization on the training rows
+func newNormalizedModel(data *TrainingData, trainRows []int, normalization Normalization) (*LinearModel, error) {
+	model := &LinearModel{Features: make([]Feature, len(data.Names))}
+	for i, name := range data.Names {
+		f := Feature{Name: name, Normalization: normalization, Min: math.Inf(1), Max: math.Inf(-1)}
+		for _, x := range data.X {
+			f.Min = math.Min(f.Min, x[i])
+			f.Max = math.Max(f.Max, x[i])
+		}
+		if math.Max(-f.Min, f.Max) > MaxFeatureMagnitude {
+			return nil, fmt.Errorf("%w: %s reaches %g, rescale it below %g", ErrFeatureMagnitude, name, math.Max(-f.Min, f.Max), MaxFeatureMagnitude)
+		}
+
+		if normalization == NormalizationZScore {
+			for _, row := range trainRows {
+				f.Mean += data.X[row][i] / float64(len(trainRows))
+			}
+			for _, row := range trainRows {
+				f.StdDev += (data.X[row][i] - f.Mean) * (data.X[row][i] - f.Mean) / float64(len(trainRows))
+			}
+			f.StdDev = math.Sqrt(f.StdDev)
+		}
+		model.Features[i] = f
+	}
+	return model, model.Validate()
+}
+
+// Evaluate computes the metrics of the model on labeled samples
+func (m *LinearModel) Evaluate(x [][]float64, labels []float64) (Metrics, error) {
+	metrics := Metrics{Samples: len(x)}
+	if len(x) == 0 || len(x) != len(labels) {
+		return metrics, fmt.Errorf("%w: %d samples for %d labels", ErrTrainingData, len(x), len(labels))
+	}
+
+	probabilities := make([]float64, len(x))
+	var truePositives, falsePositives, falseNegatives, correct float64
+	for k := range x {
+		p, err := m.Probability(x[k])
+		if err != nil {
+			return metrics, err
+		}
+		probabilities[k] = p
+
+		predicted := p >= 0.5
+		actual := labels[k] == 1
+		switch {
+		case predicted && actual:
+			truePositives++
+		case predicted && !actual:
+			falsePositives++
+		case !predicted && actual:
+			falseNegatives++
+		}
+		if predicted == actual {
+			correct++
+		}
+
+		// Clip the probabilities so that a confident mistake does not make the loss infinite
+		p = math.Min(math.Max(p, 1e-15), 1-1e-15)
+		metrics.LogLoss -= (labels[k]*math.Log(p) + (1-labels[k])*math.Log(1-p)) / float64(len(x))
+	}
+
+	metrics.Accuracy = correct / float64(len(x))
+	if truePositives+falsePositives > 0 {
+		metrics.Precision = truePositives / (truePositives + falsePositives)
+	}
+	if truePositives+falseNegatives > 0 {
+		metrics.Recall = truePositives / (truePositives + falseNegatives)
+	}
+	metrics.AUC = auc(probabilities, labels)
+	return metrics, nil
+}
+
+// auc returns the area under the ROC curve: the probability that a random default is ranked above a random
+// non-default, ties counting for one half
+func auc(probabilities []float64, labels []float64) float64 {
+	order := make([]int, len(probabilities))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return probabilities[order[a]] < probabilities[order[b]] })
+
+	// Sum the ranks of the defaults, averaging the ranks of tied probabilities
+	var rankSum, positives float64
+	for start := 0; start < len(order); {
+		end := start
+		for end < len(order) && probabilities[order[end]] == probabilities[order[start]] {
+			end++
+		}
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			if labels[i] == 1 {
+				rankSum += rank
+				positives++
+			}
+		}
+		start = end
+	}
+
+	negatives := float64(len(labels)) - positives
+	if positives == 0 || negatives == 0 {
+		return math.NaN()
+	}
+	return (rankSum - positives*(positives+1)/2) / (positives * negatives)
+}
+
+// rows returns the given rows of a slice
+func rows[T any](values []T, indices []int) []T {
+	selected := make([]T, len(indices))
+	for k, i := range indices {
+		selected[k] = values[i]
+	}
+	return selected
+}
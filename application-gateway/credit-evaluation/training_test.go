@@ -0,0 +1,133 @@
+package credit_evaluation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// labeledCSV draws applicants whose defaults follow a logistic model of their credit score and DTI
+func labeledCSV(n int) string {
+	random := rand.New(rand.NewSource(1))
+	var csv strings.Builder
+	csv.WriteString("Credit Score,DTI,Default\n")
+	for i := 0; i < n; i++ {
+		creditScore := float64(random.Intn(551) + 300)
+		dti := random.Float64()*0.9 + 0.1
+		logit := 2 - 6*(creditScore-MinCreditScore)/(MaxCreditScore-MinCreditScore) + 4*dti
+		outcome := 0
+		if random.Float64() < Sigmoid(logit) {
+			outcome = 1
+		}
+		fmt.Fprintf(&csv, "%g,%.2f,%d\n", creditScore, dti, outcome)
+	}
+	return csv.String()
+}
+
+// Test ReadTrainingData and Train functions
+func TestTrain(t *testing.T) {
+	data, err := ReadTrainingData(strings.NewReader(labeledCSV(2000)), "Default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Names) != 2 || data.Names[0] != "Credit Score" || data.Names[1] != "DTI" || len(data.X) != 2000 {
+		t.Fatalf("ReadTrainingData read %v and %d samples", data.Names, len(data.X))
+	}
+
+	for _, normalization := range []Normalization{NormalizationZScore, NormalizationMinMax} {
+		t.Run(string(normalization), func(t *testing.T) {
+			model, metrics, err := Train(data, TrainOptions{Normalization: normalization, L2: 1e-3, Seed: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A better credit score lowers the risk of default, a higher DTI raises it
+			weights, _ := model.Coefficients()
+			if weights[0] >= 0 || weights[1] <= 0 {
+				t.Errorf("Train() folded weights = %v, want a negative then a positive weight", weights)
+			}
+			if metrics.TrainingSamples != 1600 || metrics.Samples != 400 || metrics.AUC < 0.75 || metrics.Accuracy < 0.65 {
+				t.Errorf("Train() validation metrics = %+v", metrics)
+			}
+			if lo, hi := model.LogitRange(); lo < -DefaultMaxLogit || hi > DefaultMaxLogit {
+				t.Errorf("Train() logit range = [%f, %f]", lo, hi)
+			}
+		})
+	}
+}
+
+// Test Train without a validation share
+func TestTrainNoValidation(t *testing.T) {
+	data, err := ReadTrainingData(strings.NewReader(labeledCSV(500)), "Default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, metrics, err := Train(data, TrainOptions{ValidationFraction: NoValidation, L2: 1e-3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.TrainingSamples != 500 || metrics.Samples != 500 {
+		t.Errorf("Train() without validation trained on %d and measured %d samples, want 500 and 500", metrics.TrainingSamples, metrics.Samples)
+	}
+}
+
+// Test the limits Train enforces for the encrypted evaluation
+func TestTrainLimits(t *testing.T) {
+	data, err := ReadTrainingData(strings.NewReader(labeledCSV(500)), "Default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Train(data, TrainOptions{MaxLogit: 0.1}); !errors.Is(err, ErrLogitRange) {
+		t.Errorf("Train() with a tiny logit range: error = %v, want %v", err, ErrLogitRange)
+	}
+
+	for _, x := range data.X {
+		x[0] *= 1e4
+	}
+	if _, _, err := Train(data, TrainOptions{}); !errors.Is(err, ErrFeatureMagnitude) {
+		t.Errorf("Train() with large features: error = %v, want %v", err, ErrFeatureMagnitude)
+	}
+
+	if _, err := ReadTrainingData(strings.NewReader("DTI,Default\n0.3,2\n"), "Default"); !errors.Is(err, ErrTrainingData) {
+		t.Errorf("ReadTrainingData() with a label of 2: error = %v, want %v", err, ErrTrainingData)
+	}
+}
+
+// Test LinearModel.Evaluate function
+func TestEvaluate(t *testing.T) {
+	model := &LinearModel{Features: []Feature{{Name: "x", Weight: 1, Min: -10, Max: 10}}}
+	metrics, err := model.Evaluate([][]float64{{-2}, {-1}, {1}, {3}}, []float64{0, 1, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Accuracy != 0.5 || metrics.Precision != 0.5 || metrics.Recall != 0.5 || metrics.AUC != 0.75 {
+		t.Errorf("Evaluate() = %+v", metrics)
+	}
+
+	wantLogLoss := -(math.Log(1-Sigmoid(-2)) + math.Log(Sigmoid(-1)) + math.Log(1-Sigmoid(1)) + math.Log(Sigmoid(3))) / 4
+	if math.Abs(metrics.LogLoss-wantLogLoss) > 1e-12 {
+		t.Errorf("Evaluate() log loss = %f, want %f", metrics.LogLoss, wantLogLoss)
+	}
+}
+
+// Test WriteModelFile and ReadModelFile functions
+func TestModelFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := WriteModelFile(path, &testModel); err != nil {
+		t.Fatal(err)
+	}
+	model, err := ReadModelFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range [][]float64{{800, 0.2, 150}, {420, 0.9, 12}} {
+		want, _ := testModel.Probability(x)
+		if got, _ := model.Probability(x); got != want {
+			t.Errorf("Probability(%v) = %f after a round trip, want %f", x, got, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package credit_evaluation
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxTreeDepth bounds the depth of the trees of an ensemble, since every level of a tree costs the encrypted
+// evaluation a comparison of every path and a longer product along it
+const MaxTreeDepth = 6
+
+// Link names how the summed leaf values of an ensemble are turned into its output
+type Link string
+
+const (
+	// LinkIdentity returns the score itself, as for regression trees
+	LinkIdentity Link = ""
+
+	// LinkLogistic reads the score as a logit and returns its probability, as for gradient-boosted classifiers
+	LinkLogistic Link = "logistic"
+)
+
+// TreeNode is either a split, with a feature, a threshold and both children, or a leaf with a value
+// A split sends the applicants whose feature is greater than the threshold to the right and the others to the left.
+type TreeNode struct {
+	Feature   string    `json:"Feature,omitempty"`
+	Threshold float64   `json:"Threshold,omitempty"`
+	Left      *TreeNode `json:"Left,omitempty"`
+	Right     *TreeNode `json:"Right,omitempty"`
+	Value     float64   `json:"Value,omitempty"`
+}
+
+// TreeEnsemble scores an applicant as the base score plus the value of the leaf each tree reaches, the score, and
+// applies its link to the score. A single decision tree is an ensemble of one tree.
+type TreeEnsemble struct {
+	Name      string         `json:"Name"`
+	Features  []FeatureRange `json:"Features"`
+	Trees     []*TreeNode    `json:"Trees"`
+	BaseScore float64        `json:"BaseScore"`
+	Link      Link           `json:"Link,omitempty"`
+}
+
+// IsLeaf reports whether the node is a leaf
+func (n *TreeNode) IsLeaf() bool {
+	return n.Left == nil && n.Right == nil
+}
+
+// Depth returns the number of splits on the longest path from the node to a leaf
+func (n *TreeNode) Depth() int {
+	if n.IsLeaf() {
+		return 0
+	}
+	return 1 + max(n.Left.Depth(), n.Right.Depth())
+}
+
+// ValueRange returns the smallest and largest leaf values of the tree
+func (n *TreeNode) ValueRange() (lo float64, hi float64) {
+	if n.IsLeaf() {
+		return n.Value, n.Value
+	}
+	leftLo, leftHi := n.Left.ValueRange()
+	rightLo, rightHi := n.Right.ValueRange()
+	return math.Min(leftLo, rightLo), math.Max(leftHi, rightHi)
+}
+
+// Validate checks the declared ranges of the features, the structure of the trees and that every threshold lies in
+// the range of its feature
+func (e *TreeEnsemble) Validate() error {
+	if len(e.Features) == 0 {
+		return fmt.Errorf("%w: no features", ErrInvalidModel)
+	}
+	if len(e.Trees) == 0 {
+		return fmt.Errorf("%w: no trees", ErrInvalidModel)
+	}
+	switch e.Link {
+	case LinkIdentity, LinkLogistic:
+	default:
+		return fmt.Errorf("%w: unknown link %q", ErrInvalidModel, e.Link)
+	}
+
+	ranges := make(map[string]FeatureRange, len(e.Features))
+	for _, f := range e.Features {
+		if err := f.validate(); err != nil {
+			return err
+		}
+		if _, ok := ranges[f.Name]; ok {
+			return fmt.Errorf("%w: feature %s is declared twice", ErrInvalidModel, f.Name)
+		}
+		ranges[f.Name] = f
+	}
+
+	for i, tree := range e.Trees {
+		if tree == nil {
+			return fmt.Errorf("%w: tree %d is empty", ErrInvalidModel, i)
+		}
+		if err := tree.validate(ranges); err != nil {
+			return fmt.Errorf("%w: tree %d: %v", ErrInvalidModel, i, err)
+		}
+		if depth := tree.Depth(); depth > MaxTreeDepth {
+			return fmt.Errorf("%w: tree %d has depth %d, at most %d", ErrInvalidModel, i, depth, MaxTreeDepth)
+		}
+	}
+	return nil
+}
+
+// validate checks a subtree against the declared feature ranges
+func (n *TreeNode) validate(ranges map[string]FeatureRange) error {
+	if n.IsLeaf() {
+		return nil
+	}
+	if n.Left == nil || n.Right == nil {
+		return fmt.Errorf("split on %s has a single child", n.Feature)
+	}
+	f, ok := ranges[n.Feature]
+	if !ok {
+		return fmt.Errorf("split on undeclared feature %q", n.Feature)
+	}
+	if n.Threshold < f.Min || n.Threshold > f.Max {
+		return fmt.Errorf("threshold %g of %s not in [%g, %g]", n.Threshold, f.Name, f.Min, f.Max)
+	}
+	if err := n.Left.validate(ranges); err != nil {
+		return err
+	}
+	return n.Right.validate(ranges)
+}
+
+// Depth returns the depth of the deepest tree
+func (e *TreeEnsemble) Depth() int {
+	depth := 0
+	for _, tree := range e.Trees {
+		depth = max(depth, tree.Depth())
+	}
+	return depth
+}
+
+// ScoreRange returns the smallest and largest score the ensemble can return
+func (e *TreeEnsemble) ScoreRange() (lo float64, hi float64) {
+	lo, hi = e.BaseScore, e.BaseScore
+	for _, tree := range e.Trees {
+		treeLo, treeHi := tree.ValueRange()
+		lo += treeLo
+		hi += treeHi
+	}
+	return lo, hi
+}
+
+// FeatureIndex returns the position of a feature in the declared features, or -1 when it is not declared
+func (e *TreeEnsemble) FeatureIndex(name string) int {
+	for i, f := range e.Features {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CheckFeatures checks that raw features match the ensemble and lie in their declared ranges
+func (e *TreeEnsemble) CheckFeatures(x []float64) error {
+	return checkFeatures(e.Features, x)
+}
+
+// Score returns the base score plus the value of the leaf each tree reaches
+func (e *TreeEnsemble) Score(x []float64) (float64, error) {
+	if len(x) != len(e.Features) {
+		return 0, fmt.Errorf("%w: got %d, expected %d", ErrFeatureMismatch, len(x), len(e.Features))
+	}
+	score := e.BaseScore
+	for _, tree := range e.Trees {
+		node := tree
+		for !node.IsLeaf() {
+			i := e.FeatureIndex(node.Feature)
+			if i < 0 {
+				return 0, fmt.Errorf("%w: split on undeclared feature %q", ErrInvalidModel, node.Feature)
+			}
+			if x[i] > node.Threshold {
+				node = node.Right
+			} else {
+				node = node.Left
+			}
+		}
+		score += node.Value
+	}
+	return score, nil
+}
+
+// Output applies the link of the ensemble to its score
+func (e *TreeEnsemble) Output(x []float64) (float64, error) {
+	score, err := e.Score(x)
+	if err != nil {
+		return 0, err
+	}
+	if e.Link == LinkLogistic {
+		return Sigmoid(score), nil
+	}
+	return score, nil
+}
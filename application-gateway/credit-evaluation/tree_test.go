@@ -0,0 +1,123 @@
+package credit_evaluation
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// testEnsemble holds a balanced tree, an unbalanced tree and a single leaf
+var testEnsemble = TreeEnsemble{
+	Name: "test",
+	Features: []FeatureRange{
+		{Name: "CreditScore", Min: MinCreditScore, Max: MaxCreditScore},
+		{Name: "DTI", Min: 0, Max: 1},
+	},
+	Trees: []*TreeNode{
+		{
+			Feature: "CreditScore", Threshold: 650,
+			Left:  &TreeNode{Value: -1},
+			Right: &TreeNode{Feature: "DTI", Threshold: 0.4, Left: &TreeNode{Value: 0.9}, Right: &TreeNode{Value: 0.2}},
+		},
+		{
+			Feature: "DTI", Threshold: 0.6,
+			Left:  &TreeNode{Feature: "CreditScore", Threshold: 500, Left: &TreeNode{Value: 0.1}, Right: &TreeNode{Value: 0.5}},
+			Right: &TreeNode{Value: -0.8},
+		},
+		{Value: 0.05},
+	},
+	BaseScore: -0.1,
+	Link:      LinkLogistic,
+}
+
+// Test TreeEnsemble.Score and TreeEnsemble.Output functions
+func TestTreeEnsemble(t *testing.T) {
+	tests := []struct {
+		name string
+		x    []float64
+		want float64
+	}{
+		{"Good Applicant", []float64{800, 0.2}, -0.1 + 0.9 + 0.5 + 0.05},
+		{"Poor Applicant", []float64{420, 0.9}, -0.1 - 1 - 0.8 + 0.05},
+		{"On The Thresholds", []float64{650, 0.6}, -0.1 - 1 + 0.5 + 0.05},
+		{"High DTI", []float64{700, 0.5}, -0.1 + 0.2 + 0.5 + 0.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testEnsemble.Score(tt.x)
+			if err != nil || math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Score(%v) = %f, %v, want %f", tt.x, got, err, tt.want)
+			}
+			output, err := testEnsemble.Output(tt.x)
+			if err != nil || math.Abs(output-Sigmoid(tt.want)) > 1e-12 {
+				t.Errorf("Output(%v) = %f, %v, want %f", tt.x, output, err, Sigmoid(tt.want))
+			}
+		})
+	}
+
+	if lo, hi := testEnsemble.ScoreRange(); math.Abs(lo-(-1.85)) > 1e-12 || math.Abs(hi-1.35) > 1e-12 {
+		t.Errorf("ScoreRange() = [%f, %f], want [-1.85, 1.35]", lo, hi)
+	}
+	if depth := testEnsemble.Depth(); depth != 2 {
+		t.Errorf("Depth() = %d, want 2", depth)
+	}
+	if _, err := testEnsemble.Score([]float64{800}); !errors.Is(err, ErrFeatureMismatch) {
+		t.Errorf("Score() with a missing feature: error = %v, want %v", err, ErrFeatureMismatch)
+	}
+}
+
+// Test TreeEnsemble.Validate function
+func TestValidateEnsemble(t *testing.T) {
+	if err := testEnsemble.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		tree *TreeNode
+	}{
+		{"Undeclared Feature", &TreeNode{Feature: "Age", Threshold: 18, Left: &TreeNode{}, Right: &TreeNode{}}},
+		{"Threshold Out Of Range", &TreeNode{Feature: "DTI", Threshold: 2, Left: &TreeNode{}, Right: &TreeNode{}}},
+		{"Single Child", &TreeNode{Feature: "DTI", Threshold: 0.5, Left: &TreeNode{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ensemble := testEnsemble
+			ensemble.Trees = []*TreeNode{tt.tree}
+			if err := ensemble.Validate(); !errors.Is(err, ErrInvalidModel) {
+				t.Errorf("Validate() = %v, want %v", err, ErrInvalidModel)
+			}
+		})
+	}
+
+	deep := &TreeNode{Value: 1}
+	for i := 0; i <= MaxTreeDepth; i++ {
+		deep = &TreeNode{Feature: "DTI", Threshold: 0.5, Left: deep, Right: &TreeNode{}}
+	}
+	ensemble := testEnsemble
+	ensemble.Trees = []*TreeNode{deep}
+	if err := ensemble.Validate(); !errors.Is(err, ErrInvalidModel) {
+		t.Errorf("Validate() of a tree deeper than %d = %v, want %v", MaxTreeDepth, err, ErrInvalidModel)
+	}
+}
+
+// Test WriteEnsembleFile and ReadEnsembleFile functions
+func TestEnsembleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ensemble.json")
+	if err := WriteEnsembleFile(path, &testEnsemble); err != nil {
+		t.Fatal(err)
+	}
+	ensemble, err := ReadEnsembleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range [][]float64{{800, 0.2}, {420, 0.9}, {700, 0.5}} {
+		want, _ := testEnsemble.Output(x)
+		if got, _ := ensemble.Output(x); got != want {
+			t.Errorf("Output(%v) = %f after a round trip, want %f", x, got, want)
+		}
+	}
+}
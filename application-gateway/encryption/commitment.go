@@ -0,0 +1,184 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// CommitmentScheme names the hash commitment scheme of FieldCommitment
+const CommitmentScheme = "sha256"
+
+// CommitmentTolerance is the largest difference accepted between a committed number and its CKKS decryption,
+// well above the error of a fresh encryption and well below any meaningful change of an input
+const CommitmentTolerance = 1e-3
+
+// commitmentDomain separates the commitment hashes from any other use of SHA-256
+const commitmentDomain = "credit-evaluation field commitment v1"
+
+// openingPrefix is prepended to the field name authenticated with the encrypted opening
+const openingPrefix = "opening:"
+
+var (
+	ErrCommitmentMismatch = errors.New("decrypted value does not match the committed value")
+	ErrInvalidSignature   = errors.New("issuer signature does not verify")
+)
+
+// Opening is the secret needed to open a commitment: the exact committed value and the randomness hiding it
+// Numbers are committed in their shortest decimal form, text as is.
+type Opening struct {
+	Value      string `json:"Value"`
+	Randomness []byte `json:"Randomness"`
+}
+
+// FieldCommitment binds the ciphertext of a document field to the plaintext the issuer committed to
+// The issuer signs the commitment together with a digest of the stored ciphertext, and encrypts the opening to the
+// owner, who can then check after decryption that the ciphertext holds the committed value. KeyID names the signing
+// key of the issuer, so that the owner finds it on the ledger after a rotation.
+type FieldCommitment struct {
+	Scheme           string `json:"Scheme"`
+	Issuer           string `json:"Issuer"`
+	KeyID            string `json:"KeyID"`
+	Commitment       string `json:"Commitment"`
+	CiphertextDigest string `json:"CiphertextDigest"`
+	Signature        []byte `json:"Signature"`
+	Opening          string `json:"Opening"`
+}
+
+// NewOpening draws fresh randomness for committing to a value
+func NewOpening(value string) (*Opening, error) {
+	randomness := make([]byte, 32)
+	if _, err := rand.Read(randomness); err != nil {
+		return nil, err
+	}
+	return &Opening{Value: value, Randomness: randomness}, nil
+}
+
+// NumberOpening returns the opening of a numeric value
+func NumberOpening(value float64) (*Opening, error) {
+	return NewOpening(strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// Commit returns the hex commitment of the opening to the value of the named field
+func (o *Opening) Commit(field string) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(commitmentDomain), []byte(field), []byte(o.Value), o.Randomness} {
+		h.Write(binary.AppendUvarint(nil, uint64(len(part))))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CommitField commits to the opening of a field whose encrypted value is already computed, signs the commitment
+// as issuer with the key of ID keyID and encrypts the opening to the owner's hybrid key
+func (f *FieldEncryptor) CommitField(issuer string, keyID string, signer *Signer, field, encryptedValue string, opening *Opening) (*FieldCommitment, error) {
+	if f.Hybrid == nil {
+		return nil, fmt.Errorf("%w: %s", ErrMissingKey, SchemeECIES)
+	}
+	openingJSON, err := json.Marshal(opening)
+	if err != nil {
+		return nil, err
+	}
+	encryptedOpening, err := f.EncryptText(openingPrefix+field, string(openingJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	commitment := &FieldCommitment{
+		Scheme:           CommitmentScheme,
+		Issuer:           issuer,
+		KeyID:            keyID,
+		Commitment:       opening.Commit(field),
+		CiphertextDigest: checksum([]byte(encryptedValue)),
+		Opening:          encryptedOpening,
+	}
+	digest := commitment.digest(field)
+	if commitment.Signature, err = signer.SignHash(digest[:]); err != nil {
+		return nil, err
+	}
+	return commitment, nil
+}
+
+// VerifySignature checks that the issuer signed the commitment for this field and this encrypted value
+func (c *FieldCommitment) VerifySignature(issuerKey *ecdsa.PublicKey, field, encryptedValue string) error {
+	if c.Scheme != CommitmentScheme {
+		return fmt.Errorf("%w: %s", ErrUnsupportedScheme, c.Scheme)
+	}
+	if c.CiphertextDigest != checksum([]byte(encryptedValue)) {
+		return fmt.Errorf("%w: the commitment is for another ciphertext", ErrInvalidSignature)
+	}
+	digest := c.digest(field)
+	if !VerifyHash(issuerKey, digest[:], c.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// digest returns the hash the issuer signs, covering everything but the signature and the encrypted opening
+func (c *FieldCommitment) digest(field string) [32]byte {
+	var buf bytes.Buffer
+	for _, part := range []string{commitmentDomain, c.Scheme, c.Issuer, c.KeyID, field, c.Commitment, c.CiphertextDigest} {
+		buf.Write(binary.AppendUvarint(nil, uint64(len(part))))
+		buf.WriteString(part)
+	}
+	return sha256.Sum256(buf.Bytes())
+}
+
+// CheckCommitment verifies the issuer signature of a field commitment, opens it and checks that the encrypted
+// value of the field decrypts to the committed value, exactly for text and within CommitmentTolerance for numbers
+func (d *FieldDecryptor) CheckCommitment(c *FieldCommitment, issuerKey *ecdsa.PublicKey, field, encryptedValue string) error {
+	if err := c.VerifySignature(issuerKey, field, encryptedValue); err != nil {
+		return err
+	}
+
+	openingJSON, err := d.DecryptField(openingPrefix+field, c.Opening)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the opening: %w", err)
+	}
+	var opening Opening
+	if err = json.Unmarshal([]byte(openingJSON), &opening); err != nil {
+		return fmt.Errorf("opening is malformed: %w", err)
+	}
+	if opening.Commit(field) != c.Commitment {
+		return fmt.Errorf("%w: the opening does not match the commitment", ErrCommitmentMismatch)
+	}
+
+	envelope, err := DecodeEnvelope(encryptedValue)
+	if err != nil {
+		return err
+	}
+	if envelope.Scheme != SchemeCKKS {
+		value, err := d.DecryptField(field, encryptedValue)
+		if err != nil {
+			return err
+		}
+		if value != opening.Value {
+			return ErrCommitmentMismatch
+		}
+		return nil
+	}
+
+	if d.CKKS == nil {
+		return fmt.Errorf("%w: %s", ErrMissingKey, SchemeCKKS)
+	}
+	committed, err := strconv.ParseFloat(opening.Value, 64)
+	if err != nil {
+		return fmt.Errorf("%w: the committed value is not a number", ErrCommitmentMismatch)
+	}
+	values, err := d.CKKS.Values(envelope)
+	if err != nil {
+		return err
+	}
+	if math.Abs(values[0]-committed) > CommitmentTolerance {
+		return fmt.Errorf("%w: decrypted %f, committed %s", ErrCommitmentMismatch, values[0], opening.Value)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package encryption
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestFieldCommitments ensures that the owner accepts fields holding the committed values and rejects
+// commitments that were forged, moved or do not match the ciphertext
+func TestFieldCommitments(t *testing.T) {
+	helper := NewCKKSHelper()
+	hybridKey, err := GenHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerKey, err := GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewSigner(issuerKey)
+	encryptor := FieldEncryptor{CKKS: helper.CKKSEncryptor, Hybrid: hybridKey.PublicKey()}
+	decryptor := FieldDecryptor{CKKS: helper.CKKSKeyOwner, Hybrid: hybridKey}
+
+	salary, err := encryptor.EncryptNumber(52000000.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salaryOpening, err := NumberOpening(52000000.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salaryCommitment, err := encryptor.CommitField("bank", "bank-key", issuer, "Salary", salary, salaryOpening)
+	if err != nil {
+		t.Fatalf("CommitField failed: %v", err)
+	}
+
+	employer, err := encryptor.EncryptText("Employer", "Acme Trading Co.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	employerOpening, err := NewOpening("Acme Trading Co.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	employerCommitment, err := encryptor.CommitField("bank", "bank-key", issuer, "Employer", employer, employerOpening)
+	if err != nil {
+		t.Fatalf("CommitField failed: %v", err)
+	}
+
+	if err = decryptor.CheckCommitment(salaryCommitment, issuer.PublicKey(), "Salary", salary); err != nil {
+		t.Errorf("CheckCommitment of a number failed: %v", err)
+	}
+	if err = decryptor.CheckCommitment(employerCommitment, issuer.PublicKey(), "Employer", employer); err != nil {
+		t.Errorf("CheckCommitment of a text failed: %v", err)
+	}
+
+	// The issuer encrypts one value and commits to another
+	otherSalary, err := encryptor.EncryptNumber(12000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dishonest, err := encryptor.CommitField("bank", "bank-key", issuer, "Salary", otherSalary, salaryOpening)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decryptor.CheckCommitment(dishonest, issuer.PublicKey(), "Salary", otherSalary); !errors.Is(err, ErrCommitmentMismatch) {
+		t.Errorf("CheckCommitment of another value returned %v, expected %v", err, ErrCommitmentMismatch)
+	}
+
+	// A commitment does not vouch for another ciphertext, another field or another issuer
+	if err = decryptor.CheckCommitment(salaryCommitment, issuer.PublicKey(), "Salary", otherSalary); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("CheckCommitment of another ciphertext returned %v, expected %v", err, ErrInvalidSignature)
+	}
+	if err = decryptor.CheckCommitment(employerCommitment, issuer.PublicKey(), "Notes", employer); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("CheckCommitment under another name returned %v, expected %v", err, ErrInvalidSignature)
+	}
+	otherKey, err := GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decryptor.CheckCommitment(salaryCommitment, &otherKey.PublicKey, "Salary", salary); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("CheckCommitment with another issuer key returned %v, expected %v", err, ErrInvalidSignature)
+	}
+
+	// The signature covers the ID of the issuer key
+	renamed := *salaryCommitment
+	renamed.KeyID = "other-key"
+	if err = decryptor.CheckCommitment(&renamed, issuer.PublicKey(), "Salary", salary); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("CheckCommitment under another key ID returned %v, expected %v", err, ErrInvalidSignature)
+	}
+
+	// An opening that does not hash to the commitment is rejected
+	forged := *employerCommitment
+	forged.Opening, err = encryptor.EncryptText(openingPrefix+"Employer", `{"Value":"Acme Trading Co.","Randomness":"AAAA"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = decryptor.CheckCommitment(&forged, issuer.PublicKey(), "Employer", employer); !errors.Is(err, ErrCommitmentMismatch) {
+		t.Errorf("CheckCommitment of a forged opening returned %v, expected %v", err, ErrCommitmentMismatch)
+	}
+}
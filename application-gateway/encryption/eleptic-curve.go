@@ -4,8 +4,17 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
 )
 
+var ErrNotSigningKey = errors.New("encoded key is not an ECDSA public key")
+
 type Signer struct {
 	privateKey ecdsa.PrivateKey
 }
@@ -24,10 +33,77 @@ func GenKey() (*ecdsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
+// Sign hashes data with SHA-256 and signs the hash, as Verify expects
 func (s *Signer) Sign(data []byte) ([]byte, error) {
-	return ecdsa.SignASN1(rand.Reader, &s.privateKey, data)
+	hash := sha256.Sum256(data)
+	return s.SignHash(hash[:])
+}
+
+// SignHash signs a hash the caller already computed, such as the digest of a document
+func (s *Signer) SignHash(hash []byte) ([]byte, error) {
+	return ecdsa.SignASN1(rand.Reader, &s.privateKey, hash)
 }
 
-func (s *Signer) Verify(pub *ecdsa.PublicKey, hash, sig []byte) bool {
+// Verify checks a signature made by Sign on data, hashing data with SHA-256
+func (s *Signer) Verify(pub *ecdsa.PublicKey, data, sig []byte) bool {
+	hash := sha256.Sum256(data)
+	return VerifyHash(pub, hash[:], sig)
+}
+
+// VerifyHash checks a signature made by SignHash on a hash
+func VerifyHash(pub *ecdsa.PublicKey, hash, sig []byte) bool {
 	return ecdsa.VerifyASN1(pub, hash, sig)
 }
+
+// PublicKey returns the public key matching the private key of the signer
+func (s *Signer) PublicKey() *ecdsa.PublicKey {
+	return &s.privateKey.PublicKey
+}
+
+// EncodeSigningKey serializes an ECDSA public key as base64 PKIX, to be published on the ledger
+func EncodeSigningKey(pub *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// DecodeSigningKey parses an ECDSA public key encoded by EncodeSigningKey
+func DecodeSigningKey(encoded string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encoded signing key is malformed: %w", err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("encoded signing key is malformed: %w", err)
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrNotSigningKey
+	}
+	return key, nil
+}
+
+// WriteSigningKey stores an ECDSA private key as PEM, readable by its owner only
+func WriteSigningKey(path string, privateKey *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+}
+
+// ReadSigningKey reads an ECDSA private key written by WriteSigningKey
+func ReadSigningKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key file %s is not PEM encoded", path)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
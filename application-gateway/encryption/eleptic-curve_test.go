@@ -0,0 +1,79 @@
+package encryption
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+	"testing"
+)
+
+// TestSigningKeyEncoding ensures that a stored signing key and its published public key still verify signatures
+func TestSigningKeyEncoding(t *testing.T) {
+	privateKey, err := GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "signing.key")
+	if err = WriteSigningKey(path, privateKey); err != nil {
+		t.Fatalf("WriteSigningKey failed: %v", err)
+	}
+	loaded, err := ReadSigningKey(path)
+	if err != nil {
+		t.Fatalf("ReadSigningKey failed: %v", err)
+	}
+
+	encoded, err := EncodeSigningKey(NewSigner(privateKey).PublicKey())
+	if err != nil {
+		t.Fatalf("EncodeSigningKey failed: %v", err)
+	}
+	publicKey, err := DecodeSigningKey(encoded)
+	if err != nil {
+		t.Fatalf("DecodeSigningKey failed: %v", err)
+	}
+
+	signer := NewSigner(loaded)
+	hash := sha256.Sum256([]byte("document"))
+	signature, err := signer.Sign(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signer.Verify(publicKey, hash[:], signature) {
+		t.Error("signature of the loaded key does not verify under the decoded public key")
+	}
+
+	if _, err = DecodeSigningKey("not a key"); err == nil {
+		t.Error("DecodeSigningKey accepted a malformed key")
+	}
+}
+
+// TestSignVerify ensures that Sign and Verify hash the data the same way, and SignHash and VerifyHash not at all
+func TestSignVerify(t *testing.T) {
+	privateKey, err := GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewSigner(privateKey)
+
+	signature, err := signer.Sign([]byte("document"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signer.Verify(signer.PublicKey(), []byte("document"), signature) {
+		t.Error("signature of the data does not verify")
+	}
+	if signer.Verify(signer.PublicKey(), []byte("other document"), signature) {
+		t.Error("signature verified for other data")
+	}
+
+	hash := sha256.Sum256([]byte("document"))
+	if !VerifyHash(signer.PublicKey(), hash[:], signature) {
+		t.Error("signature of the data does not verify against its SHA-256 hash")
+	}
+	hashSignature, err := signer.SignHash(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signer.Verify(signer.PublicKey(), []byte("document"), hashSignature) {
+		t.Error("signature of the hash does not verify against the data")
+	}
+}
@@ -1,57 +1,184 @@
 package encryption
 
 import (
+	"errors"
 	"fmt"
+	"math"
+
 	"github.com/tuneinsight/lattigo/v4/ckks"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
 
-// CKKSHelper encapsulates CKKS parameters, keys, and operations
-type CKKSHelper struct {
+// CKKSKeyOwner holds the secret key and is the only role able to decrypt
+type CKKSKeyOwner struct {
+	Params      ckks.Parameters
+	Encoder     ckks.Encoder
+	EncryptorPr rlwe.Encryptor
+	Decryptor   rlwe.Decryptor
+	LogSlots    int
+	Scale       rlwe.Scale
+	secretKey   *rlwe.SecretKey
+}
+
+// CKKSEncryptor encrypts values under a public key and cannot decrypt them
+type CKKSEncryptor struct {
+	Params      ckks.Parameters
+	Encoder     ckks.Encoder
+	EncryptorPu rlwe.Encryptor
+	KeyID       string
+	LogSlots    int
+	Scale       rlwe.Scale
+}
+
+// CKKSEvaluator performs homomorphic operations using evaluation keys only
+type CKKSEvaluator struct {
 	Params       ckks.Parameters
 	Encoder      ckks.Encoder
-	EncryptorPu  rlwe.Encryptor
-	EncryptorPr  rlwe.Encryptor
-	Decryptor    rlwe.Decryptor
 	Evaluator    ckks.Evaluator
 	Relinearizer *rlwe.RelinearizationKey
+	Policy       ScoringPolicy
 	LogSlots     int
 	Scale        rlwe.Scale
-	secretKey    *rlwe.SecretKey
+	btp          *bootstrappingState
+}
+
+// CKKSHelper bundles the key owner, encryptor and evaluator roles of a single key set
+// Like the roles, it must not be used by several goroutines at once: see ShallowCopy and CKKSHelperPool.
+type CKKSHelper struct {
+	*CKKSKeyOwner
+	*CKKSEncryptor
+	*CKKSEvaluator
+	Params      ckks.Parameters
+	Encoder     ckks.Encoder
+	LogSlots    int
+	Scale       rlwe.Scale
+	Fingerprint string
+	Security    int
+	publicKey   *rlwe.PublicKey
+}
+
+// NewCKKSKeyOwner initializes the key owner role from a secret key
+func NewCKKSKeyOwner(params ckks.Parameters, sk *rlwe.SecretKey) *CKKSKeyOwner {
+	return newCKKSKeyOwner(params, ckks.NewEncoder(params), sk)
+}
+
+func newCKKSKeyOwner(params ckks.Parameters, encoder ckks.Encoder, sk *rlwe.SecretKey) *CKKSKeyOwner {
+	return &CKKSKeyOwner{
+		Params:      params,
+		Encoder:     encoder,
+		EncryptorPr: ckks.NewEncryptor(params, sk),
+		Decryptor:   ckks.NewDecryptor(params, sk),
+		LogSlots:    params.LogSlots(),
+		Scale:       params.DefaultScale(),
+		secretKey:   sk,
+	}
+}
+
+// NewCKKSEncryptor initializes the encryptor role from a public key
+func NewCKKSEncryptor(params ckks.Parameters, pk *rlwe.PublicKey) *CKKSEncryptor {
+	return newCKKSEncryptor(params, ckks.NewEncoder(params), pk)
+}
+
+func newCKKSEncryptor(params ckks.Parameters, encoder ckks.Encoder, pk *rlwe.PublicKey) *CKKSEncryptor {
+	return &CKKSEncryptor{
+		Params:      params,
+		Encoder:     encoder,
+		EncryptorPu: ckks.NewEncryptor(params, pk),
+		KeyID:       PublicKeyID(pk),
+		LogSlots:    params.LogSlots(),
+		Scale:       params.DefaultScale(),
+	}
+}
+
+// NewCKKSEvaluator initializes the evaluator role from a relinearization key, using the default scoring policy
+func NewCKKSEvaluator(params ckks.Parameters, rlk *rlwe.RelinearizationKey) *CKKSEvaluator {
+	return newCKKSEvaluator(params, DefaultScoringPolicy(), ckks.NewEncoder(params), rlk)
+}
+
+func newCKKSEvaluator(params ckks.Parameters, policy ScoringPolicy, encoder ckks.Encoder, rlk *rlwe.RelinearizationKey) *CKKSEvaluator {
+	return &CKKSEvaluator{
+		Params:       params,
+		Encoder:      encoder,
+		Evaluator:    ckks.NewEvaluator(params, rlwe.EvaluationKey{Rlk: rlk}),
+		Relinearizer: rlk,
+		Policy:       policy,
+		LogSlots:     params.LogSlots(),
+		Scale:        params.DefaultScale(),
+	}
 }
 
-// NewCKKSHelper initializes a CKKSHelper instance
+// NewCKKSHelper initializes a CKKSHelper instance with a freshly generated key pair
 func NewCKKSHelper() *CKKSHelper {
-	// Create CKKS parameters (using default parameter set PN12QP109)
-	params, err := ckks.NewParametersFromLiteral(ckks.PN14QP438)
+	// Use the default parameter set (PN14QP438) and scoring policy
+	helper, err := NewCKKSHelperWithOptions(HelperOptions{})
 	if err != nil {
 		panic(err)
 	}
+	return helper
+}
+
+// NewCKKSHelperWithOptions initializes a CKKSHelper instance with a freshly generated key pair
+// under the parameters selected by opts
+func NewCKKSHelperWithOptions(opts HelperOptions) (*CKKSHelper, error) {
+	// Create CKKS parameters
+	params, policy, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate keys
 	kgen := ckks.NewKeyGenerator(params)
 	sk, pk := kgen.GenKeyPair()
 	rlk := kgen.GenRelinearizationKey(sk, 1)
 
+	return newCKKSHelper(params, policy, sk, pk, rlk)
+}
+
+// newCKKSHelper builds a CKKSHelper around an existing set of keys
+func newCKKSHelper(params ckks.Parameters, policy ScoringPolicy, sk *rlwe.SecretKey, pk *rlwe.PublicKey, rlk *rlwe.RelinearizationKey) (*CKKSHelper, error) {
+	fingerprint, err := ParamsFingerprint(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// All roles share a single encoder
+	encoder := ckks.NewEncoder(params)
+
 	// Return the helper instance
 	return &CKKSHelper{
-		Params:       params,
-		Encoder:      ckks.NewEncoder(params),
-		EncryptorPu:  ckks.NewEncryptor(params, pk),
-		EncryptorPr:  ckks.NewEncryptor(params, sk),
-		Decryptor:    ckks.NewDecryptor(params, sk),
-		Evaluator:    ckks.NewEvaluator(params, rlwe.EvaluationKey{Rlk: rlk}),
-		Relinearizer: rlk,
-		LogSlots:     params.LogSlots(),
-		Scale:        params.DefaultScale(),
-		secretKey:    sk,
+		CKKSKeyOwner:  newCKKSKeyOwner(params, encoder, sk),
+		CKKSEncryptor: newCKKSEncryptor(params, encoder, pk),
+		CKKSEvaluator: newCKKSEvaluator(params, policy, encoder, rlk),
+		Params:        params,
+		Encoder:       encoder,
+		LogSlots:      params.LogSlots(),
+		Scale:         params.DefaultScale(),
+		Fingerprint:   fingerprint,
+		Security:      EstimatedSecurity(params),
+		publicKey:     pk,
+	}, nil
+}
+
+// PublicKey returns the public key of the helper, to be published for the parties encrypting to its owner
+func (c *CKKSHelper) PublicKey() *rlwe.PublicKey {
+	return c.publicKey
+}
+
+// fillSlots returns the slot values of a vector, repeating it cyclically over every slot
+// Leaving the remaining slots at zero would feed values outside the declared input range to
+// approximations such as the inverse, whose growth in those slots can overflow the modulus.
+func fillSlots(values []float64, logSlots int) []complex128 {
+	slots := make([]complex128, 1<<logSlots)
+	for i := range slots {
+		slots[i] = complex(values[i%len(values)], 0)
 	}
+	return slots
 }
 
 // EncryptPu Encrypt encodes and encrypts a value into a ciphertext
-func (c *CKKSHelper) EncryptPu(value float64) *rlwe.Ciphertext {
+func (c *CKKSEncryptor) EncryptPu(value float64) *rlwe.Ciphertext {
 	// Encode the value into a plaintext
-	values := []complex128{complex(value, 0)} // Single value in slot
+	values := fillSlots([]float64{value}, c.LogSlots)
 	plaintext := c.Encoder.EncodeNew(values, c.Params.MaxLevel(), c.Scale, c.LogSlots)
 
 	// Encrypt the plaintext
@@ -59,9 +186,9 @@ func (c *CKKSHelper) EncryptPu(value float64) *rlwe.Ciphertext {
 }
 
 // EncryptPr Encrypt encodes and encrypts a value into a ciphertext
-func (c *CKKSHelper) EncryptPr(value float64) *rlwe.Ciphertext {
+func (c *CKKSKeyOwner) EncryptPr(value float64) *rlwe.Ciphertext {
 	// Encode the value into a plaintext
-	values := []complex128{complex(value, 0)} // Single value in slot
+	values := fillSlots([]float64{value}, c.LogSlots)
 	plaintext := c.Encoder.EncodeNew(values, c.Params.MaxLevel(), c.Scale, c.LogSlots)
 
 	// Encrypt the plaintext
@@ -69,7 +196,8 @@ func (c *CKKSHelper) EncryptPr(value float64) *rlwe.Ciphertext {
 }
 
 // Decrypt decrypts a ciphertext using the default secret key and decodes it
-func (c *CKKSHelper) Decrypt(ciphertext *rlwe.Ciphertext) float64 {
+// The value is meant for the key owner; values handed to another party go through DecryptShared.
+func (c *CKKSKeyOwner) Decrypt(ciphertext *rlwe.Ciphertext) float64 {
 	// Decrypt the ciphertext into a plaintext
 	plaintext := c.Decryptor.DecryptNew(ciphertext)
 
@@ -81,7 +209,7 @@ func (c *CKKSHelper) Decrypt(ciphertext *rlwe.Ciphertext) float64 {
 }
 
 // DecryptWithKey decrypts a ciphertext using a provided secret key and decodes it
-func (c *CKKSHelper) DecryptWithKey(ciphertext *rlwe.Ciphertext, secretKey *rlwe.SecretKey) float64 {
+func (c *CKKSKeyOwner) DecryptWithKey(ciphertext *rlwe.Ciphertext, secretKey *rlwe.SecretKey) float64 {
 	// Create a decryptor with the provided secret key
 	decryptor := ckks.NewDecryptor(c.Params, secretKey)
 
@@ -96,68 +224,101 @@ func (c *CKKSHelper) DecryptWithKey(ciphertext *rlwe.Ciphertext, secretKey *rlwe
 }
 
 // Add adds two ciphertexts and returns the result
-func (c *CKKSHelper) Add(ct1, ct2 *rlwe.Ciphertext) *rlwe.Ciphertext {
-	// Create a new ciphertext to store the result
-	result := ckks.NewCiphertext(c.Params, 1, ct1.Level())
+// The operands are first brought to the same level and scale.
+func (c *CKKSEvaluator) Add(ct1, ct2 *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	ct1, ct2, err := c.alignOperands(ct1, ct2)
+	if err != nil {
+		return nil, err
+	}
 
 	// Perform the addition
-	c.Evaluator.Add(ct1, ct2, result)
-
-	return result
+	return c.Evaluator.AddNew(ct1, ct2), nil
 }
 
 // AddWithPlain adds a ciphertext with a plaintext and returns the result
-func (c *CKKSHelper) AddWithPlain(ct *rlwe.Ciphertext, value float64) *rlwe.Ciphertext {
+// The plaintext is encoded at the level and scale of the ciphertext, so no level is consumed.
+func (c *CKKSEvaluator) AddWithPlain(ct *rlwe.Ciphertext, value float64) *rlwe.Ciphertext {
 	// Encode the value into a plaintext
-	values := []complex128{complex(value, 0)} // Single value in slot
-	plaintext := c.Encoder.EncodeNew(values, ct.Level(), c.Scale, c.LogSlots)
-
-	// Create a new ciphertext to store the result
-	result := ckks.NewCiphertext(c.Params, 1, ct.Level())
+	plaintext := c.Encoder.EncodeNew(fillSlots([]float64{value}, c.LogSlots), ct.Level(), ct.Scale, c.LogSlots)
 
 	// Perform the addition
-	c.Evaluator.Add(ct, plaintext, result)
-
-	// Rescale the result to maintain the correct scale
-	c.Evaluator.Rescale(result, c.Scale, result)
-
-	return result
+	return c.Evaluator.AddNew(ct, plaintext)
 }
 
 // Multiply multiplies two ciphertexts and returns the result
-func (c *CKKSHelper) Multiply(ct1, ct2 *rlwe.Ciphertext) *rlwe.Ciphertext {
-	// Create a new ciphertext to store the result
-	result := ckks.NewCiphertext(c.Params, 1, ct1.Level())
+// The product is computed at the lowest level of the operands and consumes one level.
+func (c *CKKSEvaluator) Multiply(ct1, ct2 *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	ct1, err := c.refresh(ct1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if ct2, err = c.refresh(ct2, 1); err != nil {
+		return nil, err
+	}
 
 	// Perform the multiplication
-	c.Evaluator.MulRelin(ct1, ct2, result)
+	result := c.Evaluator.MulRelinNew(ct1, ct2)
 
 	// Rescale the result to maintain the correct scale
-	c.Evaluator.Rescale(result, c.Scale, result)
-
-	return result
+	if err := c.rescale(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // MultiplyPlain multiplies a ciphertext by a plaintext and returns the result
-func (c *CKKSHelper) MultiplyPlain(ct *rlwe.Ciphertext, value float64) *rlwe.Ciphertext {
-	// Encode the value into a plaintext
-	values := []complex128{complex(value, 0)} // Single value in slot
-	plaintext := c.Encoder.EncodeNew(values, ct.Level(), c.Scale, c.LogSlots)
+// It consumes one level.
+func (c *CKKSEvaluator) MultiplyPlain(ct *rlwe.Ciphertext, value float64) (*rlwe.Ciphertext, error) {
+	ct, err := c.refresh(ct, 1)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create a new ciphertext to store the result
-	result := ckks.NewCiphertext(c.Params, 1, ct.Level())
+	// Encode the value into a plaintext
+	plaintext := c.Encoder.EncodeNew(fillSlots([]float64{value}, c.LogSlots), ct.Level(), c.Scale, c.LogSlots)
 
 	// Perform the multiplication
-	c.Evaluator.Mul(ct, plaintext, result)
+	result := c.Evaluator.MulNew(ct, plaintext)
 
 	// Rescale the result to maintain the correct scale
-	c.Evaluator.Rescale(result, c.Scale, result)
+	if err := c.rescale(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// multByConstToScale multiplies a ciphertext by a public constant and rescales it, encoding the constant so that
+// the result lands exactly on targetScale. It consumes one level and lets terms with drifted scales be added exactly.
+func (c *CKKSEvaluator) multByConstToScale(ct *rlwe.Ciphertext, constant float64, targetScale rlwe.Scale) (*rlwe.Ciphertext, error) {
+	ct, err := c.refresh(ct, 1)
+	if err != nil {
+		return nil, err
+	}
+	return c.scaleTo(ct, constant, targetScale)
+}
+
+// scaleTo is multByConstToScale without the depth check, for ciphertexts known to have a level left
+func (c *CKKSEvaluator) scaleTo(ct *rlwe.Ciphertext, constant float64, targetScale rlwe.Scale) (*rlwe.Ciphertext, error) {
+	// The constant is scaled by targetScale * q / ct.Scale, so that dividing by q yields targetScale
+	q := float64(c.Params.RingQ().Modulus[ct.Level()])
+	scaledConstant := int64(math.Round(constant * targetScale.Float64() * q / ct.Scale.Float64()))
+
+	result := c.Evaluator.MultByConstNew(ct, scaledConstant)
+	result.Scale = targetScale.Mul(rlwe.NewScale(q))
+	if err := c.Evaluator.Rescale(result, targetScale, result); err != nil {
+		return nil, err
+	}
+	result.Scale = targetScale
 
-	return result
+	return result, nil
 }
 
 // DivideByPlain divides a ciphertext by a plaintext value
-func (c *CKKSHelper) DivideByPlain(ct *rlwe.Ciphertext, value float64) *rlwe.Ciphertext {
+func (c *CKKSEvaluator) DivideByPlain(ct *rlwe.Ciphertext, value float64) (*rlwe.Ciphertext, error) {
+	if value == 0 {
+		return nil, errors.New("division by zero")
+	}
+
 	// Compute the reciprocal of the plaintext value
 	reciprocal := 1.0 / value
 
@@ -165,156 +326,131 @@ func (c *CKKSHelper) DivideByPlain(ct *rlwe.Ciphertext, value float64) *rlwe.Cip
 	return c.MultiplyPlain(ct, reciprocal)
 }
 
-// Divide divides two ciphertexts (ct1 / ct2)
-func (c *CKKSHelper) Divide(ct1, ct2 *rlwe.Ciphertext) *rlwe.Ciphertext {
-	// Decrypt ct2 to get the divisor value
-	divisor := c.Decrypt(ct2)
-
-	// Compute the reciprocal of the divisor
-	reciprocal := 1.0 / divisor
-
-	// Multiply ct1 by the reciprocal
-	return c.MultiplyPlain(ct1, reciprocal)
-}
-
 // //////////////////////////////////// CREDIT EVALUATION ////////////////////////////////////////////
 
 const MinSalary = 10 * 1000 * 1000
 const MinAge = 18
 
+// MaxSalary and MaxAge bound the declared input ranges of the encrypted preselection comparisons
+// The comparison cannot tell apart values closer to the threshold than a fraction of the range, so the salary range
+// is kept to realistic salaries; larger ones must be capped at MaxSalary before encryption, which keeps the decision.
+const MaxSalary = 200 * 1000 * 1000
+const MaxAge = 120
+
+// SalaryResolution is the distance to MinSalary from which the encrypted salary gate of PreselectionScoringPolicy
+// agrees with the plaintext preselection; salaries closer to MinSalary get an undecided gate
+const SalaryResolution = 100 * 1000
+
 const MaxCreditScore = 850
 const MinCreditScore = 300
 const MinDTI = 0.01
+const MaxDTI = 1.0
+
+// MaxInputDTI bounds the declared input range of the encrypted DTI clamp, which brings DTIs into [MinDTI, MaxDTI]
+// before they are inverted; larger DTIs must be capped at MaxInputDTI before encryption
+const MaxInputDTI = 2.0
+
+// DTIResolution is the distance to MinDTI and MaxDTI from which the DTI clamp of FullScoringPolicy is decided over
+// [0, MaxInputDTI]; such DTIs land within SignPrecision times that distance of the plaintext clamp, while DTIs closer
+// to a bound are moved only part of the way to it
+const DTIResolution = 0.001
 
 const W0 = 0.5
 const W1 = 0.5
 
-// CreditEvaluation evaluates credit eligibility using encrypted inputs
-func (c *CKKSHelper) CreditEvaluation(helper *CKKSHelper, ageCiphertext *rlwe.Ciphertext, salaryCiphertext *rlwe.Ciphertext, creditScoreCiphertext *rlwe.Ciphertext, dtiCiphertext *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
-	// Check preselection (age and salary)
-	//preselectionResult := satisfyPreselection(helper, ageCiphertext, salaryCiphertext)
+// ErrPreselectionInputs is returned when only one of the age and salary is given to CreditEvaluation
+var ErrPreselectionInputs = errors.New("preselection needs both the age and the salary")
 
-	// If preselection fails, return an invalid result
-	//if helper.Decrypt(preselectionResult) == 0 {
-	//	return helper.EncryptPu(-1), nil
-	//}
+// CreditEvaluation evaluates credit eligibility using encrypted inputs
+// It only needs the evaluation keys, so it can run on a party that is unable to decrypt.
+// When the age and salary are given, applicants failing the preselection get -1 like in the plaintext evaluation;
+// this needs a policy with Preselection enabled. Both may be nil to only compute the score.
+// When the policy enables the sigmoid, the result is the probability instead of the score.
+func (c *CKKSEvaluator) CreditEvaluation(ageCiphertext *rlwe.Ciphertext, salaryCiphertext *rlwe.Ciphertext, creditScoreCiphertext *rlwe.Ciphertext, dtiCiphertext *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	// Make sure the whole circuit fits in the levels left, instead of failing halfway through
+	if err := c.checkCircuitDepth(c.Policy.Depth(), ageCiphertext, salaryCiphertext, creditScoreCiphertext, dtiCiphertext); err != nil {
+		return nil, err
+	}
 
 	// Calculate the credit score
-	scoreCiphertext := c.calcScore(helper, creditScoreCiphertext, dtiCiphertext)
+	scoreCiphertext, err := c.calcScore(creditScoreCiphertext, dtiCiphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	if ageCiphertext == nil && salaryCiphertext == nil {
+		return c.applySigmoid(scoreCiphertext)
+	}
+
+	// Check preselection (age and salary)
+	preselectionResult, err := c.satisfyPreselection(ageCiphertext, salaryCiphertext)
+	if err != nil {
+		return nil, err
+	}
 
-	// Apply sigmoid to the score
-	//resultCiphertext := sigmoid(helper, scoreCiphertext)
+	// Fold the 0/1 gate into the score: gate * (score + 1) - 1 is the score when the gate is 1, and -1 otherwise
+	resultCiphertext, err := c.Multiply(preselectionResult, c.Evaluator.AddConstNew(scoreCiphertext, 1))
+	if err != nil {
+		return nil, err
+	}
+	c.Evaluator.AddConst(resultCiphertext, -1, resultCiphertext)
 
-	return scoreCiphertext, nil
+	return c.applySigmoid(resultCiphertext)
 }
 
-// satisfyPreselection checks if age and salary meet the minimum requirements
-//func (c *CKKSHelper) satisfyPreselection(helper *CKKSHelper, ageCiphertext *rlwe.Ciphertext, salaryCiphertext *rlwe.Ciphertext) *rlwe.Ciphertext {
-//	// Encrypt the minimum age and salary
-//	minAgeCiphertext := helper.EncryptPu(float64(MinAge))
-//	minSalaryCiphertext := helper.EncryptPu(float64(MinSalary))
-//
-//	// Compare age and salary with minimums
-//	ageCheck := helper.Evaluator.SubNew(ageCiphertext, minAgeCiphertext)
-//	salaryCheck := helper.Evaluator.SubNew(salaryCiphertext, minSalaryCiphertext)
-//
-//	// Check if both conditions are met (age > MinAge AND salary > MinSalary)
-//	ageCheck = helper.Evaluator.IsGreaterThan(ageCheck, helper.EncryptPu(0))
-//	salaryCheck = helper.Evaluator.IsGreaterThan(salaryCheck, helper.EncryptPu(0))
-//
-//	// Combine results using multiplication (logical AND)
-//	result := helper.Evaluator.MulRelinNew(ageCheck, salaryCheck)
-//	helper.Evaluator.Rescale(result, helper.Scale, result)
-//
-//	return result
-//}
+// applySigmoid turns the score into a probability when the policy enables the sigmoid
+func (c *CKKSEvaluator) applySigmoid(scoreCiphertext *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	if !c.Policy.Sigmoid.Enabled() {
+		return scoreCiphertext, nil
+	}
+	return c.Sigmoid(scoreCiphertext, c.Policy.Sigmoid)
+}
 
-// calcScore calculates the credit score using normalized credit score and DTI
-func (c *CKKSHelper) calcScore(helper *CKKSHelper, creditScoreCiphertext *rlwe.Ciphertext, dtiCiphertext *rlwe.Ciphertext) *rlwe.Ciphertext {
-	// Normalize credit score
-	minCreditScoreCiphertext := helper.EncryptPu(MinCreditScore)
-	maxCreditScoreCiphertext := helper.EncryptPu(MaxCreditScore)
-	normalizedCreditScore := helper.Evaluator.SubNew(creditScoreCiphertext, minCreditScoreCiphertext)
-	normalizedCreditScore = helper.Divide(normalizedCreditScore, helper.Evaluator.SubNew(maxCreditScoreCiphertext, minCreditScoreCiphertext))
+// satisfyPreselection returns an encrypted gate, 1 when age > MinAge and salary > MinSalary and 0 otherwise
+// Ages are whole years, so the age threshold lies halfway to the first accepted age. Salaries are compared to
+// MinSalary itself and are only decided from SalaryResolution away from it.
+func (c *CKKSEvaluator) satisfyPreselection(ageCiphertext *rlwe.Ciphertext, salaryCiphertext *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	if ageCiphertext == nil || salaryCiphertext == nil {
+		return nil, ErrPreselectionInputs
+	}
 
-	// Normalize DTI (ensure DTI is not too small)
-	//minDTICiphertext := helper.EncryptPu(MinDTI)
-	//dtiCiphertext = helper.Evaluator.MaxNew(dtiCiphertext, minDTICiphertext)
+	ageGate, err := c.GreaterThan(ageCiphertext, MinAge+0.5, 0, MaxAge, c.Policy.Preselection)
+	if err != nil {
+		return nil, fmt.Errorf("age preselection: %w", err)
+	}
 
-	// Calculate the score: (W0 * normalizedCreditScore) + (W1 * (1 / dti))
-	w0Ciphertext := helper.EncryptPu(W0)
-	w1Ciphertext := helper.EncryptPu(W1)
+	salaryGate, err := c.GreaterThan(salaryCiphertext, MinSalary, 0, MaxSalary, c.Policy.Preselection)
+	if err != nil {
+		return nil, fmt.Errorf("salary preselection: %w", err)
+	}
 
-	term1 := helper.Evaluator.MulRelinNew(normalizedCreditScore, w0Ciphertext)
-	fmt.Println("1", c.Decrypt(term1))
-	helper.Evaluator.Rescale(term1, helper.Scale, term1)
-	fmt.Println("2", c.Decrypt(term1))
+	// Both conditions must hold
+	return c.Multiply(ageGate, salaryGate)
+}
 
-	term2, _ := helper.Evaluator.InverseNew(dtiCiphertext, 5)
-	term2 = helper.Evaluator.MulRelinNew(term2, w1Ciphertext)
-	fmt.Println("3", c.Decrypt(term2))
-	helper.Evaluator.Rescale(term2, helper.Scale, term2)
-	fmt.Println("4", c.Decrypt(term2))
+// calcScore calculates the credit score using normalized credit score and DTI
+func (c *CKKSEvaluator) calcScore(creditScoreCiphertext *rlwe.Ciphertext, dtiCiphertext *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	// Normalize DTI (ensure DTI is not too small, nor too large for the inverse to converge)
+	if c.Policy.DTIClamp.Enabled() {
+		var err error
+		if dtiCiphertext, err = c.Clamp(dtiCiphertext, MinDTI, MaxDTI, 0, MaxInputDTI, c.Policy.DTIClamp); err != nil {
+			return nil, fmt.Errorf("DTI clamp: %w", err)
+		}
+	}
 
-	scoreCiphertext := helper.Add(term1, term2)
-	fmt.Println("5", c.Decrypt(scoreCiphertext))
+	// Calculate the score: (W0 * normalizedCreditScore) + (W1 * (1 / dti))
+	// W1 / dti is computed in a single encrypted division, without decrypting anything
+	term2, err := c.ScaledInverse(dtiCiphertext, W1, MinDTI, MaxDTI, c.Policy.DTIInverseIterations)
+	if err != nil {
+		return nil, err
+	}
 
-	return scoreCiphertext
-}
+	// Normalize and weight the credit score with a single public constant, landing on the scale of term2
+	term1 := c.Evaluator.AddConstNew(creditScoreCiphertext, -MinCreditScore)
+	term1, err = c.multByConstToScale(term1, W0/(MaxCreditScore-MinCreditScore), term2.Scale)
+	if err != nil {
+		return nil, err
+	}
 
-// sigmoid applies the sigmoid function to the input ciphertext
-func (c *CKKSHelper) sigmoid(helper *CKKSHelper, xCiphertext *rlwe.Ciphertext) *rlwe.Ciphertext {
-	// Compute sigmoid(x) = 1 / (1 + exp(-x))
-
-	// Step 1: Negate x
-	negXCiphertext := helper.Evaluator.NegNew(xCiphertext)
-
-	// Step 2: Approximate exp(-x) using Taylor series:
-	// exp(-x) ≈ 1 - x + x^2/2 - x^3/6 + x^4/24 - x^5/120
-
-	x2 := helper.Multiply(negXCiphertext, negXCiphertext)
-	fmt.Println(c.Decrypt(negXCiphertext))
-	fmt.Println(c.Decrypt(x2))
-	x3 := helper.Multiply(x2, negXCiphertext)
-	fmt.Println(c.Decrypt(x3))
-	//x4 := helper.Multiply(x3, negXCiphertext)
-	//x5 := helper.Multiply(x4, negXCiphertext)
-
-	term0 := helper.EncryptPu(1.0) // 1
-	fmt.Println("term0", c.Decrypt(term0))
-
-	term1 := helper.MultiplyPlain(negXCiphertext, 1.0) // -x
-	fmt.Println("term1", c.Decrypt(term1))
-
-	term2 := helper.MultiplyPlain(x2, 1.0/2.0) // x^2 / 2
-	fmt.Println("term2", c.Decrypt(term2))
-
-	term3 := helper.MultiplyPlain(x3, 1.0/6.0) // -x^3 / 6
-	fmt.Println("term3", c.Decrypt(term3))
-
-	//term4 := helper.MultiplyPlain(x4, 1.0/24.0) // x^4 / 24
-	//fmt.Println("term4", c.Decrypt(term4))
-	//
-	//term5 := helper.MultiplyPlain(x5, -1.0/120.0) // -x^5 / 120
-	//fmt.Println("term5", c.Decrypt(term5))
-
-	// Sum up the terms for exp(-x)
-	expNegXCiphertext := helper.Add(term0, term1)
-	fmt.Println(c.Decrypt(expNegXCiphertext))
-	expNegXCiphertext = helper.Add(expNegXCiphertext, term2)
-	fmt.Println(c.Decrypt(expNegXCiphertext))
-	expNegXCiphertext = helper.Add(expNegXCiphertext, term3)
-	fmt.Println(c.Decrypt(expNegXCiphertext))
-	//expNegXCiphertext = helper.Evaluator.AddNew(expNegXCiphertext, term4)
-	//fmt.Println(c.Decrypt(expNegXCiphertext))
-	//expNegXCiphertext = helper.Evaluator.AddNew(expNegXCiphertext, term5)
-	//fmt.Println(c.Decrypt(expNegXCiphertext))
-
-	// Step 3: Compute 1 + exp(-x)
-	result := helper.AddWithPlain(expNegXCiphertext, 1)
-	fmt.Println("result", c.Decrypt(result))
-	// Step 4: Inverse of the denominator: 1 / (1 + exp(-x))
-	//result, _ := helper.Evaluator.InverseNew(denominator, 5) // use 5 as the precision of the approximation
-
-	return result
+	return c.Add(term1, term2)
 }
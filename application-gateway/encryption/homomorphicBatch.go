@@ -0,0 +1,143 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+var (
+	ErrTooManyValues = errors.New("more values than ciphertext slots")
+	ErrEmptyBatch    = errors.New("empty batch")
+	ErrBatchMismatch = errors.New("batched inputs have different lengths")
+)
+
+// EncryptVectorPu encodes up to 2^LogSlots values into the slots of a single ciphertext and encrypts it
+// Unused slots repeat the values, so every slot stays within the range of the encrypted inputs.
+func (c *CKKSEncryptor) EncryptVectorPu(values []float64) (*rlwe.Ciphertext, error) {
+	if err := checkVector(values, c.LogSlots); err != nil {
+		return nil, err
+	}
+
+	// Encode the values into a plaintext
+	plaintext := c.Encoder.EncodeNew(fillSlots(values, c.LogSlots), c.Params.MaxLevel(), c.Scale, c.LogSlots)
+
+	// Encrypt the plaintext
+	return c.EncryptorPu.EncryptNew(plaintext), nil
+}
+
+// EncryptVectorPr encodes up to 2^LogSlots values into the slots of a single ciphertext and encrypts it
+// with the secret key
+func (c *CKKSKeyOwner) EncryptVectorPr(values []float64) (*rlwe.Ciphertext, error) {
+	if err := checkVector(values, c.LogSlots); err != nil {
+		return nil, err
+	}
+
+	// Encode the values into a plaintext
+	plaintext := c.Encoder.EncodeNew(fillSlots(values, c.LogSlots), c.Params.MaxLevel(), c.Scale, c.LogSlots)
+
+	// Encrypt the plaintext
+	return c.EncryptorPr.EncryptNew(plaintext), nil
+}
+
+// DecryptVector decrypts a ciphertext and returns the real part of its first n slots
+func (c *CKKSKeyOwner) DecryptVector(ciphertext *rlwe.Ciphertext, n int) []float64 {
+	// Decrypt the ciphertext into a plaintext
+	plaintext := c.Decryptor.DecryptNew(ciphertext)
+
+	// Decode the plaintext to retrieve the values
+	decoded := c.Encoder.Decode(plaintext, c.LogSlots)
+	if n > len(decoded) {
+		n = len(decoded)
+	}
+
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = real(decoded[i])
+	}
+	return values
+}
+
+// EncryptBatchPu splits any number of values into chunks of 2^LogSlots and encrypts each chunk into its own ciphertext
+func (c *CKKSEncryptor) EncryptBatchPu(values []float64) ([]*rlwe.Ciphertext, error) {
+	if len(values) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	slots := 1 << c.LogSlots
+	ciphertexts := make([]*rlwe.Ciphertext, 0, (len(values)+slots-1)/slots)
+	for start := 0; start < len(values); start += slots {
+		end := start + slots
+		if end > len(values) {
+			end = len(values)
+		}
+
+		ciphertext, err := c.EncryptVectorPu(values[start:end])
+		if err != nil {
+			return nil, err
+		}
+		ciphertexts = append(ciphertexts, ciphertext)
+	}
+	return ciphertexts, nil
+}
+
+// DecryptBatch decrypts the ciphertexts produced from a batch of n values and concatenates their slots
+func (c *CKKSKeyOwner) DecryptBatch(ciphertexts []*rlwe.Ciphertext, n int) []float64 {
+	slots := 1 << c.LogSlots
+	values := make([]float64, 0, n)
+	for _, ciphertext := range ciphertexts {
+		remaining := n - len(values)
+		if remaining <= 0 {
+			break
+		}
+		if remaining > slots {
+			remaining = slots
+		}
+		values = append(values, c.DecryptVector(ciphertext, remaining)...)
+	}
+	return values
+}
+
+// CreditEvaluationBatch scores batched applicants, one CreditEvaluation circuit per ciphertext of the batch
+// Every slot holds a different applicant, so a single evaluation scores up to 2^LogSlots applicants at once.
+// The age and salary batches may be nil while the preselection is not evaluated.
+func (c *CKKSEvaluator) CreditEvaluationBatch(ageCiphertexts, salaryCiphertexts, creditScoreCiphertexts, dtiCiphertexts []*rlwe.Ciphertext) ([]*rlwe.Ciphertext, error) {
+	if len(creditScoreCiphertexts) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(dtiCiphertexts) != len(creditScoreCiphertexts) ||
+		(ageCiphertexts != nil && len(ageCiphertexts) != len(creditScoreCiphertexts)) ||
+		(salaryCiphertexts != nil && len(salaryCiphertexts) != len(creditScoreCiphertexts)) {
+		return nil, ErrBatchMismatch
+	}
+
+	results := make([]*rlwe.Ciphertext, len(creditScoreCiphertexts))
+	for i := range creditScoreCiphertexts {
+		var age, salary *rlwe.Ciphertext
+		if ageCiphertexts != nil {
+			age = ageCiphertexts[i]
+		}
+		if salaryCiphertexts != nil {
+			salary = salaryCiphertexts[i]
+		}
+
+		result, err := c.CreditEvaluation(age, salary, creditScoreCiphertexts[i], dtiCiphertexts[i])
+		if err != nil {
+			return nil, fmt.Errorf("batch %d: %w", i, err)
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// checkVector ensures that a vector fits into the slots of a single ciphertext
+func checkVector(values []float64, logSlots int) error {
+	if len(values) == 0 {
+		return ErrEmptyBatch
+	}
+	if len(values) > 1<<logSlots {
+		return fmt.Errorf("%w: %d values for %d slots", ErrTooManyValues, len(values), 1<<logSlots)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package encryption
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestVectorEncryption ensures that every slot of a vector survives encryption and decryption
+func TestVectorEncryption(t *testing.T) {
+	helper := NewCKKSHelper()
+
+	values := []float64{1.5, -2.25, 3, 42}
+	ciphertext, err := helper.EncryptVectorPu(values)
+	if err != nil {
+		t.Fatalf("EncryptVectorPu failed: %v", err)
+	}
+
+	decrypted := helper.DecryptVector(ciphertext, len(values))
+	for i := range values {
+		if math.Abs(decrypted[i]-values[i]) > precision {
+			t.Errorf("slot %d: got %f, expected %f", i, decrypted[i], values[i])
+		}
+	}
+
+	if _, err = helper.EncryptVectorPu(make([]float64, (1<<helper.LogSlots)+1)); !errors.Is(err, ErrTooManyValues) {
+		t.Errorf("oversized vector returned %v, expected %v", err, ErrTooManyValues)
+	}
+}
+
+// TestCreditEvaluationBatch ensures that a batch spanning several ciphertexts scores every applicant
+func TestCreditEvaluationBatch(t *testing.T) {
+	helper := NewCKKSHelper()
+	random := rand.New(rand.NewSource(1))
+
+	// One full ciphertext and a partial one
+	n := (1 << helper.LogSlots) + 100
+	creditScores := make([]float64, n)
+	dtis := make([]float64, n)
+	for i := 0; i < n; i++ {
+		creditScores[i] = float64(random.Intn(551) + 300)
+		dtis[i] = random.Float64()*0.95 + 0.05
+	}
+
+	creditScoreCiphertexts, err := helper.EncryptBatchPu(creditScores)
+	if err != nil {
+		t.Fatalf("EncryptBatchPu failed: %v", err)
+	}
+	dtiCiphertexts, err := helper.EncryptBatchPu(dtis)
+	if err != nil {
+		t.Fatalf("EncryptBatchPu failed: %v", err)
+	}
+	if len(creditScoreCiphertexts) != 2 {
+		t.Fatalf("got %d ciphertexts, expected 2", len(creditScoreCiphertexts))
+	}
+
+	resultCiphertexts, err := helper.CreditEvaluationBatch(nil, nil, creditScoreCiphertexts, dtiCiphertexts)
+	if err != nil {
+		t.Fatalf("CreditEvaluationBatch failed: %v", err)
+	}
+
+	results := helper.DecryptBatch(resultCiphertexts, n)
+	if len(results) != n {
+		t.Fatalf("got %d results, expected %d", len(results), n)
+	}
+	for i := range results {
+		expected := W0*(creditScores[i]-MinCreditScore)/(MaxCreditScore-MinCreditScore) + W1/dtis[i]
+		if math.Abs(results[i]-expected) > 0.01 {
+			t.Fatalf("applicant %d: got %f, expected %f", i, results[i], expected)
+		}
+	}
+
+	if _, err = helper.CreditEvaluationBatch(nil, nil, creditScoreCiphertexts, dtiCiphertexts[:1]); !errors.Is(err, ErrBatchMismatch) {
+		t.Errorf("mismatched batch returned %v, expected %v", err, ErrBatchMismatch)
+	}
+}
@@ -0,0 +1,505 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/ckks/bootstrapping"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Named bootstrapping parameter sets selectable through BootstrappingOptions.ParameterSet
+// They all use a sparse secret with 192 non-zero coefficients and leave 9 (N16) or 3 (N15) levels between bootstraps.
+const (
+	N16QP1546H192H32 = "N16QP1546H192H32"
+	N16QP1547H192H32 = "N16QP1547H192H32"
+	N16QP1553H192H32 = "N16QP1553H192H32"
+	N15QP768H192H32  = "N15QP768H192H32"
+
+	DefaultBootstrappingSet = N16QP1546H192H32
+)
+
+// DefaultMessageBound is the message bound used when none is given
+// It covers every intermediate value of CreditEvaluation, whose scores stay below W0 + W1/MinDTI.
+const DefaultMessageBound = 64
+
+// BootstrappingKeyKind is the kind of the key file holding the bootstrapping evaluation keys
+const BootstrappingKeyKind = "ckks-bootstrapping-keys"
+
+// BootstrappingKeyFile is the file name used by ExportBootstrappingKeys inside a key directory
+const BootstrappingKeyFile = "bootstrapping.key"
+
+var ErrBootstrappingDisabled = errors.New("evaluator has no bootstrapping keys")
+
+// bootstrappingParameterSet pairs the CKKS parameters of a bootstrapping set with the parameters of its circuit
+type bootstrappingParameterSet struct {
+	Scheme        ckks.ParametersLiteral
+	Bootstrapping bootstrapping.Parameters
+}
+
+// BootstrappingParameterSets maps the names of the selectable bootstrapping parameter sets to their literals
+var BootstrappingParameterSets = map[string]bootstrappingParameterSet{
+	N16QP1546H192H32: {bootstrapping.N16QP1546H192H32.SchemeParams, bootstrapping.N16QP1546H192H32.BootstrappingParams},
+	N16QP1547H192H32: {bootstrapping.N16QP1547H192H32.SchemeParams, bootstrapping.N16QP1547H192H32.BootstrappingParams},
+	N16QP1553H192H32: {bootstrapping.N16QP1553H192H32.SchemeParams, bootstrapping.N16QP1553H192H32.BootstrappingParams},
+	N15QP768H192H32:  {bootstrapping.N15QP768H192H32.SchemeParams, bootstrapping.N15QP768H192H32.BootstrappingParams},
+}
+
+// BootstrappingOptions selects the parameters, the scoring policy and the refresh strategy of a bootstrapping helper
+// The zero value selects DefaultBootstrappingSet and FullScoringPolicy.
+type BootstrappingOptions struct {
+	// ParameterSet is the name of one of the BootstrappingParameterSets
+	ParameterSet string
+
+	// LogN overrides the ring degree of the parameter set; smaller rings are NOT secure and only meant for tests
+	LogN int
+
+	// LogSlots overrides the number of slots, which drives the number of rotation keys and the bootstrapping time
+	LogSlots int
+
+	// Threshold is the number of levels a ciphertext must keep after each operation, at least 1 since
+	// the bootstrapping itself uses one level to match its input scale
+	Threshold int
+
+	// MessageBound bounds the magnitude of the values that get bootstrapped, rounded up to a power of two
+	MessageBound float64
+
+	// Policy is the scoring circuit evaluated by CreditEvaluation
+	Policy ScoringPolicy
+}
+
+// bootstrappingState holds what an evaluator needs to bootstrap its ciphertexts
+type bootstrappingState struct {
+	bootstrapper *bootstrapping.Bootstrapper
+	parameters   bootstrapping.Parameters
+	keys         bootstrapping.EvaluationKeys
+
+	// maxLevel is the level of a bootstrapped ciphertext, the moduli above it are reserved for the bootstrapping
+	maxLevel     int
+	threshold    int
+	messageBound float64
+}
+
+// Parameters resolves the options into the CKKS and bootstrapping parameters
+func (o BootstrappingOptions) Parameters() (ckks.Parameters, bootstrapping.Parameters, error) {
+	name := o.ParameterSet
+	if name == "" {
+		name = DefaultBootstrappingSet
+	}
+
+	set, ok := BootstrappingParameterSets[name]
+	if !ok {
+		return ckks.Parameters{}, bootstrapping.Parameters{}, fmt.Errorf("%w: %s", ErrUnknownParameterSet, name)
+	}
+
+	literal := set.Scheme
+	if o.LogN != 0 {
+		literal.LogN = o.LogN
+		literal.LogSlots = min(literal.LogSlots, o.LogN-1)
+	}
+	if o.LogSlots != 0 {
+		literal.LogSlots = o.LogSlots
+	}
+
+	params, err := ckks.NewParametersFromLiteral(literal)
+	if err != nil {
+		return ckks.Parameters{}, bootstrapping.Parameters{}, err
+	}
+	return params, set.Bootstrapping, nil
+}
+
+// threshold returns the number of levels kept after each operation, never below 1
+func (o BootstrappingOptions) threshold() int {
+	return max(o.Threshold, 1)
+}
+
+// messageBound returns the smallest power of two above the message bound of the options
+func (o BootstrappingOptions) messageBound() float64 {
+	bound := o.MessageBound
+	if bound <= 0 {
+		bound = DefaultMessageBound
+	}
+	return math.Pow(2, math.Ceil(math.Log2(bound)))
+}
+
+// policy returns the scoring policy of the options, falling back on FullScoringPolicy
+func (o BootstrappingOptions) policy() ScoringPolicy {
+	if o.Policy == (ScoringPolicy{}) {
+		return FullScoringPolicy()
+	}
+	return o.Policy
+}
+
+// resolve returns the parameters and scoring policy of the options, checking that every step of the policy
+// fits between two bootstraps
+func (o BootstrappingOptions) resolve() (ckks.Parameters, bootstrapping.Parameters, ScoringPolicy, error) {
+	params, btpParams, err := o.Parameters()
+	if err != nil {
+		return ckks.Parameters{}, bootstrapping.Parameters{}, ScoringPolicy{}, err
+	}
+
+	policy := o.policy()
+	if depth, levels := policy.StepDepth()+o.threshold(), bootstrappedLevel(btpParams); depth > levels {
+		return ckks.Parameters{}, bootstrapping.Parameters{}, ScoringPolicy{}, fmt.Errorf("%w: policy steps need %d levels, bootstrapping provides %d", ErrInsufficientDepth, depth, levels)
+	}
+
+	return params, btpParams, policy, nil
+}
+
+// bootstrappedLevel returns the level of the ciphertexts output by the bootstrapping circuit
+func bootstrappedLevel(btpParams bootstrapping.Parameters) int {
+	return btpParams.SlotsToCoeffsParameters.LevelStart - btpParams.SlotsToCoeffsParameters.Depth(true)
+}
+
+// NewBootstrappingHelper initializes a CKKSHelper able to bootstrap, with a freshly generated key set
+// Generating the rotation keys takes a while and several hundred megabytes.
+func NewBootstrappingHelper(opts BootstrappingOptions) (*CKKSHelper, error) {
+	params, btpParams, policy, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate keys, the bootstrapping keys include the relinearization key
+	kgen := ckks.NewKeyGenerator(params)
+	sk, pk := kgen.GenKeyPair()
+	keys := bootstrapping.GenEvaluationKeys(btpParams, params, sk)
+
+	helper, err := newCKKSHelper(params, policy, sk, pk, keys.Rlk)
+	if err != nil {
+		return nil, err
+	}
+	if err = helper.EnableBootstrapping(btpParams, keys, opts.threshold(), opts.messageBound()); err != nil {
+		return nil, err
+	}
+	return helper, nil
+}
+
+// EnableBootstrapping lets the evaluator refresh ciphertexts that would fall below threshold levels
+// messageBound must be a power of two bounding the magnitude of the values that get bootstrapped.
+func (c *CKKSEvaluator) EnableBootstrapping(btpParams bootstrapping.Parameters, keys bootstrapping.EvaluationKeys, threshold int, messageBound float64) error {
+	if threshold < 1 {
+		return fmt.Errorf("bootstrapping threshold must be at least 1, got %d", threshold)
+	}
+	if exponent := math.Log2(messageBound); messageBound < 1 || exponent != math.Trunc(exponent) {
+		return fmt.Errorf("message bound must be a power of two, got %g", messageBound)
+	}
+
+	bootstrapper, err := bootstrapping.NewBootstrapper(c.Params, btpParams, keys)
+	if err != nil {
+		return fmt.Errorf("failed to create bootstrapper: %w", err)
+	}
+
+	// The evaluator now needs the rotation keys as well
+	c.Evaluator = ckks.NewEvaluator(c.Params, keys.EvaluationKey)
+	c.btp = &bootstrappingState{
+		bootstrapper: bootstrapper,
+		parameters:   btpParams,
+		keys:         keys,
+		maxLevel:     bootstrappedLevel(btpParams),
+		threshold:    threshold,
+		messageBound: messageBound,
+	}
+	return nil
+}
+
+// CanBootstrap reports whether the evaluator has bootstrapping keys
+func (c *CKKSEvaluator) CanBootstrap() bool {
+	return c.btp != nil
+}
+
+// Bootstrap refreshes a ciphertext, returning it at the highest level available after bootstrapping
+// The ciphertext needs at least one level left, and its values must not exceed the message bound.
+func (c *CKKSEvaluator) Bootstrap(ct *rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	if c.btp == nil {
+		return nil, ErrBootstrappingDisabled
+	}
+	if ct.Level() < 1 {
+		return nil, fmt.Errorf("%w: bootstrapping needs 1 level to match the scale", ErrDepthExhausted)
+	}
+
+	// The bootstrapping is only precise for values of magnitude about 1, so the values are divided by the message
+	// bound, landing exactly on the default scale: at level 0, the bootstrapper expects a power of two scale
+	input, err := c.scaleTo(c.Evaluator.DropLevelNew(ct, ct.Level()-1), 1/c.btp.messageBound, c.Params.DefaultScale())
+	if err != nil {
+		return nil, err
+	}
+
+	// The values are multiplied back by an integer constant, which consumes no level
+	result := c.btp.bootstrapper.Bootstrap(input)
+	if c.btp.messageBound > 1 {
+		c.Evaluator.MultByConst(result, int64(c.btp.messageBound), result)
+	}
+	return result, nil
+}
+
+// checkCircuitDepth checks that the ciphertexts can go through a whole circuit of the given depth,
+// which is always possible when the evaluator can bootstrap
+func (c *CKKSEvaluator) checkCircuitDepth(depth int, cts ...*rlwe.Ciphertext) error {
+	if c.btp != nil {
+		return nil
+	}
+	return c.CheckDepth(depth, cts...)
+}
+
+// refresh prepares a ciphertext for a step consuming depth levels
+// Without bootstrapping it only checks the levels left. With bootstrapping, ciphertexts above the bootstrapped level
+// are dropped to it, since the moduli above are reserved for the bootstrapping, and ciphertexts that would be left
+// with fewer than the threshold levels are bootstrapped first.
+func (c *CKKSEvaluator) refresh(ct *rlwe.Ciphertext, depth int) (*rlwe.Ciphertext, error) {
+	if c.btp == nil {
+		return ct, c.CheckDepth(depth, ct)
+	}
+
+	if needed := depth + c.btp.threshold; needed > c.btp.maxLevel {
+		return nil, fmt.Errorf("%w: needs %d levels, bootstrapping provides %d", ErrDepthExhausted, needed, c.btp.maxLevel)
+	}
+	if ct.Level() > c.btp.maxLevel {
+		return c.Evaluator.DropLevelNew(ct, ct.Level()-c.btp.maxLevel), nil
+	}
+	if ct.Level() >= depth+c.btp.threshold {
+		return ct, nil
+	}
+	return c.Bootstrap(ct)
+}
+
+// ExportBootstrappingKeys writes the keys of the helper into dir, including the bootstrapping keys when it has them
+// The bootstrapping keys are streamed into a binary file, since they are too large to be held twice in memory.
+func (c *CKKSHelper) ExportBootstrappingKeys(dir string) error {
+	if c.btp == nil {
+		return ErrBootstrappingDisabled
+	}
+	if err := c.ExportKeys(dir); err != nil {
+		return err
+	}
+
+	parts := []encoding.BinaryMarshaler{&c.btp.parameters, c.btp.keys.Rtks, c.btp.keys.SwkDtS, c.btp.keys.SwkStD}
+	return writeKeyParts(filepath.Join(dir, BootstrappingKeyFile), BootstrappingKeyKind, c.Params, parts)
+}
+
+// LoadBootstrappingHelper initializes a bootstrapping helper from keys previously written by ExportBootstrappingKeys
+// opts must select the parameters the keys were generated under
+func LoadBootstrappingHelper(dir string, opts BootstrappingOptions) (*CKKSHelper, error) {
+	params, btpParams, policy, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	sk, err := readSecretKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := readPublicKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := readBootstrappingKeys(dir, params, btpParams)
+	if err != nil {
+		return nil, err
+	}
+
+	helper, err := newCKKSHelper(params, policy, sk, pk, keys.Rlk)
+	if err != nil {
+		return nil, err
+	}
+	if err = helper.EnableBootstrapping(btpParams, keys, opts.threshold(), opts.messageBound()); err != nil {
+		return nil, err
+	}
+	return helper, nil
+}
+
+// LoadBootstrappingEvaluator initializes an evaluator able to bootstrap from the evaluation keys in dir
+// The secret key file is never read, so dir only needs to contain the evaluation keys
+func LoadBootstrappingEvaluator(dir string, opts BootstrappingOptions) (*CKKSEvaluator, error) {
+	params, btpParams, policy, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := readBootstrappingKeys(dir, params, btpParams)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluator := newCKKSEvaluator(params, policy, ckks.NewEncoder(params), keys.Rlk)
+	if err = evaluator.EnableBootstrapping(btpParams, keys, opts.threshold(), opts.messageBound()); err != nil {
+		return nil, err
+	}
+	return evaluator, nil
+}
+
+// readBootstrappingKeys reads the relinearization and bootstrapping keys from dir, refusing keys generated
+// for other bootstrapping parameters
+func readBootstrappingKeys(dir string, params ckks.Parameters, btpParams bootstrapping.Parameters) (bootstrapping.EvaluationKeys, error) {
+	rlk, err := readRelinearizationKey(dir, params)
+	if err != nil {
+		return bootstrapping.EvaluationKeys{}, err
+	}
+
+	var fileParams bootstrapping.Parameters
+	keys := bootstrapping.EvaluationKeys{
+		EvaluationKey: rlwe.EvaluationKey{Rlk: rlk, Rtks: new(rlwe.RotationKeySet)},
+		SwkDtS:        new(rlwe.SwitchingKey),
+		SwkStD:        new(rlwe.SwitchingKey),
+	}
+	parts := []encoding.BinaryUnmarshaler{&fileParams, keys.Rtks, keys.SwkDtS, keys.SwkStD}
+	if err = readKeyParts(filepath.Join(dir, BootstrappingKeyFile), BootstrappingKeyKind, params, parts); err != nil {
+		return bootstrapping.EvaluationKeys{}, err
+	}
+
+	// Parameters without an ephemeral secret have no encapsulation keys
+	if len(keys.SwkDtS.Value) == 0 {
+		keys.SwkDtS = nil
+	}
+	if len(keys.SwkStD.Value) == 0 {
+		keys.SwkStD = nil
+	}
+
+	expected, err := btpParams.MarshalBinary()
+	if err != nil {
+		return bootstrapping.EvaluationKeys{}, err
+	}
+	actual, err := fileParams.MarshalBinary()
+	if err != nil {
+		return bootstrapping.EvaluationKeys{}, err
+	}
+	if !bytes.Equal(expected, actual) {
+		return bootstrapping.EvaluationKeys{}, fmt.Errorf("%w: bootstrapping parameters differ", ErrParamsMismatch)
+	}
+
+	return keys, nil
+}
+
+// writeKeyParts writes a key file made of a JSON header line followed by length-prefixed binary parts
+// Each part is marshaled and written on its own; nil parts are written empty.
+func writeKeyParts(path string, kind string, params ckks.Parameters, parts []encoding.BinaryMarshaler) error {
+	fingerprint, err := ParamsFingerprint(params)
+	if err != nil {
+		return err
+	}
+
+	header, err := json.Marshal(keyFile{
+		Version:     KeyFileVersion,
+		Kind:        kind,
+		Fingerprint: fingerprint,
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if _, err = writer.Write(append(header, '\n')); err != nil {
+		return err
+	}
+
+	for i, part := range parts {
+		var data []byte
+		if part != nil && !isNilKey(part) {
+			if data, err = part.MarshalBinary(); err != nil {
+				return fmt.Errorf("failed to marshal part %d of %s: %w", i, kind, err)
+			}
+		}
+		if err = binary.Write(writer, binary.BigEndian, uint64(len(data))); err != nil {
+			return err
+		}
+		if _, err = writer.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// readKeyParts reads a key file written by writeKeyParts into parts, refusing files of another kind or parameter set
+// Empty parts are left untouched.
+func readKeyParts(path string, kind string, params ckks.Parameters, parts []encoding.BinaryUnmarshaler) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	headerJSON, err := reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("key file %s is malformed: %w", path, err)
+	}
+	remaining := uint64(info.Size()) - uint64(len(headerJSON))
+
+	var header keyFile
+	if err = json.Unmarshal(headerJSON, &header); err != nil {
+		return fmt.Errorf("key file %s is malformed: %w", path, err)
+	}
+	if header.Version != KeyFileVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Version)
+	}
+	if header.Kind != kind {
+		return fmt.Errorf("%w: expected %s, got %s", ErrKeyKindMismatch, kind, header.Kind)
+	}
+
+	fingerprint, err := ParamsFingerprint(params)
+	if err != nil {
+		return err
+	}
+	if header.Fingerprint != fingerprint {
+		return fmt.Errorf("%w: expected %s, got %s", ErrParamsMismatch, fingerprint, header.Fingerprint)
+	}
+
+	for i, part := range parts {
+		var length uint64
+		if err = binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return fmt.Errorf("key file %s is truncated: %w", path, err)
+		}
+		remaining -= 8
+		if length == 0 {
+			continue
+		}
+
+		// The length comes from the file, so it cannot ask for more than the file still holds
+		if length > remaining {
+			return fmt.Errorf("key file %s is truncated: part %d claims %d bytes, %d left", path, i, length, remaining)
+		}
+		remaining -= length
+
+		data := make([]byte, length)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return fmt.Errorf("key file %s is truncated: %w", path, err)
+		}
+		if err = part.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("failed to unmarshal part %d of %s: %w", i, kind, err)
+		}
+	}
+	return nil
+}
+
+// isNilKey reports whether a part holds a nil switching key, which the bootstrapping keys use
+// when the parameters have no ephemeral secret
+func isNilKey(part encoding.BinaryMarshaler) bool {
+	swk, ok := part.(*rlwe.SwitchingKey)
+	return ok && swk == nil
+}
@@ -0,0 +1,168 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// newBootstrappingTestHelper returns a bootstrapping helper on a reduced ring with few slots
+// These parameters are NOT secure, they only keep the key generation and the bootstraps fast enough for tests.
+func newBootstrappingTestHelper(t *testing.T) *CKKSHelper {
+	t.Helper()
+
+	helper, err := NewBootstrappingHelper(BootstrappingOptions{LogN: 13, LogSlots: 6})
+	if err != nil {
+		t.Fatalf("NewBootstrappingHelper failed: %v", err)
+	}
+	return helper
+}
+
+// TestBootstrapping ensures that bootstrapped ciphertexts keep their values and that the whole scoring circuit,
+// which no parameter set can hold, is evaluated end to end. The key generation is shared by the subtests.
+func TestBootstrapping(t *testing.T) {
+	if testing.Short() {
+		t.Skip("bootstrapping keys take a while to generate")
+	}
+	helper := newBootstrappingTestHelper(t)
+
+	t.Run("Bootstrap", func(t *testing.T) {
+		values := []float64{-40, -1, 0.25, 3, 50}
+		ct, err := helper.EncryptVectorPu(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exhausted := helper.Evaluator.DropLevelNew(ct, ct.Level()-1)
+		refreshed, err := helper.Bootstrap(exhausted)
+		if err != nil {
+			t.Fatalf("Bootstrap failed: %v", err)
+		}
+		if refreshed.Level() <= exhausted.Level() {
+			t.Errorf("bootstrapped ciphertext is at level %d, expected more than %d", refreshed.Level(), exhausted.Level())
+		}
+
+		results := helper.DecryptVector(refreshed, len(values))
+		for i, value := range values {
+			if math.Abs(results[i]-value) > 1e-4 {
+				t.Errorf("slot %d: got %f, expected %f", i, results[i], value)
+			}
+		}
+
+		// Bootstrapping needs one level to match the scale
+		if _, err = helper.Bootstrap(helper.Evaluator.DropLevelNew(ct, ct.Level())); !errors.Is(err, ErrDepthExhausted) {
+			t.Errorf("Bootstrap at level 0 returned %v, expected %v", err, ErrDepthExhausted)
+		}
+	})
+
+	t.Run("CreditEvaluation", func(t *testing.T) {
+		ages := []float64{30, 16, 45, 60}
+		salaries := []float64{50 * 1000 * 1000, 40 * 1000 * 1000, 5 * 1000 * 1000, 200 * 1000 * 1000}
+		creditScores := []float64{700, 800, 650, 400}
+		dtis := []float64{0.4, 0.2, 0.3, 0.001}
+
+		var inputs [4]*rlwe.Ciphertext
+		for i, values := range [][]float64{ages, salaries, creditScores, dtis} {
+			var err error
+			if inputs[i], err = helper.EncryptVectorPu(values); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resultCiphertext, err := helper.CreditEvaluation(inputs[0], inputs[1], inputs[2], inputs[3])
+		if err != nil {
+			t.Fatalf("CreditEvaluation failed: %v", err)
+		}
+
+		results := helper.DecryptVector(resultCiphertext, len(ages))
+		for i := range ages {
+			score := -1.0
+			if ages[i] > MinAge && salaries[i] > MinSalary {
+				score = W0*(creditScores[i]-MinCreditScore)/(MaxCreditScore-MinCreditScore) + W1/math.Max(dtis[i], MinDTI)
+			}
+			expected := 1 / (1 + math.Exp(-score))
+			if math.Abs(results[i]-expected) > 0.01 {
+				t.Errorf("applicant %d: got %f, expected %f", i, results[i], expected)
+			}
+		}
+	})
+
+	t.Run("ExportLoad", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := helper.ExportBootstrappingKeys(dir); err != nil {
+			t.Fatalf("ExportBootstrappingKeys failed: %v", err)
+		}
+
+		evaluator, err := LoadBootstrappingEvaluator(dir, BootstrappingOptions{LogN: 13, LogSlots: 6})
+		if err != nil {
+			t.Fatalf("LoadBootstrappingEvaluator failed: %v", err)
+		}
+
+		ct := helper.EncryptPu(2.5)
+		refreshed, err := evaluator.Bootstrap(evaluator.Evaluator.DropLevelNew(ct, ct.Level()-1))
+		if err != nil {
+			t.Fatalf("Bootstrap with loaded keys failed: %v", err)
+		}
+		if result := helper.Decrypt(refreshed); math.Abs(result-2.5) > 1e-4 {
+			t.Errorf("Bootstrap with loaded keys failed. Got %f, expected %f", result, 2.5)
+		}
+
+		// Keys generated for other parameters are refused
+		if _, err = LoadBootstrappingEvaluator(dir, BootstrappingOptions{LogN: 13, LogSlots: 5}); !errors.Is(err, ErrParamsMismatch) {
+			t.Errorf("loading keys under other parameters returned %v, expected %v", err, ErrParamsMismatch)
+		}
+	})
+}
+
+// TestBootstrappingDisabled ensures that helpers without bootstrapping keys refuse to bootstrap
+func TestBootstrappingDisabled(t *testing.T) {
+	helper := NewCKKSHelper()
+	if helper.CanBootstrap() {
+		t.Fatal("helper without bootstrapping keys reports it can bootstrap")
+	}
+	if _, err := helper.Bootstrap(helper.EncryptPu(1)); !errors.Is(err, ErrBootstrappingDisabled) {
+		t.Errorf("Bootstrap returned %v, expected %v", err, ErrBootstrappingDisabled)
+	}
+
+	// The full scoring circuit does not fit between two bootstraps of the smaller parameter set
+	if _, err := NewBootstrappingHelper(BootstrappingOptions{ParameterSet: N15QP768H192H32}); !errors.Is(err, ErrInsufficientDepth) {
+		t.Errorf("NewBootstrappingHelper returned %v, expected %v", err, ErrInsufficientDepth)
+	}
+}
+
+// TestReadKeyPartsOversizedLength ensures that a part length larger than the file is refused before anything is allocated
+func TestReadKeyPartsOversizedLength(t *testing.T) {
+	params := NewCKKSHelper().Params
+	fingerprint, err := ParamsFingerprint(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := json.Marshal(keyFile{Version: KeyFileVersion, Kind: BootstrappingKeyKind, Fingerprint: fingerprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte('\n')
+	_ = binary.Write(&buf, binary.BigEndian, uint64(1)<<62)
+	buf.WriteString("short")
+
+	path := filepath.Join(t.TempDir(), BootstrappingKeyFile)
+	if err = os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := []encoding.BinaryUnmarshaler{new(rlwe.SwitchingKey)}
+	if err = readKeyParts(path, BootstrappingKeyKind, params, parts); err == nil {
+		t.Fatal("readKeyParts accepted a part longer than the file")
+	}
+}
@@ -0,0 +1,159 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+var (
+	ErrComparisonRange    = errors.New("comparison threshold must lie inside the declared input range")
+	ErrComparisonDisabled = errors.New("comparison policy has no sign iterations")
+)
+
+// SignPrecision is the largest distance to ±1 tolerated from the approximate sign of an input outside the margin
+const SignPrecision = 0.01
+
+// signPolynomialDepth is the number of levels consumed by each degree 7 sign polynomial
+const signPolynomialDepth = 3
+
+// expansionCoefficients are those of a steep odd polynomial that pushes inputs near zero away from it (slope 4.48 at 0)
+// Taken from Cheon et al., "Efficient Homomorphic Comparison Methods with Optimal Complexity".
+var expansionCoefficients = []float64{0, 4589.0 / 1024, 0, -16577.0 / 1024, 0, 25614.0 / 1024, 0, -12860.0 / 1024}
+
+// refinementCoefficients are those of f(x) = (35x - 35x^3 + 21x^5 - 5x^7) / 16, which pulls inputs in [-1, 1] towards ±1
+var refinementCoefficients = []float64{0, 35.0 / 16, 0, -35.0 / 16, 0, 21.0 / 16, 0, -5.0 / 16}
+
+// ComparisonPolicy configures the composite polynomial approximating the sign function on [-1, 1]
+// The composite first applies Expansions steep polynomials, then Refinements flat ones.
+type ComparisonPolicy struct {
+	// Expansions is the number of polynomials moving inputs close to zero towards ±1
+	Expansions int
+
+	// Refinements is the number of polynomials reducing the remaining distance to ±1
+	Refinements int
+}
+
+// Enabled reports whether the policy evaluates any sign polynomial
+func (p ComparisonPolicy) Enabled() bool {
+	return p.Expansions+p.Refinements > 0
+}
+
+// Depth returns the number of levels consumed by a comparison, including the normalization of the input
+func (p ComparisonPolicy) Depth() int {
+	if !p.Enabled() {
+		return 0
+	}
+	return 1 + signPolynomialDepth*(p.Expansions+p.Refinements)
+}
+
+// Margin returns the smallest normalized distance to zero, as a fraction of the declared input range, from which
+// the approximate sign is within SignPrecision of ±1. Inputs closer to zero than the margin get an undecided sign.
+func (p ComparisonPolicy) Margin() float64 {
+	const steps = 100000
+
+	margin := 1.0
+	for i := steps; i > 0; i-- {
+		x := float64(i) / steps
+		if math.Abs(p.evaluate(x)-1) > SignPrecision {
+			return margin
+		}
+		margin = x
+	}
+	return margin
+}
+
+// evaluate computes the composite sign polynomial on a plaintext value
+func (p ComparisonPolicy) evaluate(x float64) float64 {
+	for _, coefficients := range p.polynomials() {
+		y := 0.0
+		for i := len(coefficients) - 1; i >= 0; i-- {
+			y = y*x + coefficients[i]
+		}
+		x = y
+	}
+	return x
+}
+
+// polynomials returns the coefficients of the sign polynomials in the order they are applied
+func (p ComparisonPolicy) polynomials() [][]float64 {
+	polynomials := make([][]float64, 0, p.Expansions+p.Refinements)
+	for i := 0; i < p.Expansions; i++ {
+		polynomials = append(polynomials, expansionCoefficients)
+	}
+	for i := 0; i < p.Refinements; i++ {
+		polynomials = append(polynomials, refinementCoefficients)
+	}
+	return polynomials
+}
+
+// Sign approximates the sign (-1 or 1) of encrypted values declared to lie in [-bound, bound]
+// Values closer to zero than policy.Margin() * bound get an undecided result. It consumes policy.Depth() levels.
+func (c *CKKSEvaluator) Sign(ct *rlwe.Ciphertext, bound float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	if bound <= 0 {
+		return nil, fmt.Errorf("%w: bound %g", ErrComparisonRange, bound)
+	}
+	return c.compare(ct, 0, bound, policy, false)
+}
+
+// GreaterThan approximates the step function, 1 where the encrypted values are greater than threshold and 0 otherwise,
+// for values declared to lie in [lo, hi]. Values closer to threshold than policy.Margin() * max(threshold-lo, hi-threshold)
+// get an undecided result. It consumes policy.Depth() levels.
+func (c *CKKSEvaluator) GreaterThan(ct *rlwe.Ciphertext, threshold, lo, hi float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	if threshold < lo || threshold > hi || lo == hi {
+		return nil, fmt.Errorf("%w: %g not in [%g, %g]", ErrComparisonRange, threshold, lo, hi)
+	}
+	return c.compare(ct, threshold, math.Max(threshold-lo, hi-threshold), policy, true)
+}
+
+// compare evaluates the composite sign polynomial on (ct - threshold) / bound, mapping its output to [0, 1] when step is set
+func (c *CKKSEvaluator) compare(ct *rlwe.Ciphertext, threshold, bound float64, policy ComparisonPolicy, step bool) (*rlwe.Ciphertext, error) {
+	if !policy.Enabled() {
+		return nil, ErrComparisonDisabled
+	}
+	if err := c.checkCircuitDepth(policy.Depth(), ct); err != nil {
+		return nil, err
+	}
+	ct, err := c.refresh(ct, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalize the input into [-1, 1], centered on the threshold
+	result := c.Evaluator.MultByConstNew(ct, 1/bound)
+	c.Evaluator.AddConst(result, -threshold/bound, result)
+	if err = c.rescale(result); err != nil {
+		return nil, err
+	}
+
+	polynomials := policy.polynomials()
+	for i, coefficients := range polynomials {
+		values := make([]complex128, len(coefficients))
+		for j, coefficient := range coefficients {
+			values[j] = complex(coefficient, 0)
+		}
+
+		// The step function is (1 + sign) / 2, which is folded into the last polynomial
+		if step && i == len(polynomials)-1 {
+			for j := range values {
+				values[j] /= 2
+			}
+			values[0] += 0.5
+		}
+
+		if result, err = c.refresh(result, signPolynomialDepth); err != nil {
+			return nil, err
+		}
+		if result, err = c.Evaluator.EvaluatePoly(result, ckks.NewPoly(values), c.Scale); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package encryption
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// TestComparisonMargin ensures that deeper comparison policies decide closer to the threshold, and that the
+// preselection policy decides salaries SalaryResolution away from MinSalary
+func TestComparisonMargin(t *testing.T) {
+	shallow := ComparisonPolicy{Expansions: 2, Refinements: 2}.Margin()
+	deep := ComparisonPolicy{Expansions: 4, Refinements: 3}.Margin()
+	if deep >= shallow || deep > 0.001 {
+		t.Errorf("unexpected margins: shallow %f, deep %f", shallow, deep)
+	}
+
+	if margin := PreselectionScoringPolicy().Preselection.Margin() * (MaxSalary - MinSalary); margin >= SalaryResolution {
+		t.Errorf("salary gate undecided up to %f from MinSalary, expected less than %d", margin, SalaryResolution)
+	}
+}
+
+// TestGreaterThan ensures that the encrypted step function agrees with the plaintext comparison outside the margin
+func TestGreaterThan(t *testing.T) {
+	policy := ComparisonPolicy{Expansions: 3, Refinements: 2}
+	helper := newDeepTestHelper(t, ScoringPolicy{DTIInverseIterations: 8, Preselection: policy})
+
+	threshold, lo, hi := 18.5, 0.0, 120.0
+	values := []float64{0, 17, 18, 19, 25, 65, 120}
+	ciphertext, err := helper.EncryptVectorPu(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resultCiphertext, err := helper.GreaterThan(ciphertext, threshold, lo, hi, policy)
+	if err != nil {
+		t.Fatalf("GreaterThan failed: %v", err)
+	}
+
+	results := helper.DecryptVector(resultCiphertext, len(values))
+	for i, value := range values {
+		expected := 0.0
+		if value > threshold {
+			expected = 1
+		}
+		if math.Abs(results[i]-expected) > SignPrecision {
+			t.Errorf("GreaterThan(%f, %f) = %f, expected %f", value, threshold, results[i], expected)
+		}
+	}
+
+	if _, err = helper.GreaterThan(ciphertext, 200, lo, hi, policy); !errors.Is(err, ErrComparisonRange) {
+		t.Errorf("threshold outside the range returned %v, expected %v", err, ErrComparisonRange)
+	}
+	if _, err = helper.GreaterThan(ciphertext, threshold, lo, hi, ComparisonPolicy{}); !errors.Is(err, ErrComparisonDisabled) {
+		t.Errorf("disabled policy returned %v, expected %v", err, ErrComparisonDisabled)
+	}
+}
+
+// TestCreditEvaluationPreselection ensures that the encrypted preselection gate agrees with the plaintext decisions
+func TestCreditEvaluationPreselection(t *testing.T) {
+	helper := newDeepTestHelper(t, PreselectionScoringPolicy())
+
+	ages := []float64{30, 17, 45, 18, 19, 40, 40, 30}
+	salaries := []float64{50 * 1000 * 1000, 50 * 1000 * 1000, 5 * 1000 * 1000, 20 * 1000 * 1000, 12 * 1000 * 1000,
+		MinSalary - SalaryResolution, MinSalary + SalaryResolution, MaxSalary}
+	creditScores := []float64{800, 700, 650, 750, 600, 700, 700, 820}
+	dtis := []float64{0.5, 0.4, 0.3, 0.2, 0.8, 0.4, 0.4, 0.3}
+
+	encrypt := func(values []float64) []*rlwe.Ciphertext {
+		ciphertexts, err := helper.EncryptBatchPu(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ciphertexts
+	}
+
+	resultCiphertexts, err := helper.CreditEvaluationBatch(encrypt(ages), encrypt(salaries), encrypt(creditScores), encrypt(dtis))
+	if err != nil {
+		t.Fatalf("CreditEvaluationBatch failed: %v", err)
+	}
+
+	results := helper.DecryptBatch(resultCiphertexts, len(ages))
+	for i := range ages {
+		expected := -1.0
+		if ages[i] > MinAge && salaries[i] > MinSalary {
+			expected = W0*(creditScores[i]-MinCreditScore)/(MaxCreditScore-MinCreditScore) + W1/dtis[i]
+		}
+		if math.Abs(results[i]-expected) > 0.01 {
+			t.Errorf("applicant %d: got %f, expected %f", i, results[i], expected)
+		}
+	}
+
+	if _, err = helper.CreditEvaluation(encrypt(ages)[0], nil, encrypt(creditScores)[0], encrypt(dtis)[0]); !errors.Is(err, ErrPreselectionInputs) {
+		t.Errorf("missing salary returned %v, expected %v", err, ErrPreselectionInputs)
+	}
+}
+
+// newDeepTestHelper returns a helper with exactly enough levels for the scoring policy
+// The ring is far too small for the modulus chain to be secure, which keeps the tests fast.
+func newDeepTestHelper(t *testing.T, policy ScoringPolicy) *CKKSHelper {
+	logQ := []int{55}
+	for i := 0; i < policy.Depth(); i++ {
+		logQ = append(logQ, 40)
+	}
+
+	helper, err := NewCKKSHelperWithOptions(HelperOptions{LogN: 13, LogQ: logQ, LogP: []int{55, 55}, Policy: policy})
+	if err != nil {
+		t.Fatalf("NewCKKSHelperWithOptions failed: %v", err)
+	}
+	return helper
+}
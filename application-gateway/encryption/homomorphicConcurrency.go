@@ -0,0 +1,95 @@
+package encryption
+
+import (
+	"sync"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+// The encoders, encryptors, decryptors and evaluators of the roles keep internal buffers, so that a role must not
+// be used by several goroutines at once. A shallow copy shares the keys and the read-only precomputations of its
+// original but has its own buffers: each goroutine works on its own copy, or borrows one from a CKKSHelperPool.
+
+// ShallowCopy returns a copy of the key owner that can be used concurrently with the original
+func (c *CKKSKeyOwner) ShallowCopy() *CKKSKeyOwner {
+	return c.shallowCopy(c.Encoder.ShallowCopy())
+}
+
+func (c *CKKSKeyOwner) shallowCopy(encoder ckks.Encoder) *CKKSKeyOwner {
+	owner := *c
+	owner.Encoder = encoder
+	owner.EncryptorPr = c.EncryptorPr.ShallowCopy()
+	// The shallow copy of an rlwe decryptor loses its parameters in lattigo v4.1.1, a new one only allocates a buffer
+	owner.Decryptor = ckks.NewDecryptor(c.Params, c.secretKey)
+	return &owner
+}
+
+// ShallowCopy returns a copy of the encryptor that can be used concurrently with the original
+func (c *CKKSEncryptor) ShallowCopy() *CKKSEncryptor {
+	return c.shallowCopy(c.Encoder.ShallowCopy())
+}
+
+func (c *CKKSEncryptor) shallowCopy(encoder ckks.Encoder) *CKKSEncryptor {
+	encryptor := *c
+	encryptor.Encoder = encoder
+	encryptor.EncryptorPu = c.EncryptorPu.ShallowCopy()
+	return &encryptor
+}
+
+// ShallowCopy returns a copy of the evaluator that can be used concurrently with the original
+// Its bootstrapper, if any, is copied as well.
+func (c *CKKSEvaluator) ShallowCopy() *CKKSEvaluator {
+	return c.shallowCopy(c.Encoder.ShallowCopy())
+}
+
+func (c *CKKSEvaluator) shallowCopy(encoder ckks.Encoder) *CKKSEvaluator {
+	evaluator := *c
+	evaluator.Encoder = encoder
+	evaluator.Evaluator = c.Evaluator.ShallowCopy()
+	if c.btp != nil {
+		btp := *c.btp
+		btp.bootstrapper = c.btp.bootstrapper.ShallowCopy()
+		evaluator.btp = &btp
+	}
+	return &evaluator
+}
+
+// ShallowCopy returns a copy of the helper that can be used concurrently with the original
+// Like the original, the roles of the copy share a single encoder.
+func (c *CKKSHelper) ShallowCopy() *CKKSHelper {
+	helper := *c
+	helper.Encoder = c.Encoder.ShallowCopy()
+	helper.CKKSKeyOwner = c.CKKSKeyOwner.shallowCopy(helper.Encoder)
+	helper.CKKSEncryptor = c.CKKSEncryptor.shallowCopy(helper.Encoder)
+	helper.CKKSEvaluator = c.CKKSEvaluator.shallowCopy(helper.Encoder)
+	return &helper
+}
+
+// CKKSHelperPool hands out shallow copies of a helper to concurrent goroutines, such as the handlers of a server
+// scoring several applicants in parallel, and recycles them once they are done
+type CKKSHelperPool struct {
+	pool sync.Pool
+}
+
+// NewCKKSHelperPool creates a pool of shallow copies of the helper
+// The helper itself is never handed out, so that a single goroutine may keep using it alongside the pool.
+func NewCKKSHelperPool(helper *CKKSHelper) *CKKSHelperPool {
+	return &CKKSHelperPool{pool: sync.Pool{New: func() any { return helper.ShallowCopy() }}}
+}
+
+// Get borrows a helper, for the exclusive use of the calling goroutine until it is put back
+func (p *CKKSHelperPool) Get() *CKKSHelper {
+	return p.pool.Get().(*CKKSHelper)
+}
+
+// Put returns a borrowed helper to the pool
+func (p *CKKSHelperPool) Put(helper *CKKSHelper) {
+	p.pool.Put(helper)
+}
+
+// Do runs f with a borrowed helper and puts it back once f returns
+func (p *CKKSHelperPool) Do(f func(helper *CKKSHelper) error) error {
+	helper := p.Get()
+	defer p.Put(helper)
+	return f(helper)
+}
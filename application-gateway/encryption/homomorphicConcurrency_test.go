@@ -0,0 +1,96 @@
+package encryption
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+)
+
+// concurrentApplicants is the number of applicants scored in parallel by the concurrency tests
+const concurrentApplicants = 8
+
+// scoreApplicant encrypts, evaluates and decrypts a small score of one applicant with a helper of its own
+func scoreApplicant(helper *CKKSHelper, i int) error {
+	creditScore, dti := 500.0+float64(50*i), 0.2+0.05*float64(i)
+	creditScoreCiphertext, dtiCiphertext := helper.EncryptPu(creditScore), helper.EncryptPu(dti)
+
+	product, err := helper.Multiply(creditScoreCiphertext, dtiCiphertext)
+	if err != nil {
+		return err
+	}
+	resultCiphertext, err := helper.Add(product, creditScoreCiphertext)
+	if err != nil {
+		return err
+	}
+
+	result := helper.Decrypt(resultCiphertext)
+	expected := creditScore*dti + creditScore
+	if math.Abs(result-expected) > 1e-4*expected {
+		return fmt.Errorf("applicant %d: got %f, expected %f", i, result, expected)
+	}
+	return nil
+}
+
+// TestShallowCopyConcurrency ensures that shallow copies of a helper score applicants in parallel, alongside
+// the original helper; run with -race to check that they share no mutable state
+func TestShallowCopyConcurrency(t *testing.T) {
+	helper := NewCKKSHelper()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentApplicants)
+	for i := 0; i < concurrentApplicants; i++ {
+		copied := helper.ShallowCopy()
+		if i == 0 {
+			copied = helper
+		}
+		wg.Add(1)
+		go func(helper *CKKSHelper, i int) {
+			defer wg.Done()
+			errs <- scoreApplicant(helper, i)
+		}(copied, i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestHelperPoolConcurrency ensures that helpers borrowed from a pool score applicants in parallel and are recycled
+func TestHelperPoolConcurrency(t *testing.T) {
+	pool := NewCKKSHelperPool(NewCKKSHelper())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*concurrentApplicants)
+	for round := 0; round < 2; round++ {
+		for i := 0; i < concurrentApplicants; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- pool.Do(func(helper *CKKSHelper) error {
+					return scoreApplicant(helper, i)
+				})
+			}(i)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A ciphertext encrypted with a borrowed helper is decrypted with another one
+	encryptor, owner := pool.Get(), pool.Get()
+	defer pool.Put(encryptor)
+	defer pool.Put(owner)
+	if result := owner.Decrypt(encryptor.EncryptPu(42)); math.Abs(result-42) > precision {
+		t.Errorf("helpers of the pool do not share keys. Got %f, expected 42", result)
+	}
+}
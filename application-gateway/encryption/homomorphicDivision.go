@@ -0,0 +1,121 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// ErrInverseRange is returned when the declared input range of an inverse contains zero
+var ErrInverseRange = errors.New("inverse input range must not contain zero")
+
+// InverseIterations returns the number of Goldschmidt iterations needed by Inverse to reach
+// the given relative precision for every input in [lo, hi]
+func InverseIterations(lo, hi, precision float64) int {
+	// After normalization the initial error is bounded by |hi-lo| / |hi+lo|,
+	// and every iteration squares it
+	e := math.Abs(hi-lo) / math.Abs(hi+lo)
+	iterations := 1
+	for e > precision && iterations < 64 {
+		e *= e
+		iterations++
+	}
+	return iterations
+}
+
+// InverseDepth returns the number of levels consumed by an inverse with the given number of iterations
+func InverseDepth(iterations int) int {
+	if iterations <= 1 {
+		return 1
+	}
+	return iterations + 1
+}
+
+// Inverse computes 1/ct for encrypted values declared to lie in [lo, hi]
+// It consumes InverseDepth(iterations) levels and never touches the secret key
+func (c *CKKSEvaluator) Inverse(ct *rlwe.Ciphertext, lo, hi float64, iterations int) (*rlwe.Ciphertext, error) {
+	return c.ScaledInverse(ct, 1, lo, hi, iterations)
+}
+
+// ScaledInverse computes numerator/ct for encrypted values declared to lie in [lo, hi] using Goldschmidt iteration.
+//
+// The input is first normalized by k = 2/(lo+hi), so that x' = k*x lies in (0, 2) and the error e = 1 - x'
+// is bounded by |hi-lo|/|hi+lo| < 1. Then 1/x' = (1+e)(1+e^2)(1+e^4)..., and every iteration squares the
+// remaining error. The normalization and the public numerator are folded into the first factor, which is
+// computed directly from the input, so the evaluation consumes InverseDepth(iterations) levels.
+func (c *CKKSEvaluator) ScaledInverse(ct *rlwe.Ciphertext, numerator, lo, hi float64, iterations int) (*rlwe.Ciphertext, error) {
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	if lo <= 0 && hi >= 0 {
+		return nil, fmt.Errorf("%w: [%g, %g]", ErrInverseRange, lo, hi)
+	}
+	if iterations < 1 {
+		return nil, fmt.Errorf("inverse needs at least one iteration, got %d", iterations)
+	}
+	if err := c.checkCircuitDepth(InverseDepth(iterations), ct); err != nil {
+		return nil, err
+	}
+	ct, err := c.refresh(ct, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalization factor; it carries the sign of the range so k*x is always positive
+	k := 2 / (lo + hi)
+
+	// e = 1 - k*x
+	e := c.Evaluator.MultByConstNew(ct, -k)
+	c.Evaluator.AddConst(e, 1, e)
+	if err = c.rescale(e); err != nil {
+		return nil, err
+	}
+
+	// result = numerator * k * (1 + e) = numerator * (2k - k^2 * x)
+	result := c.Evaluator.MultByConstNew(ct, -numerator*k*k)
+	c.Evaluator.AddConst(result, 2*numerator*k, result)
+	if err = c.rescale(result); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < iterations; i++ {
+		// Bootstrap the error and the partial result when they run out of levels
+		// The squared error is one level below e, and so is the product with the partial result.
+		if e, err = c.refresh(e, 2); err != nil {
+			return nil, err
+		}
+		if result, err = c.refresh(result, 1); err != nil {
+			return nil, err
+		}
+
+		// e = e^2
+		c.Evaluator.MulRelin(e, e, e)
+		if err = c.rescale(e); err != nil {
+			return nil, err
+		}
+
+		// result = result * (1 + e)
+		factor := c.Evaluator.AddConstNew(e, 1)
+		c.Evaluator.MulRelin(result, factor, result)
+		if err = c.rescale(result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Divide divides two ciphertexts (ct1 / ct2), where the values of ct2 are declared to lie in [lo, hi]
+// It consumes InverseDepth(iterations) + 1 levels
+func (c *CKKSEvaluator) Divide(ct1, ct2 *rlwe.Ciphertext, lo, hi float64, iterations int) (*rlwe.Ciphertext, error) {
+	// Compute the reciprocal of the divisor
+	reciprocal, err := c.Inverse(ct2, lo, hi, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	// Multiply ct1 by the reciprocal
+	return c.Multiply(ct1, reciprocal)
+}
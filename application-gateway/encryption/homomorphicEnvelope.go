@@ -0,0 +1,459 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// EnvelopeVersion is the version of the envelope format written by EncodeEnvelope
+// Bare ciphertexts stored before envelopes existed are read back as version 0; version 1 envelopes have no
+// payload flags and always hold a full uncompressed ciphertext.
+const EnvelopeVersion = 2
+
+// SchemeCKKS names the scheme of envelopes holding CKKS ciphertexts
+const SchemeCKKS = "ckks"
+
+// Slot encodings of the values inside an envelope
+const (
+	// EncodingScalar repeats a single value over every slot, as EncryptPu does
+	EncodingScalar = "scalar"
+	// EncodingVector packs one value per slot, repeated cyclically, as EncryptVectorPu does
+	EncodingVector = "vector"
+)
+
+// Serializations of an envelope inside a Document.Data field
+const (
+	EnvelopeJSON   = "json"
+	EnvelopeBinary = "binary"
+)
+
+// envelopeMagic starts the binary serialization of an envelope
+var envelopeMagic = []byte("CKEV")
+
+// Payload flags of the binary serialization
+const (
+	envelopeSeeded byte = 1 << iota
+	envelopeCompressed
+)
+
+var (
+	ErrChecksumMismatch    = errors.New("envelope payload does not match its checksum")
+	ErrKeyMismatch         = errors.New("ciphertext was encrypted under a different key")
+	ErrUnsupportedScheme   = errors.New("unsupported encryption scheme")
+	ErrUnsupportedEncoding = errors.New("unsupported envelope encoding")
+)
+
+// Envelope wraps a serialized ciphertext with the metadata needed to use it safely: the scheme and parameters
+// it belongs to, the key it is encrypted under, how the values are laid out in the slots, its level and scale
+type Envelope struct {
+	Version     int     `json:"Version"`
+	Scheme      string  `json:"Scheme"`
+	Fingerprint string  `json:"Fingerprint"`
+	KeyID       string  `json:"KeyID"`
+	Encoding    string  `json:"Encoding"`
+	Slots       int     `json:"Slots"`
+	Level       int     `json:"Level"`
+	Scale       float64 `json:"Scale"`
+	Seeded      bool    `json:"Seeded,omitempty"`
+	Compressed  bool    `json:"Compressed,omitempty"`
+	Payload     []byte  `json:"Payload"`
+	Checksum    string  `json:"Checksum"`
+}
+
+// PublicKeyID returns a short digest identifying a public key, or an empty string if it cannot be serialized
+func PublicKeyID(pk *rlwe.PublicKey) string {
+	data, err := pk.MarshalBinary()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewEnvelope wraps a ciphertext of the given parameters, encrypted under the key identified by keyID,
+// holding slots values laid out with encoding
+func NewEnvelope(ct *rlwe.Ciphertext, params ckks.Parameters, keyID string, encoding string, slots int) (*Envelope, error) {
+	if encoding != EncodingScalar && encoding != EncodingVector {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+	fingerprint, err := ParamsFingerprint(params)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ct.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the ciphertext: %w", err)
+	}
+
+	return &Envelope{
+		Version:     EnvelopeVersion,
+		Scheme:      SchemeCKKS,
+		Fingerprint: fingerprint,
+		KeyID:       keyID,
+		Encoding:    encoding,
+		Slots:       slots,
+		Level:       ct.Level(),
+		Scale:       ct.Scale.Float64(),
+		Payload:     payload,
+		Checksum:    checksum(payload),
+	}, nil
+}
+
+// NewSeededEnvelope wraps a seeded ciphertext like NewEnvelope does, keeping it seeded
+func NewSeededEnvelope(s *SeededCiphertext, params ckks.Parameters, keyID string, encoding string, slots int) (*Envelope, error) {
+	payload, err := s.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the seeded ciphertext: %w", err)
+	}
+	e, err := NewEnvelope(s.Body, params, keyID, encoding, slots)
+	if err != nil {
+		return nil, err
+	}
+	e.Seeded = true
+	e.Payload = payload
+	e.Checksum = checksum(payload)
+	return e, nil
+}
+
+// Compress gzips the payload of the envelope, which Open transparently reverses
+func (e *Envelope) Compress() error {
+	if e.Compressed {
+		return nil
+	}
+	payload, err := Compress(e.Payload)
+	if err != nil {
+		return err
+	}
+	e.Compressed = true
+	e.Payload = payload
+	e.Checksum = checksum(payload)
+	return nil
+}
+
+// Seal encrypts a value and wraps it into an envelope
+func (c *CKKSEncryptor) Seal(value float64) (*Envelope, error) {
+	return NewEnvelope(c.EncryptPu(value), c.Params, c.KeyID, EncodingScalar, 1)
+}
+
+// SealVector encrypts a vector of values, one per slot, and wraps it into an envelope
+func (c *CKKSEncryptor) SealVector(values []float64) (*Envelope, error) {
+	ct, err := c.EncryptVectorPu(values)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnvelope(ct, c.Params, c.KeyID, EncodingVector, len(values))
+}
+
+// SealSeeded encrypts a value under the secret key and wraps it into a seeded envelope, about half the size
+// of the envelope Seal returns
+func (c *CKKSHelper) SealSeeded(value float64) (*Envelope, error) {
+	s, err := c.EncryptSeeded(value)
+	if err != nil {
+		return nil, err
+	}
+	return NewSeededEnvelope(s, c.Params, c.KeyID, EncodingScalar, 1)
+}
+
+// SealVectorSeeded encrypts a vector of values under the secret key and wraps it into a seeded envelope
+func (c *CKKSHelper) SealVectorSeeded(values []float64) (*Envelope, error) {
+	s, err := c.EncryptVectorSeeded(values)
+	if err != nil {
+		return nil, err
+	}
+	return NewSeededEnvelope(s, c.Params, c.KeyID, EncodingVector, len(values))
+}
+
+// Open checks that the envelope belongs to the parameters, and to the key identified by keyID unless it is empty,
+// and returns the ciphertext it holds, decompressed and expanded. Envelopes read from bare ciphertexts carry no
+// metadata to check.
+func (e *Envelope) Open(params ckks.Parameters, keyID string) (*rlwe.Ciphertext, error) {
+	if e.Scheme != SchemeCKKS {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedScheme, e.Scheme)
+	}
+	if e.Version > 0 {
+		if e.Checksum != checksum(e.Payload) {
+			return nil, ErrChecksumMismatch
+		}
+		fingerprint, err := ParamsFingerprint(params)
+		if err != nil {
+			return nil, err
+		}
+		if e.Fingerprint != fingerprint {
+			return nil, fmt.Errorf("%w: expected %s, got %s", ErrParamsMismatch, fingerprint, e.Fingerprint)
+		}
+		if keyID != "" && e.KeyID != keyID {
+			return nil, fmt.Errorf("%w: expected %s, got %s", ErrKeyMismatch, keyID, e.KeyID)
+		}
+	}
+
+	ct, err := e.ciphertext(params)
+	if err != nil {
+		return nil, err
+	}
+	if ct.Level() != e.Level || ct.Scale.Float64() != e.Scale {
+		return nil, fmt.Errorf("envelope metadata does not match its ciphertext: level %d scale %g, ciphertext level %d scale %g",
+			e.Level, e.Scale, ct.Level(), ct.Scale.Float64())
+	}
+	return ct, nil
+}
+
+// ciphertext reads the ciphertext of the payload, undoing the compression and the seeding
+func (e *Envelope) ciphertext(params ckks.Parameters) (*rlwe.Ciphertext, error) {
+	payload := e.Payload
+	if e.Compressed {
+		var err error
+		if payload, err = Decompress(payload, params); err != nil {
+			return nil, fmt.Errorf("envelope payload is malformed: %w", err)
+		}
+	}
+
+	if e.Seeded {
+		s := new(SeededCiphertext)
+		if err := s.UnmarshalBinary(payload); err != nil {
+			return nil, fmt.Errorf("envelope payload is malformed: %w", err)
+		}
+		return s.Expand(params)
+	}
+
+	ct := new(rlwe.Ciphertext)
+	if err := ct.UnmarshalBinary(payload); err != nil {
+		return nil, fmt.Errorf("envelope payload is malformed: %w", err)
+	}
+	return ct, nil
+}
+
+// Values decrypts an envelope into the values it holds, for the key owner; SharedValues floods them for another party
+func (c *CKKSKeyOwner) Values(e *Envelope) ([]float64, error) {
+	ct, err := e.Open(c.Params, "")
+	if err != nil {
+		return nil, err
+	}
+	slots := e.Slots
+	if slots < 1 {
+		slots = 1
+	}
+	return c.DecryptVector(ct, slots), nil
+}
+
+// CreditEvaluationEnvelopes runs CreditEvaluation on enveloped inputs and envelopes the result
+// Every input must be encrypted under the same key, which the result is encrypted under too; age and salary may be nil.
+func (c *CKKSEvaluator) CreditEvaluationEnvelopes(age, salary, creditScore, dti *Envelope) (*Envelope, error) {
+	var keyID string
+	encoding, slots := EncodingScalar, 1
+	var inputs [4]*rlwe.Ciphertext
+	for i, e := range []*Envelope{age, salary, creditScore, dti} {
+		if e == nil {
+			continue
+		}
+		if keyID == "" {
+			keyID = e.KeyID
+		}
+
+		var err error
+		if inputs[i], err = e.Open(c.Params, keyID); err != nil {
+			return nil, err
+		}
+		if e.Encoding == EncodingVector {
+			encoding = EncodingVector
+		}
+		if e.Slots > slots {
+			slots = e.Slots
+		}
+	}
+
+	result, err := c.CreditEvaluation(inputs[0], inputs[1], inputs[2], inputs[3])
+	if err != nil {
+		return nil, err
+	}
+	return NewEnvelope(result, c.Params, keyID, encoding, slots)
+}
+
+// EncodeEnvelope serializes an envelope into a string that can be stored in Document.Data
+// The JSON serialization is readable as is, the binary one is compact and base64 encoded.
+func EncodeEnvelope(e *Envelope, format string) (string, error) {
+	switch format {
+	case EnvelopeJSON:
+		data, err := json.Marshal(e)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case EnvelopeBinary:
+		data, err := e.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedEncoding, format)
+	}
+}
+
+// DecodeEnvelope reads an envelope written by EncodeEnvelope in either serialization
+// Bare base64 ciphertexts, as stored before envelopes existed, are read back as version 0 envelopes.
+func DecodeEnvelope(encoded string) (*Envelope, error) {
+	// JSON cannot be mistaken for base64, which has no braces
+	if len(encoded) > 0 && encoded[0] == '{' {
+		var e Envelope
+		if err := json.Unmarshal([]byte(encoded), &e); err != nil {
+			return nil, fmt.Errorf("envelope is malformed: %w", err)
+		}
+		if e.Version > EnvelopeVersion {
+			return nil, fmt.Errorf("%w: envelope version %d", ErrUnsupportedVersion, e.Version)
+		}
+		return &e, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("envelope is malformed: %w", err)
+	}
+	if bytes.HasPrefix(data, envelopeMagic) {
+		e := new(Envelope)
+		if err = e.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return legacyEnvelope(data)
+}
+
+// legacyEnvelope wraps a bare serialized ciphertext, taking the level and scale from the ciphertext itself
+func legacyEnvelope(payload []byte) (*Envelope, error) {
+	ct := new(rlwe.Ciphertext)
+	if err := ct.UnmarshalBinary(payload); err != nil {
+		return nil, fmt.Errorf("envelope is malformed: %w", err)
+	}
+	return &Envelope{
+		Scheme:   SchemeCKKS,
+		Encoding: EncodingScalar,
+		Slots:    1,
+		Level:    ct.Level(),
+		Scale:    ct.Scale.Float64(),
+		Payload:  payload,
+		Checksum: checksum(payload),
+	}, nil
+}
+
+// MarshalBinary serializes the envelope as the magic bytes, the version, then every field in order, with
+// strings and the payload prefixed by their length and the booleans packed into a flags byte
+func (e *Envelope) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(envelopeMagic)
+	buf.WriteByte(byte(e.Version))
+	for _, field := range []string{e.Scheme, e.Fingerprint, e.KeyID, e.Encoding} {
+		writeBytes(&buf, []byte(field))
+	}
+	if e.Version >= 2 {
+		var flags byte
+		if e.Seeded {
+			flags |= envelopeSeeded
+		}
+		if e.Compressed {
+			flags |= envelopeCompressed
+		}
+		buf.WriteByte(flags)
+	} else if e.Seeded || e.Compressed {
+		return nil, fmt.Errorf("%w: envelope version %d cannot hold a seeded or compressed payload", ErrUnsupportedVersion, e.Version)
+	}
+	buf.Write(binary.AppendUvarint(nil, uint64(e.Slots)))
+	buf.Write(binary.AppendUvarint(nil, uint64(e.Level)))
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(e.Scale)))
+	writeBytes(&buf, []byte(e.Checksum))
+	writeBytes(&buf, e.Payload)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary reads an envelope serialized by MarshalBinary
+func (e *Envelope) UnmarshalBinary(data []byte) (err error) {
+	if !bytes.HasPrefix(data, envelopeMagic) || len(data) <= len(envelopeMagic) {
+		return errors.New("envelope is malformed: missing header")
+	}
+	reader := bytes.NewReader(data[len(envelopeMagic):])
+	version, _ := reader.ReadByte()
+	if int(version) > EnvelopeVersion {
+		return fmt.Errorf("%w: envelope version %d", ErrUnsupportedVersion, version)
+	}
+
+	var fields [5][]byte
+	var flags byte
+	var slots, level uint64
+	var scale [8]byte
+	for i := range fields[:4] {
+		if fields[i], err = readBytes(reader); err != nil {
+			return fmt.Errorf("envelope is malformed: %w", err)
+		}
+	}
+	if version >= 2 {
+		if flags, err = reader.ReadByte(); err != nil {
+			return fmt.Errorf("envelope is malformed: %w", err)
+		}
+	}
+	if slots, err = binary.ReadUvarint(reader); err != nil {
+		return fmt.Errorf("envelope is malformed: %w", err)
+	}
+	if level, err = binary.ReadUvarint(reader); err != nil {
+		return fmt.Errorf("envelope is malformed: %w", err)
+	}
+	if _, err = io.ReadFull(reader, scale[:]); err != nil {
+		return fmt.Errorf("envelope is malformed: %w", err)
+	}
+	if fields[4], err = readBytes(reader); err != nil {
+		return fmt.Errorf("envelope is malformed: %w", err)
+	}
+	payload, err := readBytes(reader)
+	if err != nil {
+		return fmt.Errorf("envelope is malformed: %w", err)
+	}
+
+	*e = Envelope{
+		Version:     int(version),
+		Scheme:      string(fields[0]),
+		Fingerprint: string(fields[1]),
+		KeyID:       string(fields[2]),
+		Encoding:    string(fields[3]),
+		Slots:       int(slots),
+		Level:       int(level),
+		Scale:       math.Float64frombits(binary.BigEndian.Uint64(scale[:])),
+		Seeded:      flags&envelopeSeeded != 0,
+		Compressed:  flags&envelopeCompressed != 0,
+		Checksum:    string(fields[4]),
+		Payload:     payload,
+	}
+	return nil
+}
+
+func writeBytes(buf *bytes.Buffer, data []byte) {
+	buf.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	buf.Write(data)
+}
+
+func readBytes(reader *bytes.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(reader.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(reader, data)
+	return data, err
+}
+
+// checksum returns the hex SHA-256 digest of a payload
+func checksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
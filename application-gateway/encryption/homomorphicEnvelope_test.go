@@ -0,0 +1,129 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestEnvelopeEncodings ensures that envelopes survive both serializations and that bare ciphertexts are still read
+func TestEnvelopeEncodings(t *testing.T) {
+	helper := NewCKKSHelper()
+	values := []float64{1.5, -2, 700}
+
+	envelope, err := helper.SealVector(values)
+	if err != nil {
+		t.Fatalf("SealVector failed: %v", err)
+	}
+
+	for _, format := range []string{EnvelopeJSON, EnvelopeBinary} {
+		encoded, err := EncodeEnvelope(envelope, format)
+		if err != nil {
+			t.Fatalf("EncodeEnvelope %s failed: %v", format, err)
+		}
+		decoded, err := DecodeEnvelope(encoded)
+		if err != nil {
+			t.Fatalf("DecodeEnvelope %s failed: %v", format, err)
+		}
+		if decoded.KeyID != helper.KeyID || decoded.Fingerprint != helper.Fingerprint || decoded.Slots != len(values) || decoded.Encoding != EncodingVector {
+			t.Errorf("%s: metadata was not preserved: %+v", format, decoded)
+		}
+
+		results, err := helper.Values(decoded)
+		if err != nil {
+			t.Fatalf("%s: Values failed: %v", format, err)
+		}
+		for i, value := range values {
+			if math.Abs(results[i]-value) > precision {
+				t.Errorf("%s: slot %d: got %f, expected %f", format, i, results[i], value)
+			}
+		}
+	}
+
+	// Fields stored before envelopes existed are bare base64 ciphertexts
+	payload, err := helper.EncryptPu(42).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := DecodeEnvelope(base64.StdEncoding.EncodeToString(payload))
+	if err != nil {
+		t.Fatalf("DecodeEnvelope of a bare ciphertext failed: %v", err)
+	}
+	if legacy.Version != 0 || legacy.Level != helper.Params.MaxLevel() {
+		t.Errorf("bare ciphertext read as %+v", legacy)
+	}
+	if results, err := helper.Values(legacy); err != nil || math.Abs(results[0]-42) > precision {
+		t.Errorf("Values of a bare ciphertext returned %v, %v", results, err)
+	}
+}
+
+// TestEnvelopeChecks ensures that tampered envelopes and envelopes of other keys or parameters are refused
+func TestEnvelopeChecks(t *testing.T) {
+	helper := NewCKKSHelper()
+	envelope, err := helper.Seal(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := *envelope
+	tampered.Payload = append([]byte{}, envelope.Payload...)
+	tampered.Payload[len(tampered.Payload)-1] ^= 1
+	if _, err = tampered.Open(helper.Params, ""); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Open of a tampered envelope returned %v, expected %v", err, ErrChecksumMismatch)
+	}
+
+	if _, err = envelope.Open(helper.Params, "other key"); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Open under another key returned %v, expected %v", err, ErrKeyMismatch)
+	}
+
+	otherParams, err := HelperOptions{ParameterSet: PN15QP880}.Parameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = envelope.Open(otherParams, ""); !errors.Is(err, ErrParamsMismatch) {
+		t.Errorf("Open under other parameters returned %v, expected %v", err, ErrParamsMismatch)
+	}
+
+	if _, err = DecodeEnvelope(`{"Version":3,"Scheme":"ckks"}`); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("DecodeEnvelope of a newer version returned %v, expected %v", err, ErrUnsupportedVersion)
+	}
+}
+
+// TestCreditEvaluationEnvelopes ensures that the evaluator reads enveloped inputs and envelopes its result
+func TestCreditEvaluationEnvelopes(t *testing.T) {
+	helper := NewCKKSHelper()
+	creditScore, err := helper.Seal(800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dti, err := helper.Seal(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := helper.CreditEvaluationEnvelopes(nil, nil, creditScore, dti)
+	if err != nil {
+		t.Fatalf("CreditEvaluationEnvelopes failed: %v", err)
+	}
+	if result.KeyID != helper.KeyID {
+		t.Errorf("result is enveloped under key %s, expected %s", result.KeyID, helper.KeyID)
+	}
+	values, err := helper.Values(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := W0*(800-MinCreditScore)/(MaxCreditScore-MinCreditScore) + W1/0.5
+	if math.Abs(values[0]-expected) > 0.01 {
+		t.Errorf("CreditEvaluationEnvelopes got %f, expected %f", values[0], expected)
+	}
+
+	// Inputs encrypted under different keys cannot be combined
+	other, err := NewCKKSHelper().Seal(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = helper.CreditEvaluationEnvelopes(nil, nil, creditScore, other); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("CreditEvaluationEnvelopes with mixed keys returned %v, expected %v", err, ErrKeyMismatch)
+	}
+}
@@ -0,0 +1,167 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/ring"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/tuneinsight/lattigo/v4/utils"
+)
+
+// DefaultFloodingPrecision is the largest error the flooding noise adds to a disclosed value
+const DefaultFloodingPrecision = 1e-2
+
+// DefaultFloodingSecurity is the statistical security, in bits, of the flooding of disclosed values
+// With the default precision, the scoring circuit only leaves enough room for it on PN15QP880 and larger sets.
+const DefaultFloodingSecurity = 32
+
+// floodingTail is the number of standard deviations of the flooding noise that fit in the precision,
+// which bounds the error of a disclosed value except with probability about 2^-29
+const floodingTail = 6
+
+var ErrFloodingPrecision = errors.New("decryption noise is too large to be flooded within the precision")
+
+// NoiseFlooding sizes the Gaussian noise added to decrypted values before they leave the key owner
+// A raw CKKS decryption reveals its noise, which is linear in the secret key, so that a few decryptions returned to
+// another party let it recover the key (Li and Micciancio, Eurocrypt 2021). The flooding noise is as large as the
+// precision allows, and must exceed the decryption noise by 2^(SecurityBits/2) to statistically hide it.
+type NoiseFlooding struct {
+	// Precision is the largest error the noise adds to a disclosed value, DefaultFloodingPrecision when zero
+	Precision float64
+
+	// SecurityBits is the statistical security of the flooding, DefaultFloodingSecurity when zero
+	SecurityBits int
+}
+
+// precision returns the largest error the flooding noise adds to a disclosed value
+func (f NoiseFlooding) precision() float64 {
+	if f.Precision <= 0 {
+		return DefaultFloodingPrecision
+	}
+	return f.Precision
+}
+
+// securityBits returns the statistical security of the flooding
+func (f NoiseFlooding) securityBits() int {
+	if f.SecurityBits <= 0 {
+		return DefaultFloodingSecurity
+	}
+	return f.SecurityBits
+}
+
+// sigma returns the standard deviation of the flooding noise
+func (f NoiseFlooding) sigma() float64 {
+	return f.precision() / floodingTail
+}
+
+// smudgingSigma returns the standard deviation of the noise a key-switching share adds to the coefficients of a
+// ciphertext, so that the values it decodes to at the default scale are flooded like by DecryptShared
+// Every coefficient contributes to every slot, whose real part gets sqrt(N/2) times the coefficient noise.
+func (f NoiseFlooding) smudgingSigma(params ckks.Parameters) float64 {
+	return f.sigma() * params.DefaultScale().Float64() / math.Sqrt(float64(params.N()/2))
+}
+
+// floodShare adds Gaussian noise of standard deviation sigma to the coefficients of a key-switching share at the
+// given level. The protocols of lattigo divide the noise they add to their shares by the special primes, which
+// leaves too little of it to flood anything.
+func floodShare(params ckks.Parameters, sigma float64, share *ring.Poly, level int, isNTT bool) error {
+	prng, err := utils.NewPRNG()
+	if err != nil {
+		return err
+	}
+
+	ringQ := params.RingQ()
+	noise := ringQ.NewPolyLvl(level)
+	ring.NewGaussianSampler(prng, ringQ, sigma, int(floodingTail*sigma)).ReadLvl(level, noise)
+	if isNTT {
+		ringQ.NTTLvl(level, noise, noise)
+	}
+	ringQ.AddLvl(level, share, noise, share)
+	return nil
+}
+
+// DecryptShared decrypts the first n slots of a ciphertext for another party, flooding each value with Gaussian
+// noise so that the result does not leak the secret key. Any decryption that leaves the key owner goes through it.
+func (c *CKKSKeyOwner) DecryptShared(ciphertext *rlwe.Ciphertext, n int, flooding NoiseFlooding) ([]float64, error) {
+	if n < 1 || n > 1<<c.LogSlots {
+		return nil, fmt.Errorf("%w: %d values for %d slots", ErrTooManyValues, n, 1<<c.LogSlots)
+	}
+
+	// Decrypt the ciphertext into a plaintext and decode all of its slots
+	decoded := c.Encoder.Decode(c.Decryptor.DecryptNew(ciphertext), c.LogSlots)
+
+	// The circuits only compute on real values, which leaves the imaginary parts as pure decryption noise
+	sigma := flooding.sigma()
+	if noise := imaginaryNoise(decoded); noise*math.Exp2(float64(flooding.securityBits())/2) > sigma {
+		return nil, fmt.Errorf("%w: noise %.3g, flooding %.3g for %d bits of security", ErrFloodingPrecision, noise, sigma, flooding.securityBits())
+	}
+
+	values := make([]float64, n)
+	for i := range values {
+		noise, err := gaussian(sigma)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = real(decoded[i]) + noise
+	}
+	return values, nil
+}
+
+// DecryptSharedBatch decrypts the ciphertexts produced from a batch of n values for another party, flooded as by
+// DecryptShared, and concatenates their slots
+func (c *CKKSKeyOwner) DecryptSharedBatch(ciphertexts []*rlwe.Ciphertext, n int, flooding NoiseFlooding) ([]float64, error) {
+	slots := 1 << c.LogSlots
+	values := make([]float64, 0, n)
+	for _, ciphertext := range ciphertexts {
+		remaining := min(n-len(values), slots)
+		if remaining <= 0 {
+			break
+		}
+		decrypted, err := c.DecryptShared(ciphertext, remaining, flooding)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, decrypted...)
+	}
+	return values, nil
+}
+
+// SharedValues opens an envelope and decrypts its values for another party, flooded as by DecryptShared
+func (c *CKKSKeyOwner) SharedValues(e *Envelope, flooding NoiseFlooding) ([]float64, error) {
+	ct, err := e.Open(c.Params, "")
+	if err != nil {
+		return nil, err
+	}
+	slots := e.Slots
+	if slots < 1 {
+		slots = 1
+	}
+	return c.DecryptShared(ct, slots, flooding)
+}
+
+// imaginaryNoise estimates the standard deviation of the decryption noise of a slot from the imaginary parts
+func imaginaryNoise(decoded []complex128) float64 {
+	sum := 0.0
+	for _, value := range decoded {
+		sum += imag(value) * imag(value)
+	}
+	return math.Sqrt(sum / float64(len(decoded)))
+}
+
+// gaussian samples centered Gaussian noise of the given standard deviation from the system randomness
+func gaussian(sigma float64) (float64, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+
+	// Box-Muller transform of two uniform values, the first one in (0, 1] to keep the logarithm finite
+	u1 := (float64(binary.BigEndian.Uint64(buf[:8])>>11) + 1) / (1 << 53)
+	u2 := float64(binary.BigEndian.Uint64(buf[8:])>>11) / (1 << 53)
+	return sigma * math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2), nil
+}
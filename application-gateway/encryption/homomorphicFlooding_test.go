@@ -0,0 +1,84 @@
+package encryption
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestDecryptShared ensures that shared decryptions are flooded with fresh noise of the configured size
+// and stay within the configured precision
+func TestDecryptShared(t *testing.T) {
+	// The decryption noise of the default parameter set is too large for the default flooding
+	helper, err := NewCKKSHelperWithOptions(HelperOptions{ParameterSet: PN15QP880})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flooding := NoiseFlooding{}
+
+	value := 3.5
+	ct := helper.EncryptPu(value)
+	first, err := helper.DecryptShared(ct, 1, flooding)
+	if err != nil {
+		t.Fatalf("DecryptShared failed: %v", err)
+	}
+	second, err := helper.DecryptShared(ct, 1, flooding)
+	if err != nil {
+		t.Fatalf("DecryptShared failed: %v", err)
+	}
+	if math.Abs(first[0]-value) > DefaultFloodingPrecision || math.Abs(second[0]-value) > DefaultFloodingPrecision {
+		t.Errorf("DecryptShared got %f and %f, expected %f within %g", first[0], second[0], value, DefaultFloodingPrecision)
+	}
+	if first[0] == second[0] {
+		t.Error("two shared decryptions of the same ciphertext are identical")
+	}
+
+	// The noise of every slot follows the configured distribution
+	slots := 1 << helper.CKKSKeyOwner.LogSlots
+	zeros, err := helper.EncryptVectorPu(make([]float64, slots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := helper.DecryptShared(zeros, slots, flooding)
+	if err != nil {
+		t.Fatalf("DecryptShared failed: %v", err)
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v * v
+	}
+	if deviation := math.Sqrt(sum / float64(slots)); math.Abs(deviation-flooding.sigma()) > 0.1*flooding.sigma() {
+		t.Errorf("flooding noise has a standard deviation of %g, expected %g", deviation, flooding.sigma())
+	}
+
+	// A batch spread over several ciphertexts is flooded slot by slot
+	batch := make([]float64, slots+3)
+	for i := range batch {
+		batch[i] = float64(i % 7)
+	}
+	batchCiphertexts, err := helper.EncryptBatchPu(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batchValues, err := helper.DecryptSharedBatch(batchCiphertexts, len(batch), flooding)
+	if err != nil {
+		t.Fatalf("DecryptSharedBatch failed: %v", err)
+	}
+	if len(batchValues) != len(batch) {
+		t.Fatalf("DecryptSharedBatch returned %d values, expected %d", len(batchValues), len(batch))
+	}
+	for i := range batch {
+		if math.Abs(batchValues[i]-batch[i]) > DefaultFloodingPrecision {
+			t.Errorf("DecryptSharedBatch slot %d: got %f, expected %f", i, batchValues[i], batch[i])
+			break
+		}
+	}
+
+	// A precision too fine to hide the decryption noise is refused
+	if _, err = helper.DecryptShared(ct, 1, NoiseFlooding{Precision: 1e-6}); !errors.Is(err, ErrFloodingPrecision) {
+		t.Errorf("DecryptShared with a precision of 1e-6 returned %v, expected %v", err, ErrFloodingPrecision)
+	}
+	if _, err = helper.DecryptShared(ct, slots+1, flooding); !errors.Is(err, ErrTooManyValues) {
+		t.Errorf("DecryptShared of %d values returned %v, expected %v", slots+1, err, ErrTooManyValues)
+	}
+}
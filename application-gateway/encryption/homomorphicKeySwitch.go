@@ -0,0 +1,215 @@
+package encryption
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/dckks"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Kinds of the messages exchanged by the threshold re-encryption
+const (
+	reEncryptionRequestKind = "re-encryption-request"
+	reEncryptionShareKind   = "re-encryption-share"
+)
+
+var (
+	ErrConsentMissing   = errors.New("owner has not consented to share the result with the lender")
+	ErrIncompleteGrant  = errors.New("consent grant must name the owner, the lender and the resource")
+	ErrResourceMismatch = errors.New("ciphertext is not the resource the consent grant names")
+)
+
+// ConsentChecker looks up, typically on the ledger, whether an owner consented to let a lender learn a resource,
+// the public key the lender published and the resource itself, so that a re-encryption only ever switches the
+// resource the owner consented to, to the key of the lender the owner consented to
+type ConsentChecker interface {
+	HasConsent(ownerID, lenderID, resourceID string) (bool, error)
+	// LenderKey returns the homomorphic public key a lender published, encoded by EncodeKey
+	LenderKey(lenderID string) (string, error)
+	// ResourceDigest returns the EnvelopeDigest of the envelope stored as a resource of an owner
+	ResourceDigest(ownerID, resourceID string) (string, error)
+}
+
+// ConsentGrant identifies the consent a re-encryption is performed under
+type ConsentGrant struct {
+	OwnerID    string `json:"OwnerID"`
+	LenderID   string `json:"LenderID"`
+	ResourceID string `json:"ResourceID"`
+}
+
+// Check returns ErrConsentMissing unless the checker confirms the grant
+func (g ConsentGrant) Check(checker ConsentChecker) error {
+	if g.OwnerID == "" || g.LenderID == "" || g.ResourceID == "" {
+		return ErrIncompleteGrant
+	}
+
+	granted, err := checker.HasConsent(g.OwnerID, g.LenderID, g.ResourceID)
+	if err != nil {
+		return fmt.Errorf("failed to look up the consent: %w", err)
+	}
+	if !granted {
+		return fmt.Errorf("%w: %s to %s for %s", ErrConsentMissing, g.OwnerID, g.LenderID, g.ResourceID)
+	}
+	return nil
+}
+
+// resolve checks the grant and that the envelope is the resource it names, and returns the ciphertext of the
+// envelope with the public key the lender published
+func (g ConsentGrant) resolve(checker ConsentChecker, envelope *Envelope, params ckks.Parameters) (*rlwe.Ciphertext, *rlwe.PublicKey, error) {
+	if err := g.Check(checker); err != nil {
+		return nil, nil, err
+	}
+
+	digest, err := checker.ResourceDigest(g.OwnerID, g.ResourceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up the resource: %w", err)
+	}
+	if envelope == nil || checksum(envelope.Payload) != digest {
+		return nil, nil, fmt.Errorf("%w: %s of %s", ErrResourceMismatch, g.ResourceID, g.OwnerID)
+	}
+	ct, err := envelope.Open(params, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encodedKey, err := checker.LenderKey(g.LenderID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up the key of %s: %w", g.LenderID, err)
+	}
+	lenderKey := rlwe.NewPublicKey(params.Parameters)
+	if err = DecodeKey(encodedKey, PublicKeyKind, params, lenderKey); err != nil {
+		return nil, nil, fmt.Errorf("key of %s: %w", g.LenderID, err)
+	}
+	return ct, lenderKey, nil
+}
+
+// EnvelopeDigest returns the digest identifying the ciphertext of an encoded envelope, as stored on the ledger
+func EnvelopeDigest(encoded string) (string, error) {
+	envelope, err := DecodeEnvelope(encoded)
+	if err != nil {
+		return "", err
+	}
+	return checksum(envelope.Payload), nil
+}
+
+// reEncryptionRequest asks a party for its share of the re-encryption of an envelope under the key of the lender
+// the grant names
+type reEncryptionRequest struct {
+	Active   []string     `json:"Active"`
+	Grant    ConsentGrant `json:"Grant"`
+	Envelope []byte       `json:"Envelope"`
+}
+
+// ReEncrypt switches an envelope encrypted under the owner's key to the public key of the lender, so that the
+// lender can decrypt it with its own secret key, once the checker confirms that the owner granted the consent.
+// The envelope must be the resource the grant names, and the lender key is the one the lender published.
+// The owner never sees the plaintext, and the share is flooded as by DecryptShared, so that the lender learns
+// nothing about the owner's key.
+func (c *CKKSKeyOwner) ReEncrypt(envelope *Envelope, grant ConsentGrant, consent ConsentChecker) (*rlwe.Ciphertext, error) {
+	ct, lenderKey, err := grant.resolve(consent, envelope, c.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	pcks := dckks.NewPCKSProtocol(c.Params, rlwe.DefaultSigma)
+	share := pcks.AllocateShare(ct.Level())
+	pcks.GenShare(c.secretKey, lenderKey, ct, share)
+	if err := floodShare(c.Params, NoiseFlooding{}.smudgingSigma(c.Params), share.Value[0], ct.Level(), ct.IsNTT); err != nil {
+		return nil, err
+	}
+
+	result := ckks.NewCiphertext(c.Params, 1, ct.Level())
+	pcks.KeySwitch(ct, share, result)
+	return result, nil
+}
+
+// ReEncrypt switches an envelope encrypted under the collective key to the public key of the lender the grant names
+// Each of the first Threshold active parties checks the consent, the envelope and the lender key on its own before
+// contributing its share, so the aggregator alone cannot hand a result over to a lender the owner did not consent to.
+func (a *ThresholdAggregator) ReEncrypt(envelope *Envelope, grant ConsentGrant, active []string) (*rlwe.Ciphertext, error) {
+	active, err := a.activeParties(active)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := envelope.Open(a.Params, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask the active parties for their re-encryption shares
+	data, err := envelope.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	request, err := json.Marshal(reEncryptionRequest{Active: active, Grant: grant, Envelope: data})
+	if err != nil {
+		return nil, err
+	}
+	for _, party := range active {
+		if err = a.transport.Send(Message{From: ThresholdAggregatorName, To: party, Kind: reEncryptionRequestKind, Data: request}); err != nil {
+			return nil, err
+		}
+	}
+
+	pcks := dckks.NewPCKSProtocol(a.Params, rlwe.DefaultSigma)
+	combined := pcks.AllocateShare(ct.Level())
+	if err = aggregateShares(a.transport, reEncryptionShareKind, active, func() *drlwe.PCKSShare { return new(drlwe.PCKSShare) }, combined, func(share *drlwe.PCKSShare) {
+		pcks.AggregateShares(combined, share, combined)
+	}); err != nil {
+		return nil, err
+	}
+
+	result := ckks.NewCiphertext(a.Params, 1, ct.Level())
+	pcks.KeySwitch(ct, combined, result)
+	return result, nil
+}
+
+// ServeReEncryption waits for a re-encryption request from the aggregator and answers it with the party's share,
+// only after checking on its own that the owner granted the consent the request refers to, that the envelope is the
+// resource it names, and reading the lender key from the checker
+func (p *ThresholdParty) ServeReEncryption(consent ConsentChecker) error {
+	if p.thresholdShare == nil {
+		return ErrKeysNotGenerated
+	}
+
+	msg, err := p.transport.Receive(p.Name, reEncryptionRequestKind)
+	if err != nil {
+		return err
+	}
+	if msg.From != ThresholdAggregatorName {
+		return fmt.Errorf("%w: %s", ErrUnexpectedSender, msg.From)
+	}
+
+	var request reEncryptionRequest
+	if err = json.Unmarshal(msg.Data, &request); err != nil {
+		return fmt.Errorf("malformed re-encryption request: %w", err)
+	}
+	var envelope *Envelope
+	if len(request.Envelope) > 0 {
+		envelope = new(Envelope)
+		if err = envelope.UnmarshalBinary(request.Envelope); err != nil {
+			return fmt.Errorf("malformed re-encryption request: %w", err)
+		}
+	}
+	ct, lenderKey, err := request.Grant.resolve(consent, envelope, p.Params)
+	if err != nil {
+		return err
+	}
+
+	additiveShare, err := p.additiveShare(request.Active)
+	if err != nil {
+		return err
+	}
+
+	pcks := dckks.NewPCKSProtocol(p.Params, rlwe.DefaultSigma)
+	share := pcks.AllocateShare(ct.Level())
+	pcks.GenShare(additiveShare, lenderKey, ct, share)
+	if err = floodShare(p.Params, p.config.smudgingSigma(p.Params), share.Value[0], ct.Level(), ct.IsNTT); err != nil {
+		return err
+	}
+	return p.send(ThresholdAggregatorName, reEncryptionShareKind, share)
+}
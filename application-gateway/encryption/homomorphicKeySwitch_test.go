@@ -0,0 +1,172 @@
+package encryption
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+)
+
+// consentLedger is an in-memory ConsentChecker standing in for the ledger
+type consentLedger struct {
+	grants    map[ConsentGrant]bool
+	keys      map[string]string
+	resources map[ConsentGrant]string
+}
+
+func (l consentLedger) HasConsent(ownerID, lenderID, resourceID string) (bool, error) {
+	return l.grants[ConsentGrant{OwnerID: ownerID, LenderID: lenderID, ResourceID: resourceID}], nil
+}
+
+func (l consentLedger) LenderKey(lenderID string) (string, error) {
+	if key, ok := l.keys[lenderID]; ok {
+		return key, nil
+	}
+	return "", errors.New("no key published")
+}
+
+func (l consentLedger) ResourceDigest(ownerID, resourceID string) (string, error) {
+	if digest, ok := l.resources[ConsentGrant{OwnerID: ownerID, ResourceID: resourceID}]; ok {
+		return digest, nil
+	}
+	return "", errors.New("no such resource")
+}
+
+// newConsentLedger returns a ledger where the lender published its key and the envelope is stored as the resource
+// of the grant, the grant being consented to or not
+func newConsentLedger(t *testing.T, grant ConsentGrant, consented bool, lender *CKKSHelper, envelope *Envelope) consentLedger {
+	lenderKey, err := lender.EncodedPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := EncodeEnvelope(envelope, EnvelopeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := EnvelopeDigest(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return consentLedger{
+		grants:    map[ConsentGrant]bool{grant: consented},
+		keys:      map[string]string{grant.LenderID: lenderKey},
+		resources: map[ConsentGrant]string{{OwnerID: grant.OwnerID, ResourceID: grant.ResourceID}: digest},
+	}
+}
+
+// TestReEncrypt ensures that a result is switched to the lender's key only once the owner consented, and only for
+// the resource the consent names
+func TestReEncrypt(t *testing.T) {
+	owner := NewCKKSHelper()
+	lender := NewCKKSHelper()
+	grant := ConsentGrant{OwnerID: "owner", LenderID: "lender", ResourceID: "score"}
+
+	value := 0.75
+	envelope, err := owner.Seal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := newConsentLedger(t, grant, true, lender, envelope)
+
+	if _, err := owner.ReEncrypt(envelope, grant, newConsentLedger(t, grant, false, lender, envelope)); !errors.Is(err, ErrConsentMissing) {
+		t.Errorf("ReEncrypt without consent returned %v, expected %v", err, ErrConsentMissing)
+	}
+	if _, err := owner.ReEncrypt(envelope, ConsentGrant{OwnerID: "owner"}, ledger); !errors.Is(err, ErrIncompleteGrant) {
+		t.Errorf("ReEncrypt with an incomplete grant returned %v, expected %v", err, ErrIncompleteGrant)
+	}
+	other, err := owner.Seal(-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := owner.ReEncrypt(other, grant, ledger); !errors.Is(err, ErrResourceMismatch) {
+		t.Errorf("ReEncrypt of another ciphertext returned %v, expected %v", err, ErrResourceMismatch)
+	}
+
+	switched, err := owner.ReEncrypt(envelope, grant, ledger)
+	if err != nil {
+		t.Fatalf("ReEncrypt failed: %v", err)
+	}
+	// The re-encrypted value is flooded like a shared decryption
+	if result := lender.Decrypt(switched); math.Abs(result-value) > DefaultFloodingPrecision {
+		t.Errorf("lender decrypted %f, expected %f", result, value)
+	}
+	if result := owner.Decrypt(switched); math.Abs(result-value) < DefaultFloodingPrecision {
+		t.Error("owner still decrypts the re-encrypted result")
+	}
+}
+
+// TestThresholdReEncrypt ensures that the parties of a threshold key each check the consent before re-encrypting
+func TestThresholdReEncrypt(t *testing.T) {
+	config := ThresholdConfig{
+		Parties:   []string{"owner", "issuer", "government"},
+		Threshold: 2,
+		Seed:      []byte("re-encryption test"),
+	}
+	aggregator, parties := newThresholdTestSetup(t, config)
+	lender := NewCKKSHelper()
+	grant := ConsentGrant{OwnerID: "owner", LenderID: "lender", ResourceID: "score"}
+
+	encryptor, err := aggregator.Encryptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := -1.5
+	envelope, err := encryptor.Seal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := newConsentLedger(t, grant, true, lender, envelope)
+
+	active := []string{"issuer", "government"}
+	var wg sync.WaitGroup
+	for _, name := range active {
+		wg.Add(1)
+		go func(party *ThresholdParty) {
+			defer wg.Done()
+			if err := party.ServeReEncryption(ledger); err != nil {
+				t.Errorf("%s failed to serve the re-encryption: %v", party.Name, err)
+			}
+		}(parties[name])
+	}
+	switched, err := aggregator.ReEncrypt(envelope, grant, active)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("ReEncrypt failed: %v", err)
+	}
+	if result := lender.Decrypt(switched); math.Abs(result-value) > DefaultFloodingPrecision*math.Sqrt(float64(config.Threshold)) {
+		t.Errorf("lender decrypted %f, expected %f", result, value)
+	}
+
+	// A party refuses to contribute to a re-encryption the owner did not consent to
+	refused := make(chan error, 1)
+	withoutConsent := newConsentLedger(t, grant, false, lender, envelope)
+	go func() { refused <- parties["owner"].ServeReEncryption(withoutConsent) }()
+	if err = aggregator.transport.Send(Message{From: ThresholdAggregatorName, To: "owner", Kind: reEncryptionRequestKind, Data: []byte(`{"Grant":{"OwnerID":"owner","LenderID":"lender","ResourceID":"score"}}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-refused; !errors.Is(err, ErrConsentMissing) {
+		t.Errorf("ServeReEncryption without consent returned %v, expected %v", err, ErrConsentMissing)
+	}
+
+	// Nor to the re-encryption of another ciphertext than the resource the consent names
+	other, err := encryptor.Seal(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherData, err := other.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := json.Marshal(reEncryptionRequest{Active: active, Grant: grant, Envelope: otherData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { refused <- parties["issuer"].ServeReEncryption(ledger) }()
+	if err = aggregator.transport.Send(Message{From: ThresholdAggregatorName, To: "issuer", Kind: reEncryptionRequestKind, Data: request}); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-refused; !errors.Is(err, ErrResourceMismatch) {
+		t.Errorf("ServeReEncryption of another ciphertext returned %v, expected %v", err, ErrResourceMismatch)
+	}
+}
@@ -0,0 +1,271 @@
+package encryption
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// KeyFileVersion is the version of the key file format written by WriteKeyFile
+const KeyFileVersion = 1
+
+// Kinds of key material that can be stored in a key file
+const (
+	SecretKeyKind          = "ckks-secret-key"
+	PublicKeyKind          = "ckks-public-key"
+	RelinearizationKeyKind = "ckks-relinearization-key"
+)
+
+// File names used by ExportKeys and LoadCKKSHelper inside a key directory
+const (
+	SecretKeyFile          = "secret.key"
+	PublicKeyFile          = "public.key"
+	RelinearizationKeyFile = "relinearization.key"
+)
+
+var (
+	ErrParamsMismatch     = errors.New("key was generated under different CKKS parameters")
+	ErrKeyKindMismatch    = errors.New("key file holds a different kind of key")
+	ErrUnsupportedVersion = errors.New("unsupported key file version")
+)
+
+// keyFile is the on-disk representation of a single serialized key
+type keyFile struct {
+	Version     int    `json:"Version"`
+	Kind        string `json:"Kind"`
+	Fingerprint string `json:"Fingerprint"`
+	Data        []byte `json:"Data"`
+}
+
+// ParamsFingerprint returns a short digest identifying a CKKS parameter set
+func ParamsFingerprint(params ckks.Parameters) (string, error) {
+	data, err := params.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// WriteKeyFile serializes a key together with its kind and the fingerprint of the parameters it belongs to
+func WriteKeyFile(path string, kind string, params ckks.Parameters, key encoding.BinaryMarshaler) error {
+	fileJSON, err := marshalKey(kind, params, key)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, fileJSON, 0600)
+}
+
+// ReadKeyFile reads a key written by WriteKeyFile into key, refusing files of another kind or parameter set
+func ReadKeyFile(path string, kind string, params ckks.Parameters, key encoding.BinaryUnmarshaler) error {
+	fileJSON, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err = unmarshalKey(fileJSON, kind, params, key); err != nil {
+		return fmt.Errorf("key file %s: %w", path, err)
+	}
+	return nil
+}
+
+// EncodeKey serializes a key like WriteKeyFile does, as a base64 string that can be published on the ledger
+func EncodeKey(kind string, params ckks.Parameters, key encoding.BinaryMarshaler) (string, error) {
+	fileJSON, err := marshalKey(kind, params, key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(fileJSON), nil
+}
+
+// DecodeKey reads a key encoded by EncodeKey into key, refusing keys of another kind or parameter set
+func DecodeKey(encoded string, kind string, params ckks.Parameters, key encoding.BinaryUnmarshaler) error {
+	fileJSON, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("encoded key is malformed: %w", err)
+	}
+	return unmarshalKey(fileJSON, kind, params, key)
+}
+
+// marshalKey serializes a key together with its kind and the fingerprint of the parameters it belongs to
+func marshalKey(kind string, params ckks.Parameters, key encoding.BinaryMarshaler) ([]byte, error) {
+	fingerprint, err := ParamsFingerprint(params)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := key.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", kind, err)
+	}
+
+	return json.Marshal(keyFile{
+		Version:     KeyFileVersion,
+		Kind:        kind,
+		Fingerprint: fingerprint,
+		Data:        data,
+	})
+}
+
+// unmarshalKey reads a key serialized by marshalKey into key
+func unmarshalKey(fileJSON []byte, kind string, params ckks.Parameters, key encoding.BinaryUnmarshaler) error {
+	var file keyFile
+	if err := json.Unmarshal(fileJSON, &file); err != nil {
+		return fmt.Errorf("key is malformed: %w", err)
+	}
+
+	if file.Version != KeyFileVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, file.Version)
+	}
+	if file.Kind != kind {
+		return fmt.Errorf("%w: expected %s, got %s", ErrKeyKindMismatch, kind, file.Kind)
+	}
+
+	fingerprint, err := ParamsFingerprint(params)
+	if err != nil {
+		return err
+	}
+	if file.Fingerprint != fingerprint {
+		return fmt.Errorf("%w: expected %s, got %s", ErrParamsMismatch, fingerprint, file.Fingerprint)
+	}
+
+	return key.UnmarshalBinary(file.Data)
+}
+
+// ExportKeys writes the secret, public and relinearization keys of the helper into dir
+func (c *CKKSHelper) ExportKeys(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if err := WriteKeyFile(filepath.Join(dir, SecretKeyFile), SecretKeyKind, c.Params, c.secretKey); err != nil {
+		return err
+	}
+	if err := WriteKeyFile(filepath.Join(dir, PublicKeyFile), PublicKeyKind, c.Params, c.publicKey); err != nil {
+		return err
+	}
+	return WriteKeyFile(filepath.Join(dir, RelinearizationKeyFile), RelinearizationKeyKind, c.Params, c.Relinearizer)
+}
+
+// LoadCKKSHelper initializes a CKKSHelper from keys previously written by ExportKeys
+// opts must select the parameters the keys were generated under
+func LoadCKKSHelper(dir string, opts HelperOptions) (*CKKSHelper, error) {
+	params, policy, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	sk, err := readSecretKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := readPublicKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rlk, err := readRelinearizationKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCKKSHelper(params, policy, sk, pk, rlk)
+}
+
+// LoadCKKSKeyOwner initializes the key owner role from the secret key in dir
+func LoadCKKSKeyOwner(dir string, opts HelperOptions) (*CKKSKeyOwner, error) {
+	params, _, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	sk, err := readSecretKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+	return newCKKSKeyOwner(params, ckks.NewEncoder(params), sk), nil
+}
+
+// LoadCKKSEncryptor initializes the encryptor role from the public key in dir
+func LoadCKKSEncryptor(dir string, opts HelperOptions) (*CKKSEncryptor, error) {
+	params, _, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := readPublicKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+	return newCKKSEncryptor(params, ckks.NewEncoder(params), pk), nil
+}
+
+// EncodedPublicKey returns the public key of the helper encoded by EncodeKey, as published in User.PublicKey
+func (c *CKKSHelper) EncodedPublicKey() (string, error) {
+	return EncodeKey(PublicKeyKind, c.Params, c.publicKey)
+}
+
+// DecodeCKKSEncryptor initializes the encryptor role from a public key encoded by EncodeKey, such as the one
+// a user published on the ledger, so that values are encrypted to that user only
+func DecodeCKKSEncryptor(encoded string, opts HelperOptions) (*CKKSEncryptor, error) {
+	params, _, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	pk := rlwe.NewPublicKey(params.Parameters)
+	if err = DecodeKey(encoded, PublicKeyKind, params, pk); err != nil {
+		return nil, err
+	}
+	return newCKKSEncryptor(params, ckks.NewEncoder(params), pk), nil
+}
+
+// LoadCKKSEvaluator initializes the evaluator role from the relinearization key in dir
+// The secret key file is never read, so dir only needs to contain the evaluation keys
+func LoadCKKSEvaluator(dir string, opts HelperOptions) (*CKKSEvaluator, error) {
+	params, policy, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	rlk, err := readRelinearizationKey(dir, params)
+	if err != nil {
+		return nil, err
+	}
+	return newCKKSEvaluator(params, policy, ckks.NewEncoder(params), rlk), nil
+}
+
+func readSecretKey(dir string, params ckks.Parameters) (*rlwe.SecretKey, error) {
+	sk := rlwe.NewSecretKey(params.Parameters)
+	if err := ReadKeyFile(filepath.Join(dir, SecretKeyFile), SecretKeyKind, params, sk); err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+func readPublicKey(dir string, params ckks.Parameters) (*rlwe.PublicKey, error) {
+	pk := rlwe.NewPublicKey(params.Parameters)
+	if err := ReadKeyFile(filepath.Join(dir, PublicKeyFile), PublicKeyKind, params, pk); err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+func readRelinearizationKey(dir string, params ckks.Parameters) (*rlwe.RelinearizationKey, error) {
+	rlk := new(rlwe.RelinearizationKey)
+	if err := ReadKeyFile(filepath.Join(dir, RelinearizationKeyFile), RelinearizationKeyKind, params, rlk); err != nil {
+		return nil, err
+	}
+	return rlk, nil
+}
@@ -0,0 +1,129 @@
+package encryption
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+// TestExportLoadKeys ensures that a ciphertext can be decrypted by a helper loaded from exported keys
+func TestExportLoadKeys(t *testing.T) {
+	helper := NewCKKSHelper()
+	dir := t.TempDir()
+
+	originalValue := 42.0
+	ciphertext := helper.EncryptPu(originalValue)
+
+	if err := helper.ExportKeys(dir); err != nil {
+		t.Fatalf("ExportKeys failed: %v", err)
+	}
+
+	loaded, err := LoadCKKSHelper(dir, HelperOptions{})
+	if err != nil {
+		t.Fatalf("LoadCKKSHelper failed: %v", err)
+	}
+
+	if loaded.Fingerprint != helper.Fingerprint {
+		t.Errorf("fingerprint mismatch. Got %s, expected %s", loaded.Fingerprint, helper.Fingerprint)
+	}
+
+	// Decrypt the ciphertext with the loaded keys
+	decryptedValue := loaded.Decrypt(ciphertext)
+	if math.Abs(decryptedValue-originalValue) > precision {
+		t.Errorf("Decrypt with loaded keys failed. Got %f, expected %f", decryptedValue, originalValue)
+	}
+
+	// Encrypt with the loaded public key and decrypt with the original helper
+	decryptedValue = helper.Decrypt(loaded.EncryptPu(originalValue))
+	if math.Abs(decryptedValue-originalValue) > precision {
+		t.Errorf("Decrypt of loaded encryption failed. Got %f, expected %f", decryptedValue, originalValue)
+	}
+}
+
+// TestReadKeyFileRejectsOtherParams ensures that keys created under different parameters are refused
+func TestReadKeyFileRejectsOtherParams(t *testing.T) {
+	helper := NewCKKSHelper()
+	path := filepath.Join(t.TempDir(), SecretKeyFile)
+
+	if err := WriteKeyFile(path, SecretKeyKind, helper.Params, helper.secretKey); err != nil {
+		t.Fatalf("WriteKeyFile failed: %v", err)
+	}
+
+	otherParams, err := ckks.NewParametersFromLiteral(ckks.PN12QP109)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ReadKeyFile(path, SecretKeyKind, otherParams, helper.secretKey)
+	if !errors.Is(err, ErrParamsMismatch) {
+		t.Errorf("ReadKeyFile with other parameters returned %v, expected %v", err, ErrParamsMismatch)
+	}
+
+	err = ReadKeyFile(path, PublicKeyKind, helper.Params, helper.publicKey)
+	if !errors.Is(err, ErrKeyKindMismatch) {
+		t.Errorf("ReadKeyFile with another kind returned %v, expected %v", err, ErrKeyKindMismatch)
+	}
+}
+
+// TestSeparateRoles ensures that each role can be loaded from its own key file and that
+// the evaluator role alone is enough to run the credit evaluation
+func TestSeparateRoles(t *testing.T) {
+	helper := NewCKKSHelper()
+	dir := t.TempDir()
+	if err := helper.ExportKeys(dir); err != nil {
+		t.Fatalf("ExportKeys failed: %v", err)
+	}
+
+	encryptor, err := LoadCKKSEncryptor(dir, HelperOptions{})
+	if err != nil {
+		t.Fatalf("LoadCKKSEncryptor failed: %v", err)
+	}
+	evaluator, err := LoadCKKSEvaluator(dir, HelperOptions{})
+	if err != nil {
+		t.Fatalf("LoadCKKSEvaluator failed: %v", err)
+	}
+	owner, err := LoadCKKSKeyOwner(dir, HelperOptions{})
+	if err != nil {
+		t.Fatalf("LoadCKKSKeyOwner failed: %v", err)
+	}
+
+	creditScore, dti := 800.0, 0.5
+	resultCiphertext, err := evaluator.CreditEvaluation(nil, nil, encryptor.EncryptPu(creditScore), encryptor.EncryptPu(dti))
+	if err != nil {
+		t.Fatalf("CreditEvaluation failed: %v", err)
+	}
+
+	result := owner.Decrypt(resultCiphertext)
+	expected := W0*(creditScore-MinCreditScore)/(MaxCreditScore-MinCreditScore) + W1/dti
+	if math.Abs(result-expected) > 0.01 {
+		t.Errorf("CreditEvaluation with separate roles failed. Got %f, expected %f", result, expected)
+	}
+}
+
+// TestPublishedPublicKey ensures that values encrypted under a published public key are decrypted by its owner only
+func TestPublishedPublicKey(t *testing.T) {
+	owner := NewCKKSHelper()
+	encoded, err := owner.EncodedPublicKey()
+	if err != nil {
+		t.Fatalf("EncodedPublicKey failed: %v", err)
+	}
+
+	encryptor, err := DecodeCKKSEncryptor(encoded, HelperOptions{})
+	if err != nil {
+		t.Fatalf("DecodeCKKSEncryptor failed: %v", err)
+	}
+	value := 650.0
+	if result := owner.Decrypt(encryptor.EncryptPu(value)); math.Abs(result-value) > precision {
+		t.Errorf("owner decrypted %f, expected %f", result, value)
+	}
+
+	if _, err = DecodeCKKSEncryptor(encoded, HelperOptions{ParameterSet: PN15QP880}); !errors.Is(err, ErrParamsMismatch) {
+		t.Errorf("DecodeCKKSEncryptor with other parameters returned %v, expected %v", err, ErrParamsMismatch)
+	}
+	if _, err = DecodeCKKSEncryptor("not a key", HelperOptions{}); err == nil {
+		t.Error("DecodeCKKSEncryptor accepted a malformed key")
+	}
+}
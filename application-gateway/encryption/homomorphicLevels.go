@@ -0,0 +1,65 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// ErrDepthExhausted is returned when a computation needs more levels than a ciphertext has left
+var ErrDepthExhausted = errors.New("ciphertext has not enough levels left")
+
+// scaleTolerance is the largest relative difference between two scales that can be ignored when adding ciphertexts
+const scaleTolerance = 1e-12
+
+// RemainingDepth returns the number of multiplications a ciphertext can still go through before it must be decrypted
+func (c *CKKSEvaluator) RemainingDepth(ct *rlwe.Ciphertext) int {
+	return ct.Level()
+}
+
+// CheckDepth returns ErrDepthExhausted when the ciphertexts do not all have depth levels left
+func (c *CKKSEvaluator) CheckDepth(depth int, cts ...*rlwe.Ciphertext) error {
+	for _, ct := range cts {
+		if ct != nil && ct.Level() < depth {
+			return fmt.Errorf("%w: needs %d levels, %d left", ErrDepthExhausted, depth, ct.Level())
+		}
+	}
+	return nil
+}
+
+// rescale divides a ciphertext by the last moduli of its chain until its scale is back around the default scale
+func (c *CKKSEvaluator) rescale(ct *rlwe.Ciphertext) error {
+	if ct.Level() == 0 {
+		return fmt.Errorf("%w: cannot rescale at level 0", ErrDepthExhausted)
+	}
+	return c.Evaluator.Rescale(ct, c.Scale, ct)
+}
+
+// alignOperands brings two ciphertexts to the same level and scale so that they can be added
+// The evaluator only corrects integer scale ratios by itself; any other ratio would silently change the sum,
+// so the operand with the most levels left is first moved onto the scale of the other, which costs it one level.
+func (c *CKKSEvaluator) alignOperands(ct1, ct2 *rlwe.Ciphertext) (*rlwe.Ciphertext, *rlwe.Ciphertext, error) {
+	if ratio := ct1.Scale.Float64() / ct2.Scale.Float64(); math.Abs(ratio-1) > scaleTolerance {
+		// The operand is only replaced once aligned, so that both scales are still there to report a failure
+		target, other := &ct1, ct2
+		if ct1.Level() < ct2.Level() {
+			target, other = &ct2, ct1
+		}
+		aligned, err := c.multByConstToScale(*target, 1, other.Scale)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot align scales %g and %g: %w", ct1.Scale.Float64(), ct2.Scale.Float64(), err)
+		}
+		*target = aligned
+	}
+
+	// Drop the operand with the most levels to the level of the other
+	if ct1.Level() > ct2.Level() {
+		ct1 = c.Evaluator.DropLevelNew(ct1, ct1.Level()-ct2.Level())
+	} else if ct2.Level() > ct1.Level() {
+		ct2 = c.Evaluator.DropLevelNew(ct2, ct2.Level()-ct1.Level())
+	}
+
+	return ct1, ct2, nil
+}
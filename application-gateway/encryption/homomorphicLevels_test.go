@@ -0,0 +1,86 @@
+package encryption
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// TestAddAlignsOperands ensures that ciphertexts at different levels and scales are added correctly
+func TestAddAlignsOperands(t *testing.T) {
+	helper := NewCKKSHelper()
+
+	value1, value2, value3 := 1000.0, 2.0, 3.25
+	fresh := helper.EncryptPu(value1)
+
+	// The product is one level down and its scale drifted away from the default scale
+	product, err := helper.Multiply(helper.EncryptPu(value2), helper.EncryptPu(value3))
+	if err != nil {
+		t.Fatalf("Multiply failed: %v", err)
+	}
+	if product.Scale.Float64() == fresh.Scale.Float64() {
+		t.Fatalf("expected the product scale to differ from the default scale")
+	}
+
+	// Both orders, so that either operand gets aligned on the other
+	for _, operands := range [][2]*rlwe.Ciphertext{{fresh, product}, {product, fresh}} {
+		sum, err := helper.Add(operands[0], operands[1])
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+
+		// Adding without aligning the scales would be off by about value1 * 1e-4
+		result := helper.Decrypt(sum)
+		expected := value1 + value2*value3
+		if math.Abs(result-expected) > 1e-3 {
+			t.Errorf("Add of misaligned operands failed. Got %f, expected %f", result, expected)
+		}
+	}
+}
+
+// TestAddWithPlainKeepsLevel ensures that adding a plaintext does not consume a level
+func TestAddWithPlainKeepsLevel(t *testing.T) {
+	helper := NewCKKSHelper()
+
+	ct := helper.EncryptPu(3.5)
+	result := helper.AddWithPlain(ct, 2)
+	if result.Level() != ct.Level() {
+		t.Errorf("AddWithPlain consumed a level: %d -> %d", ct.Level(), result.Level())
+	}
+	if value := helper.Decrypt(result); math.Abs(value-5.5) > precision {
+		t.Errorf("AddWithPlain failed. Got %f, expected %f", value, 5.5)
+	}
+}
+
+// TestDepthExhausted ensures that running out of levels returns ErrDepthExhausted instead of a wrong result
+func TestDepthExhausted(t *testing.T) {
+	helper := NewCKKSHelper()
+
+	ct := helper.EncryptPu(2)
+	if depth := helper.RemainingDepth(ct); depth != helper.Params.MaxLevel() {
+		t.Errorf("fresh ciphertext has %d levels left, expected %d", depth, helper.Params.MaxLevel())
+	}
+
+	exhausted := helper.Evaluator.DropLevelNew(ct, ct.Level())
+	if _, err := helper.Multiply(exhausted, ct); !errors.Is(err, ErrDepthExhausted) {
+		t.Errorf("Multiply at level 0 returned %v, expected %v", err, ErrDepthExhausted)
+	}
+	if _, err := helper.Inverse(exhausted, 1, 4, 3); !errors.Is(err, ErrDepthExhausted) {
+		t.Errorf("Inverse at level 0 returned %v, expected %v", err, ErrDepthExhausted)
+	}
+
+	// Operands at level 0 can not be moved onto each other's scale
+	rescaled := exhausted.CopyNew()
+	rescaled.Scale = rlwe.NewScale(1.5 * exhausted.Scale.Float64())
+	if _, err := helper.Add(exhausted, rescaled); !errors.Is(err, ErrDepthExhausted) {
+		t.Errorf("Add of misaligned operands at level 0 returned %v, expected %v", err, ErrDepthExhausted)
+	}
+
+	// The credit evaluation is refused upfront when the inputs cannot hold its whole circuit
+	lowered := helper.Evaluator.DropLevelNew(ct, 2)
+	if _, err := helper.CreditEvaluation(nil, nil, lowered, lowered); !errors.Is(err, ErrDepthExhausted) {
+		t.Errorf("CreditEvaluation without enough levels returned %v, expected %v", err, ErrDepthExhausted)
+	}
+}
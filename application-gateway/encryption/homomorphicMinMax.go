@@ -0,0 +1,152 @@
+package encryption
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// The operations below are built from the approximate sign of a difference, so their precision follows the
+// comparison policy: when the two operands are further apart than policy.Margin() times the declared bound,
+// the error is at most SignPrecision times their distance; closer than that, it is at most their distance.
+// Each operation consumes policy.Depth() + 1 levels.
+
+// Abs computes the absolute value of encrypted values declared to lie in [-bound, bound], as x * sign(x)
+func (c *CKKSEvaluator) Abs(ct *rlwe.Ciphertext, bound float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	sign, err := c.Sign(ct, bound, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Multiply(ct, sign)
+}
+
+// Max computes the slot-wise maximum of two ciphertexts at the same scale whose difference is declared to lie in [-bound, bound]
+func (c *CKKSEvaluator) Max(ct1, ct2 *rlwe.Ciphertext, bound float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	return c.selectGreater(ct1, ct2, bound, policy, true)
+}
+
+// Min computes the slot-wise minimum of two ciphertexts at the same scale whose difference is declared to lie in [-bound, bound]
+func (c *CKKSEvaluator) Min(ct1, ct2 *rlwe.Ciphertext, bound float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	return c.selectGreater(ct1, ct2, bound, policy, false)
+}
+
+// MaxPlain computes max(ct, value) for encrypted values declared to lie in [lo, hi]
+func (c *CKKSEvaluator) MaxPlain(ct *rlwe.Ciphertext, value, lo, hi float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	// max(x, value) = value + (x - value) * step(x - value)
+	return c.Clamp(ct, value, math.Max(hi, value), lo, hi, policy)
+}
+
+// MinPlain computes min(ct, value) for encrypted values declared to lie in [lo, hi]
+func (c *CKKSEvaluator) MinPlain(ct *rlwe.Ciphertext, value, lo, hi float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	// min(x, value) = value + (x - value) * (1 - step(x - value))
+	return c.Clamp(ct, math.Min(lo, value), value, lo, hi, policy)
+}
+
+// Clamp brings encrypted values declared to lie in [inputLo, inputHi] into [lo, hi]
+// Both bounds are compared in parallel, so clamping costs a single comparison depth.
+func (c *CKKSEvaluator) Clamp(ct *rlwe.Ciphertext, lo, hi, inputLo, inputHi float64, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	if lo > hi {
+		return nil, fmt.Errorf("%w: clamp to [%g, %g]", ErrComparisonRange, lo, hi)
+	}
+	if inputLo > inputHi {
+		inputLo, inputHi = inputHi, inputLo
+	}
+
+	// clamp(x) = lo + (x - lo) * step(x - lo) - (x - hi) * step(x - hi)
+	// A bound outside the input range is never crossed and its term is dropped, in which case
+	// clamp(x) = lo + (x - lo) * step(x - lo) or clamp(x) = hi + (x - hi) * (1 - step(x - hi)).
+	clampLo, clampHi := lo > inputLo, hi < inputHi
+
+	var result *rlwe.Ciphertext
+	var constant float64
+	switch {
+	case clampLo && clampHi:
+		loTerm, err := c.stepTerm(ct, lo, inputLo, inputHi, policy, false)
+		if err != nil {
+			return nil, err
+		}
+		hiTerm, err := c.stepTerm(ct, hi, inputLo, inputHi, policy, false)
+		if err != nil {
+			return nil, err
+		}
+
+		// Both terms share the same level and scale, so they can be subtracted before rescaling
+		result = c.Evaluator.SubNew(loTerm, hiTerm)
+		constant = lo
+	case clampLo:
+		var err error
+		if result, err = c.stepTerm(ct, lo, inputLo, inputHi, policy, false); err != nil {
+			return nil, err
+		}
+		constant = lo
+	case clampHi:
+		var err error
+		if result, err = c.stepTerm(ct, hi, inputLo, inputHi, policy, true); err != nil {
+			return nil, err
+		}
+		constant = hi
+	default:
+		// Nothing to clamp
+		return ct.CopyNew(), nil
+	}
+
+	if err := c.rescale(result); err != nil {
+		return nil, err
+	}
+	c.Evaluator.AddConst(result, constant, result)
+	return result, nil
+}
+
+// stepTerm returns (x - threshold) * step(x - threshold) before rescaling,
+// or (x - threshold) * (1 - step(x - threshold)) when complement is set
+func (c *CKKSEvaluator) stepTerm(ct *rlwe.Ciphertext, threshold, lo, hi float64, policy ComparisonPolicy, complement bool) (*rlwe.Ciphertext, error) {
+	step, err := c.GreaterThan(ct, threshold, lo, hi, policy)
+	if err != nil {
+		return nil, err
+	}
+	if complement {
+		c.Evaluator.Neg(step, step)
+		c.Evaluator.AddConst(step, 1, step)
+	}
+
+	// The product is rescaled by the caller, so the step must keep a level for it
+	if step, err = c.refresh(step, 1); err != nil {
+		return nil, err
+	}
+
+	difference := c.Evaluator.AddConstNew(ct, -threshold)
+	return c.Evaluator.MulRelinNew(difference, step), nil
+}
+
+// selectGreater returns ct1 * s + ct2 * (1 - s) with s = step(ct1 - ct2), which is the maximum when greater is set
+// and the minimum otherwise
+func (c *CKKSEvaluator) selectGreater(ct1, ct2 *rlwe.Ciphertext, bound float64, policy ComparisonPolicy, greater bool) (*rlwe.Ciphertext, error) {
+	if bound <= 0 {
+		return nil, fmt.Errorf("%w: bound %g", ErrComparisonRange, bound)
+	}
+
+	step, err := c.compare(c.Evaluator.SubNew(ct1, ct2), 0, bound, policy, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if step, err = c.refresh(step, 1); err != nil {
+		return nil, err
+	}
+
+	complement := c.Evaluator.NegNew(step)
+	c.Evaluator.AddConst(complement, 1, complement)
+	if !greater {
+		step, complement = complement, step
+	}
+
+	// Both products share the same level and scale, so they can be added before rescaling
+	result := c.Evaluator.MulRelinNew(ct1, step)
+	c.Evaluator.Add(result, c.Evaluator.MulRelinNew(ct2, complement), result)
+	if err = c.rescale(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
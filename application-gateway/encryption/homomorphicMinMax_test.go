@@ -0,0 +1,112 @@
+package encryption
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"math"
+	"testing"
+)
+
+// TestMinMaxAbs ensures that the encrypted min, max, clamp and absolute value agree with their plaintext versions
+func TestMinMaxAbs(t *testing.T) {
+	policy := ComparisonPolicy{Expansions: 3, Refinements: 2}
+	helper := newDeepTestHelper(t, ScoringPolicy{DTIInverseIterations: 1, DTIClamp: policy})
+
+	values1 := []float64{-7.5, -1, 0.5, 3, 9.25}
+	values2 := []float64{2, -3, 4, 3.5, -9}
+	ct1, err := helper.EncryptVectorPu(values1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct2, err := helper.EncryptVectorPu(values2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abs, err := helper.Abs(ct1, 10, policy)
+	if err != nil {
+		t.Fatalf("Abs failed: %v", err)
+	}
+	max, err := helper.Max(ct1, ct2, 20, policy)
+	if err != nil {
+		t.Fatalf("Max failed: %v", err)
+	}
+	min, err := helper.Min(ct1, ct2, 20, policy)
+	if err != nil {
+		t.Fatalf("Min failed: %v", err)
+	}
+	clamp, err := helper.Clamp(ct1, -2, 4, -10, 10, policy)
+	if err != nil {
+		t.Fatalf("Clamp failed: %v", err)
+	}
+	maxPlain, err := helper.MaxPlain(ct1, 1, -10, 10, policy)
+	if err != nil {
+		t.Fatalf("MaxPlain failed: %v", err)
+	}
+	minPlain, err := helper.MinPlain(ct1, 1, -10, 10, policy)
+	if err != nil {
+		t.Fatalf("MinPlain failed: %v", err)
+	}
+
+	absResults := helper.DecryptVector(abs, len(values1))
+	maxResults := helper.DecryptVector(max, len(values1))
+	minResults := helper.DecryptVector(min, len(values1))
+	clampResults := helper.DecryptVector(clamp, len(values1))
+	maxPlainResults := helper.DecryptVector(maxPlain, len(values1))
+	minPlainResults := helper.DecryptVector(minPlain, len(values1))
+
+	for i := range values1 {
+		a, b := values1[i], values2[i]
+		expected := map[string][2]float64{
+			"Abs":      {absResults[i], math.Abs(a)},
+			"Max":      {maxResults[i], math.Max(a, b)},
+			"Min":      {minResults[i], math.Min(a, b)},
+			"Clamp":    {clampResults[i], math.Min(math.Max(a, -2), 4)},
+			"MaxPlain": {maxPlainResults[i], math.Max(a, 1)},
+			"MinPlain": {minPlainResults[i], math.Min(a, 1)},
+		}
+		for name, result := range expected {
+			if math.Abs(result[0]-result[1]) > 0.001 {
+				t.Errorf("%s slot %d: got %f, expected %f", name, i, result[0], result[1])
+			}
+		}
+	}
+}
+
+// TestCreditEvaluationDTIClamp ensures that the encrypted score agrees with the plaintext credit evaluation for DTIs
+// from 0 to MaxInputDTI, down to DTIResolution from both clamp bounds
+func TestCreditEvaluationDTIClamp(t *testing.T) {
+	policy := ScoringPolicy{
+		DTIInverseIterations: 9,
+		DTIClamp:             FullScoringPolicy().DTIClamp,
+	}
+	if margin := policy.DTIClamp.Margin() * (MaxInputDTI - MinDTI); margin >= DTIResolution {
+		t.Errorf("DTI clamp undecided up to %f from MinDTI, expected less than %f", margin, DTIResolution)
+	}
+	helper := newDeepTestHelper(t, policy)
+
+	creditScores := []float64{700, 700, 500, 650, 650, 600, 600, 550}
+	dtis := []float64{0, 0.001, 0.5, MinDTI - DTIResolution, MinDTI + DTIResolution,
+		MaxDTI - DTIResolution, MaxDTI + DTIResolution, MaxInputDTI}
+	creditScoreCiphertext, err := helper.EncryptVectorPu(creditScores)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtiCiphertext, err := helper.EncryptVectorPu(dtis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resultCiphertext, err := helper.CreditEvaluation(nil, nil, creditScoreCiphertext, dtiCiphertext)
+	if err != nil {
+		t.Fatalf("CreditEvaluation failed: %v", err)
+	}
+
+	results := helper.DecryptVector(resultCiphertext, len(dtis))
+	for i := range dtis {
+		// The preselection is not evaluated, so the plaintext applicant passes it
+		expected := credit_evaluation.CreditEvaluation(MinAge+1, MinSalary+1, creditScores[i], dtis[i])
+		if math.Abs(results[i]-expected) > 0.01 {
+			t.Errorf("applicant %d: got %f, expected %f", i, results[i], expected)
+		}
+	}
+}
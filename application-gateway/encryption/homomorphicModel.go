@@ -0,0 +1,87 @@
+package encryption
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"fmt"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// The scoring models of the credit-evaluation package are evaluated here on encrypted raw features, one ciphertext
+// per declared feature in the order of the model. Every slot holds a different applicant, like in
+// CreditEvaluationBatch.
+
+// ModelSigmoidDegree is the degree of the sigmoid approximation of a model's probability
+// It keeps the logit and the probability within the depth of PN14QP438.
+const ModelSigmoidDegree = 63
+
+// sigmoidPolicyFor returns a sigmoid approximation covering [lo, hi], with a margin of one on each side for the
+// approximation error of its encrypted input
+func sigmoidPolicyFor(lo, hi float64) SigmoidPolicy {
+	return SigmoidPolicy{
+		Lo:     lo - 1,
+		Hi:     hi + 1,
+		Degree: ModelSigmoidDegree,
+	}
+}
+
+// ModelSigmoidPolicy returns a sigmoid approximation covering every logit of the model
+func ModelSigmoidPolicy(model *credit_evaluation.LinearModel) SigmoidPolicy {
+	return sigmoidPolicyFor(model.LogitRange())
+}
+
+// accumulate adds weight * term to sum, sum being nil for the first term
+// Every weighted term lands exactly on the default scale, so that they add up without any realignment.
+func (c *CKKSEvaluator) accumulate(sum, term *rlwe.Ciphertext, weight float64) (*rlwe.Ciphertext, error) {
+	weighted, err := c.multByConstToScale(term, weight, c.Scale)
+	if err != nil {
+		return nil, err
+	}
+	if sum == nil {
+		return weighted, nil
+	}
+	return c.Add(sum, weighted)
+}
+
+// LinearLogit evaluates the logit of a linear model on encrypted raw features
+// The normalizations are folded into the weights, so the logit consumes a single level. A folded weight is encoded
+// with an absolute precision of about one over the rescaling modulus, so that features should be expressed in units
+// keeping them small, such as salaries in millions.
+func (c *CKKSEvaluator) LinearLogit(model *credit_evaluation.LinearModel, features []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+	if len(features) != len(model.Features) {
+		return nil, fmt.Errorf("%w: got %d, expected %d", credit_evaluation.ErrFeatureMismatch, len(features), len(model.Features))
+	}
+	if err := c.checkCircuitDepth(1, features...); err != nil {
+		return nil, err
+	}
+
+	weights, bias := model.Coefficients()
+	var logit *rlwe.Ciphertext
+	for i, feature := range features {
+		var err error
+		if logit, err = c.accumulate(logit, feature, weights[i]); err != nil {
+			return nil, fmt.Errorf("feature %s: %w", model.Features[i].Name, err)
+		}
+	}
+	c.Evaluator.AddConst(logit, bias, logit)
+
+	return logit, nil
+}
+
+// LinearProbability evaluates the probability of a linear model on encrypted raw features
+// The features must lie in their declared ranges, outside of which the sigmoid approximation diverges.
+func (c *CKKSEvaluator) LinearProbability(model *credit_evaluation.LinearModel, features []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	policy := ModelSigmoidPolicy(model)
+	if err := c.checkCircuitDepth(1+policy.Depth(), features...); err != nil {
+		return nil, err
+	}
+
+	logit, err := c.LinearLogit(model, features)
+	if err != nil {
+		return nil, err
+	}
+	return c.Sigmoid(logit, policy)
+}
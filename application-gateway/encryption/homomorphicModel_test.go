@@ -0,0 +1,82 @@
+package encryption
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// testModel is a linear model using every kind of normalization, with salaries in millions
+var testModel = credit_evaluation.LinearModel{
+	Name: "test",
+	Features: []credit_evaluation.Feature{
+		{Name: "CreditScore", Weight: 2.5, Normalization: credit_evaluation.NormalizationMinMax, Min: MinCreditScore, Max: MaxCreditScore},
+		{Name: "DTI", Weight: -3, Min: 0, Max: MaxDTI},
+		{Name: "Salary", Weight: 0.8, Normalization: credit_evaluation.NormalizationZScore, Min: 0, Max: 200, Mean: 60, StdDev: 30},
+	},
+	Bias: -0.4,
+}
+
+// TestLinearModelParity ensures that the encrypted logit and probability of a linear model match its plaintext
+// evaluation for a batch of applicants
+func TestLinearModelParity(t *testing.T) {
+	helper := NewCKKSHelper()
+
+	applicants := [][]float64{
+		{800, 0.2, 150},
+		{420, 0.9, 12},
+		{650, 0.45, 60},
+		{300, 0, 0},
+		{850, 1, 200},
+	}
+
+	features := encryptColumns(t, helper, applicants, len(testModel.Features))
+
+	logitCiphertext, err := helper.LinearLogit(&testModel, features)
+	if err != nil {
+		t.Fatalf("LinearLogit failed: %v", err)
+	}
+	probabilityCiphertext, err := helper.LinearProbability(&testModel, features)
+	if err != nil {
+		t.Fatalf("LinearProbability failed: %v", err)
+	}
+
+	logits := helper.DecryptVector(logitCiphertext, len(applicants))
+	probabilities := helper.DecryptVector(probabilityCiphertext, len(applicants))
+	for j, applicant := range applicants {
+		expectedLogit, err := testModel.Logit(applicant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedProbability, _ := testModel.Probability(applicant)
+		if math.Abs(logits[j]-expectedLogit) > 1e-3 {
+			t.Errorf("applicant %d: LinearLogit got %f, expected %f", j, logits[j], expectedLogit)
+		}
+		if math.Abs(probabilities[j]-expectedProbability) > 1e-3 {
+			t.Errorf("applicant %d: LinearProbability got %f, expected %f", j, probabilities[j], expectedProbability)
+		}
+	}
+
+	if _, err = helper.LinearLogit(&testModel, features[:2]); !errors.Is(err, credit_evaluation.ErrFeatureMismatch) {
+		t.Errorf("LinearLogit with a missing feature returned %v, expected %v", err, credit_evaluation.ErrFeatureMismatch)
+	}
+}
+
+// encryptColumns encrypts one ciphertext per feature, one applicant per slot
+func encryptColumns(t *testing.T, helper *CKKSHelper, applicants [][]float64, n int) []*rlwe.Ciphertext {
+	features := make([]*rlwe.Ciphertext, n)
+	for i := range features {
+		column := make([]float64, len(applicants))
+		for j, applicant := range applicants {
+			column[j] = applicant[i]
+		}
+		var err error
+		if features[i], err = helper.EncryptVectorPu(column); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return features
+}
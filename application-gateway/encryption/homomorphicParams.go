@@ -0,0 +1,265 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+// Named CKKS parameter sets selectable through HelperOptions.ParameterSet
+// The NoP variants drop the special primes P used by key switching and decompose in base 2^NoPPow2Base instead,
+// which raises the estimated security at the cost of larger evaluation keys and a noisier relinearization.
+const (
+	PN12QP109  = "PN12QP109"
+	PN13QP218  = "PN13QP218"
+	PN14QP438  = "PN14QP438"
+	PN15QP880  = "PN15QP880"
+	PN16QP1761 = "PN16QP1761"
+
+	PN12QP109NoP  = "PN12QP109NoP"
+	PN13QP218NoP  = "PN13QP218NoP"
+	PN14QP438NoP  = "PN14QP438NoP"
+	PN15QP880NoP  = "PN15QP880NoP"
+	PN16QP1761NoP = "PN16QP1761NoP"
+
+	DefaultParameterSet = PN14QP438
+)
+
+// NoPPow2Base is the gadget decomposition base used by the parameter sets without special primes
+const NoPPow2Base = 16
+
+// ParameterSets maps the names of the selectable parameter sets to their literals
+var ParameterSets = map[string]ckks.ParametersLiteral{
+	PN12QP109:  ckks.PN12QP109,
+	PN13QP218:  ckks.PN13QP218,
+	PN14QP438:  ckks.PN14QP438,
+	PN15QP880:  ckks.PN15QP880,
+	PN16QP1761: ckks.PN16QP1761,
+
+	PN12QP109NoP:  withoutSpecialPrimes(ckks.PN12QP109),
+	PN13QP218NoP:  withoutSpecialPrimes(ckks.PN13QP218),
+	PN14QP438NoP:  withoutSpecialPrimes(ckks.PN14QP438),
+	PN15QP880NoP:  withoutSpecialPrimes(ckks.PN15QP880),
+	PN16QP1761NoP: withoutSpecialPrimes(ckks.PN16QP1761),
+}
+
+var (
+	ErrUnknownParameterSet = errors.New("unknown CKKS parameter set")
+	ErrInvalidScale        = errors.New("invalid CKKS scale")
+	ErrInsufficientDepth   = errors.New("parameters do not have enough levels for the scoring policy")
+)
+
+// HelperOptions selects the CKKS parameters and the scoring policy of a helper
+// The zero value selects DefaultParameterSet and DefaultScoringPolicy.
+type HelperOptions struct {
+	// ParameterSet is the name of one of the ParameterSets, ignored when LogN is set
+	ParameterSet string
+
+	// LogN, LogQ and LogP describe a custom parameter set; LogP may be empty to use no special primes
+	LogN int
+	LogQ []int
+	LogP []int
+
+	// LogScale overrides the default scale of the parameter set with 2^LogScale
+	LogScale int
+
+	// Policy is the scoring circuit the parameters must have enough levels for
+	Policy ScoringPolicy
+}
+
+// ScoringPolicy describes the encrypted scoring circuit evaluated by CreditEvaluation
+type ScoringPolicy struct {
+	// DTIInverseIterations is the number of Goldschmidt iterations used to invert a DTI in [MinDTI, MaxDTI]
+	DTIInverseIterations int
+
+	// DTIClamp is the comparison used to bring DTIs in [0, MaxInputDTI] into [MinDTI, MaxDTI] before the inverse,
+	// disabled when zero. Without it, DTIs must already lie in [MinDTI, MaxDTI].
+	DTIClamp ComparisonPolicy
+
+	// Preselection is the comparison used to gate the score on the age and salary, disabled when zero
+	Preselection ComparisonPolicy
+
+	// Sigmoid turns the score into a probability, disabled when zero
+	Sigmoid SigmoidPolicy
+}
+
+// DefaultScoringPolicy returns the scoring policy used when none is given
+func DefaultScoringPolicy() ScoringPolicy {
+	return ScoringPolicy{
+		DTIInverseIterations: 8,
+	}
+}
+
+// PreselectionScoringPolicy returns a scoring policy that also gates the score on the age and salary
+// Its circuit is too deep for PN14QP438 and needs PN16QP1761. Its comparison decides salaries from
+// SalaryResolution away from MinSalary over [0, MaxSalary].
+func PreselectionScoringPolicy() ScoringPolicy {
+	policy := DefaultScoringPolicy()
+	policy.Preselection = ComparisonPolicy{
+		Expansions:  5,
+		Refinements: 2,
+	}
+	return policy
+}
+
+// FullScoringPolicy returns a scoring policy with the preselection, the DTI clamp and the sigmoid all enabled
+// Its circuit is deeper than any parameter set and can only be evaluated with bootstrapping.
+func FullScoringPolicy() ScoringPolicy {
+	policy := PreselectionScoringPolicy()
+	policy.DTIClamp = ComparisonPolicy{
+		Expansions:  5,
+		Refinements: 2,
+	}
+	policy.Sigmoid = DefaultSigmoidPolicy()
+	return policy
+}
+
+// StepDepth returns the largest number of levels consumed by a single step of the scoring circuit,
+// which must fit between two bootstraps
+func (p ScoringPolicy) StepDepth() int {
+	depth := 1
+	if p.DTIClamp.Enabled() || p.Preselection.Enabled() {
+		depth = signPolynomialDepth
+	}
+	return max(depth, p.Sigmoid.Depth())
+}
+
+// Depth returns the number of levels consumed by the scoring circuit
+func (p ScoringPolicy) Depth() int {
+	// The credit score term only needs one level and is computed alongside the DTI inverse
+	depth := InverseDepth(p.DTIInverseIterations)
+
+	if p.DTIClamp.Enabled() {
+		// The DTI is clamped before the inverse
+		depth += p.DTIClamp.Depth() + 1
+	}
+
+	if p.Preselection.Enabled() {
+		// Both comparisons are evaluated alongside the score, multiplied together, then folded into the score
+		depth = max(depth, p.Preselection.Depth()+1) + 1
+	}
+	return depth + p.Sigmoid.Depth()
+}
+
+// Validate checks that the parameters have enough levels to evaluate the policy
+func (p ScoringPolicy) Validate(params ckks.Parameters) error {
+	if p.Depth() > params.MaxLevel() {
+		return fmt.Errorf("%w: policy needs %d levels, parameters provide %d", ErrInsufficientDepth, p.Depth(), params.MaxLevel())
+	}
+	return nil
+}
+
+// Parameters resolves the options into a CKKS parameter set
+func (o HelperOptions) Parameters() (ckks.Parameters, error) {
+	var literal ckks.ParametersLiteral
+
+	if o.LogN != 0 {
+		// Custom parameter set
+		literal = ckks.ParametersLiteral{
+			LogN:     o.LogN,
+			LogQ:     o.LogQ,
+			LogP:     o.LogP,
+			LogSlots: o.LogN - 1,
+		}
+		if len(o.LogP) == 0 {
+			literal.Pow2Base = NoPPow2Base
+		}
+		if len(o.LogQ) > 1 {
+			literal.DefaultScale = float64(uint64(1) << o.LogQ[1])
+		}
+	} else {
+		name := o.ParameterSet
+		if name == "" {
+			name = DefaultParameterSet
+		}
+
+		var ok bool
+		if literal, ok = ParameterSets[name]; !ok {
+			return ckks.Parameters{}, fmt.Errorf("%w: %s", ErrUnknownParameterSet, name)
+		}
+	}
+
+	if o.LogScale != 0 {
+		if o.LogScale < 0 || o.LogScale > 60 {
+			return ckks.Parameters{}, fmt.Errorf("%w: 2^%d", ErrInvalidScale, o.LogScale)
+		}
+		literal.DefaultScale = float64(uint64(1) << o.LogScale)
+	}
+
+	params, err := ckks.NewParametersFromLiteral(literal)
+	if err != nil {
+		return ckks.Parameters{}, err
+	}
+
+	// The base modulus must be able to hold a message at the default scale
+	if scale := params.DefaultScale().Float64(); scale <= 1 || bits.Len64(uint64(scale)) > bits.Len64(params.Q()[0]) {
+		return ckks.Parameters{}, fmt.Errorf("%w: scale %g exceeds the base modulus", ErrInvalidScale, scale)
+	}
+
+	return params, nil
+}
+
+// policy returns the scoring policy of the options, falling back on DefaultScoringPolicy
+func (o HelperOptions) policy() ScoringPolicy {
+	if o.Policy == (ScoringPolicy{}) {
+		return DefaultScoringPolicy()
+	}
+	return o.Policy
+}
+
+// resolve returns the parameters and scoring policy of the options, checking that they fit together
+func (o HelperOptions) resolve() (ckks.Parameters, ScoringPolicy, error) {
+	params, err := o.Parameters()
+	if err != nil {
+		return ckks.Parameters{}, ScoringPolicy{}, err
+	}
+
+	policy := o.policy()
+	if err = policy.Validate(params); err != nil {
+		return ckks.Parameters{}, ScoringPolicy{}, err
+	}
+
+	return params, policy, nil
+}
+
+// securityTable holds the largest log2(QP) giving 128, 192 and 256 bits of security for a ternary secret
+// according to the homomorphic encryption standard, indexed by logN
+var securityTable = map[int][3]int{
+	10: {27, 19, 14},
+	11: {54, 37, 29},
+	12: {109, 75, 58},
+	13: {218, 152, 118},
+	14: {438, 305, 237},
+	15: {881, 611, 476},
+	16: {1761, 1220, 956},
+}
+
+// EstimatedSecurity returns the estimated classical security level of the parameters in bits (256, 192 or 128),
+// or 0 when the parameters fall below 128 bits of security
+func EstimatedSecurity(params ckks.Parameters) int {
+	bounds, ok := securityTable[params.LogN()]
+	if !ok {
+		return 0
+	}
+
+	logQP := params.LogQP()
+	switch {
+	case logQP <= bounds[2]:
+		return 256
+	case logQP <= bounds[1]:
+		return 192
+	case logQP <= bounds[0]:
+		return 128
+	default:
+		return 0
+	}
+}
+
+// withoutSpecialPrimes returns a copy of a parameter set literal that does not use special primes
+func withoutSpecialPrimes(literal ckks.ParametersLiteral) ckks.ParametersLiteral {
+	literal.P = nil
+	literal.Pow2Base = NoPPow2Base
+	return literal
+}
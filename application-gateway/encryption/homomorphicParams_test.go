@@ -0,0 +1,77 @@
+package encryption
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// TestParameterSets ensures that every named parameter set resolves and reports its security level
+func TestParameterSets(t *testing.T) {
+	for name := range ParameterSets {
+		params, err := HelperOptions{ParameterSet: name}.Parameters()
+		if err != nil {
+			t.Errorf("parameter set %s failed to resolve: %v", name, err)
+			continue
+		}
+		if security := EstimatedSecurity(params); security < 128 {
+			t.Errorf("parameter set %s has an estimated security of %d bits", name, security)
+		}
+	}
+
+	if _, err := (HelperOptions{ParameterSet: "PN11"}).Parameters(); !errors.Is(err, ErrUnknownParameterSet) {
+		t.Errorf("unknown parameter set returned %v, expected %v", err, ErrUnknownParameterSet)
+	}
+}
+
+// TestHelperOptionsPolicyDepth ensures that parameters without enough levels for the scoring policy are refused
+func TestHelperOptionsPolicyDepth(t *testing.T) {
+	_, err := NewCKKSHelperWithOptions(HelperOptions{ParameterSet: PN13QP218})
+	if !errors.Is(err, ErrInsufficientDepth) {
+		t.Errorf("PN13QP218 with the default policy returned %v, expected %v", err, ErrInsufficientDepth)
+	}
+
+	helper, err := NewCKKSHelperWithOptions(HelperOptions{
+		ParameterSet: PN13QP218,
+		Policy:       ScoringPolicy{DTIInverseIterations: 4},
+	})
+	if err != nil {
+		t.Fatalf("PN13QP218 with a shallow policy failed: %v", err)
+	}
+
+	creditScore, dti := 700.0, 0.4
+	resultCiphertext, err := helper.CreditEvaluation(nil, nil, helper.EncryptPu(creditScore), helper.EncryptPu(dti))
+	if err != nil {
+		t.Fatalf("CreditEvaluation failed: %v", err)
+	}
+
+	result := helper.Decrypt(resultCiphertext)
+	expected := W0*(creditScore-MinCreditScore)/(MaxCreditScore-MinCreditScore) + W1/dti
+	if math.Abs(result-expected) > 0.01 {
+		t.Errorf("CreditEvaluation on PN13QP218 failed. Got %f, expected %f", result, expected)
+	}
+}
+
+// TestCustomParameters ensures that custom literals and scales are honored
+func TestCustomParameters(t *testing.T) {
+	opts := HelperOptions{
+		LogN:     13,
+		LogQ:     []int{40, 30, 30, 30, 30, 30},
+		LogP:     []int{40},
+		LogScale: 30,
+		Policy:   ScoringPolicy{DTIInverseIterations: 3},
+	}
+
+	helper, err := NewCKKSHelperWithOptions(opts)
+	if err != nil {
+		t.Fatalf("custom parameters failed: %v", err)
+	}
+	if helper.Params.LogN() != 13 || helper.Params.MaxLevel() != 5 || helper.Scale.Float64() != 1<<30 {
+		t.Errorf("custom parameters not honored: logN %d, levels %d, scale %f", helper.Params.LogN(), helper.Params.MaxLevel(), helper.Scale.Float64())
+	}
+
+	opts.LogScale = 45
+	if _, err = opts.Parameters(); !errors.Is(err, ErrInvalidScale) {
+		t.Errorf("scale larger than the base modulus returned %v, expected %v", err, ErrInvalidScale)
+	}
+}
@@ -0,0 +1,55 @@
+package encryption
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"fmt"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// ScorecardDepth returns the number of levels consumed by ScorecardScore: the comparisons, which are all evaluated
+// on the features, and the weighting of the steps by their points
+func ScorecardDepth(policy ComparisonPolicy) int {
+	return policy.Depth() + 1
+}
+
+// ScorecardScore evaluates the score of a scorecard on encrypted raw features
+// The points of a feature are those of its first bin plus, for every inner edge, the difference with the next bin
+// weighted by step(x - edge), so that all the edges are compared in parallel. Within policy.Margin() of an edge, the
+// points interpolate between both bins; away from the edges, the error of a step is at most SignPrecision times its
+// difference of points. It consumes ScorecardDepth levels.
+func (c *CKKSEvaluator) ScorecardScore(scorecard *credit_evaluation.Scorecard, features []*rlwe.Ciphertext, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	if err := scorecard.Validate(); err != nil {
+		return nil, err
+	}
+	if len(features) != len(scorecard.Features) {
+		return nil, fmt.Errorf("%w: got %d, expected %d", credit_evaluation.ErrFeatureMismatch, len(features), len(scorecard.Features))
+	}
+	if err := c.checkCircuitDepth(ScorecardDepth(policy), features...); err != nil {
+		return nil, err
+	}
+
+	var score *rlwe.Ciphertext
+	constant := scorecard.BasePoints
+	for i, f := range scorecard.Features {
+		base, edges, deltas := f.Steps()
+		constant += base
+
+		for j, edge := range edges {
+			step, err := c.GreaterThan(features[i], edge, f.Min, f.Max, policy)
+			if err != nil {
+				return nil, fmt.Errorf("feature %s: %w", f.Name, err)
+			}
+
+			if score, err = c.accumulate(score, step, deltas[j]); err != nil {
+				return nil, fmt.Errorf("feature %s: %w", f.Name, err)
+			}
+		}
+	}
+	if score == nil {
+		return nil, fmt.Errorf("%w: the scorecard has no inner edge", credit_evaluation.ErrInvalidModel)
+	}
+
+	c.Evaluator.AddConst(score, constant, score)
+	return score, nil
+}
@@ -0,0 +1,63 @@
+package encryption
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"errors"
+	"math"
+	"testing"
+)
+
+// testScorecard is a points-based scorecard with salaries in millions
+var testScorecard = credit_evaluation.Scorecard{
+	Name: "test",
+	Features: []credit_evaluation.ScorecardFeature{
+		{Name: "CreditScore", Min: MinCreditScore, Max: MaxCreditScore, Bins: []credit_evaluation.ScorecardBin{
+			{Upper: 580, Points: -20}, {Upper: 670, Points: 0}, {Upper: 740, Points: 15}, {Upper: MaxCreditScore, Points: 30},
+		}},
+		{Name: "DTI", Min: 0, Max: MaxDTI, Bins: []credit_evaluation.ScorecardBin{
+			{Upper: 0.2, Points: 25}, {Upper: 0.36, Points: 10}, {Upper: 0.5, Points: -5}, {Upper: MaxDTI, Points: -30},
+		}},
+		{Name: "Salary", Min: 0, Max: 200, Bins: []credit_evaluation.ScorecardBin{
+			{Upper: 20, Points: -10}, {Upper: 200, Points: 10},
+		}},
+	},
+	BasePoints: 600,
+}
+
+// TestScorecardParity ensures that the encrypted score of a scorecard matches its plaintext evaluation for a batch
+// of applicants away from the edges of the bins
+func TestScorecardParity(t *testing.T) {
+	policy := ComparisonPolicy{Expansions: 2, Refinements: 2}
+	helper := newLevelsTestHelper(t, ScorecardDepth(policy))
+
+	applicants := [][]float64{
+		{800, 0.1, 150},
+		{420, 0.9, 12},
+		{700, 0.3, 30},
+		{620, 0.45, 100},
+		{300, 0, 0},
+		{850, 1, 200},
+	}
+
+	features := encryptColumns(t, helper, applicants, len(testScorecard.Features))
+
+	scoreCiphertext, err := helper.ScorecardScore(&testScorecard, features, policy)
+	if err != nil {
+		t.Fatalf("ScorecardScore failed: %v", err)
+	}
+
+	scores := helper.DecryptVector(scoreCiphertext, len(applicants))
+	for j, applicant := range applicants {
+		expected, err := testScorecard.Score(applicant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(scores[j]-expected) > 1e-2 {
+			t.Errorf("applicant %d: ScorecardScore got %f, expected %f", j, scores[j], expected)
+		}
+	}
+
+	if _, err = helper.ScorecardScore(&testScorecard, features[:2], policy); !errors.Is(err, credit_evaluation.ErrFeatureMismatch) {
+		t.Errorf("ScorecardScore with a missing feature returned %v, expected %v", err, credit_evaluation.ErrFeatureMismatch)
+	}
+}
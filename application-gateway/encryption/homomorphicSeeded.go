@@ -0,0 +1,160 @@
+package encryption
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/ring"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/tuneinsight/lattigo/v4/utils"
+)
+
+// SeedSize is the size in bytes of the seed a seeded ciphertext regenerates its second component from
+const SeedSize = 32
+
+// ciphertextHeaderSize bounds the bytes of a serialized ciphertext besides its coefficients: its metadata, the
+// headers of its polynomials and the seed of a seeded ciphertext
+const ciphertextHeaderSize = 1024
+
+var ErrMalformedSeeded = errors.New("seeded ciphertext is malformed")
+
+// SeededCiphertext is a fresh secret-key encryption stored as its first component and the seed of the uniformly
+// random second component, about half the size of the full ciphertext.
+// Only fresh encryptions can be seeded: any homomorphic operation makes the second component depend on the data.
+type SeededCiphertext struct {
+	Seed []byte
+	Body *rlwe.Ciphertext
+}
+
+// EncryptSeeded encrypts a value under the secret key into a seeded ciphertext
+func (c *CKKSKeyOwner) EncryptSeeded(value float64) (*SeededCiphertext, error) {
+	return c.encryptSeeded(fillSlots([]float64{value}, c.LogSlots))
+}
+
+// EncryptVectorSeeded encrypts a vector of values, one per slot, under the secret key into a seeded ciphertext
+func (c *CKKSKeyOwner) EncryptVectorSeeded(values []float64) (*SeededCiphertext, error) {
+	if err := checkVector(values, c.LogSlots); err != nil {
+		return nil, err
+	}
+	return c.encryptSeeded(fillSlots(values, c.LogSlots))
+}
+
+func (c *CKKSKeyOwner) encryptSeeded(slots []complex128) (*SeededCiphertext, error) {
+	// A fresh seed per ciphertext, the second component must never be reused
+	seed := make([]byte, SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	prng, err := utils.NewKeyedPRNG(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := c.Encoder.EncodeNew(slots, c.Params.MaxLevel(), c.Scale, c.LogSlots)
+	ct := ckks.NewPRNGEncryptor(c.Params, c.secretKey).WithPRNG(prng).EncryptNew(plaintext)
+
+	// Keep the first component only
+	body := rlwe.NewCiphertext(c.Params.Parameters, 0, ct.Level())
+	body.MetaData = ct.MetaData
+	body.Value[0] = ct.Value[0]
+	return &SeededCiphertext{Seed: seed, Body: body}, nil
+}
+
+// Expand regenerates the second component from the seed and returns the full ciphertext, ready to be evaluated
+func (s *SeededCiphertext) Expand(params ckks.Parameters) (*rlwe.Ciphertext, error) {
+	if len(s.Seed) != SeedSize || s.Body == nil || s.Body.Degree() != 0 {
+		return nil, ErrMalformedSeeded
+	}
+	if s.Body.Level() > params.MaxLevel() {
+		return nil, fmt.Errorf("%w: level %d above the maximum level %d", ErrMalformedSeeded, s.Body.Level(), params.MaxLevel())
+	}
+	prng, err := utils.NewKeyedPRNG(s.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	// The encryptor samples the second component in the NTT domain, the sampler gives the same polynomial
+	ct := rlwe.NewCiphertext(params.Parameters, 1, s.Body.Level())
+	ct.MetaData = s.Body.MetaData
+	ct.Value[0] = s.Body.Value[0].CopyNew()
+	ct.Value[1] = ring.NewUniformSampler(prng, params.RingQ()).ReadLvlNew(s.Body.Level())
+	return ct, nil
+}
+
+// MarshalBinary serializes the seeded ciphertext as the seed followed by the first component
+func (s *SeededCiphertext) MarshalBinary() ([]byte, error) {
+	body, err := s.Body.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data := binary.AppendUvarint(nil, uint64(len(s.Seed)))
+	data = append(data, s.Seed...)
+	return append(data, body...), nil
+}
+
+// UnmarshalBinary reads a seeded ciphertext serialized by MarshalBinary
+func (s *SeededCiphertext) UnmarshalBinary(data []byte) error {
+	seedSize, n := binary.Uvarint(data)
+	if n <= 0 || seedSize != SeedSize || len(data) < n+SeedSize {
+		return ErrMalformedSeeded
+	}
+	body := new(rlwe.Ciphertext)
+	if err := body.UnmarshalBinary(data[n+SeedSize:]); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedSeeded, err)
+	}
+	if body.Degree() != 0 {
+		return fmt.Errorf("%w: body of degree %d", ErrMalformedSeeded, body.Degree())
+	}
+
+	s.Seed = append([]byte{}, data[n:n+SeedSize]...)
+	s.Body = body
+	return nil
+}
+
+// Compress gzips a serialized ciphertext for storage
+func Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, gzip.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MaxCiphertextSize returns the size of the largest serialized ciphertext under the parameters: a ciphertext of
+// degree one at the maximum level
+func MaxCiphertextSize(params ckks.Parameters) int {
+	return 2*params.N()*(params.MaxLevel()+1)*8 + ciphertextHeaderSize
+}
+
+// Decompress reverses Compress
+// The data comes from the outside, so it may not inflate beyond MaxCiphertextSize.
+func Decompress(data []byte, params ckks.Parameters) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	limit := MaxCiphertextSize(params)
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > limit {
+		return nil, fmt.Errorf("compressed data inflates beyond %d bytes, the largest ciphertext", limit)
+	}
+	return decompressed, nil
+}
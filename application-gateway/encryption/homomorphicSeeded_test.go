@@ -0,0 +1,129 @@
+package encryption
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSeededCiphertext ensures that a seeded ciphertext is about half the size of the full one and that its
+// expansion decrypts and evaluates like a regular ciphertext
+func TestSeededCiphertext(t *testing.T) {
+	helper := NewCKKSHelper()
+	value := 12.5
+
+	seeded, err := helper.EncryptSeeded(value)
+	if err != nil {
+		t.Fatalf("EncryptSeeded failed: %v", err)
+	}
+	data, err := seeded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := helper.EncryptPr(value).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio := float64(len(data)) / float64(len(full)); ratio > 0.55 {
+		t.Errorf("seeded ciphertext is %d bytes, %.2f of the full %d bytes", len(data), ratio, len(full))
+	}
+
+	decoded := new(SeededCiphertext)
+	if err = decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	ct, err := decoded.Expand(helper.Params)
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	if result := helper.Decrypt(ct); math.Abs(result-value) > precision {
+		t.Errorf("expanded ciphertext decrypted to %f, expected %f", result, value)
+	}
+
+	product, err := helper.Multiply(ct, helper.EncryptPu(2))
+	if err != nil {
+		t.Fatalf("Multiply failed: %v", err)
+	}
+	if result := helper.Decrypt(product); math.Abs(result-2*value) > 0.01 {
+		t.Errorf("product of the expanded ciphertext is %f, expected %f", result, 2*value)
+	}
+
+	if err = decoded.UnmarshalBinary(data[:10]); err == nil {
+		t.Error("UnmarshalBinary accepted a truncated seeded ciphertext")
+	}
+}
+
+// TestSeededEnvelope ensures that seeded and compressed envelopes are expanded transparently in both serializations
+func TestSeededEnvelope(t *testing.T) {
+	helper := NewCKKSHelper()
+	values := []float64{0.4, 650, 30}
+
+	envelope, err := helper.SealVectorSeeded(values)
+	if err != nil {
+		t.Fatalf("SealVectorSeeded failed: %v", err)
+	}
+	if err = envelope.Compress(); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	for _, format := range []string{EnvelopeJSON, EnvelopeBinary} {
+		encoded, err := EncodeEnvelope(envelope, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeEnvelope(encoded)
+		if err != nil {
+			t.Fatalf("DecodeEnvelope %s failed: %v", format, err)
+		}
+		if !decoded.Seeded || !decoded.Compressed {
+			t.Errorf("%s: payload flags were not preserved: seeded %v, compressed %v", format, decoded.Seeded, decoded.Compressed)
+		}
+
+		results, err := helper.Values(decoded)
+		if err != nil {
+			t.Fatalf("%s: Values failed: %v", format, err)
+		}
+		for i, value := range values {
+			if math.Abs(results[i]-value) > precision {
+				t.Errorf("%s: slot %d: got %f, expected %f", format, i, results[i], value)
+			}
+		}
+	}
+
+	// Version 1 envelopes have no payload flags and are still read
+	v1, err := helper.Seal(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Version = 1
+	encoded, err := EncodeEnvelope(v1, EnvelopeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeEnvelope(encoded)
+	if err != nil {
+		t.Fatalf("DecodeEnvelope of a version 1 envelope failed: %v", err)
+	}
+	if results, err := helper.Values(decoded); err != nil || math.Abs(results[0]-7) > precision {
+		t.Errorf("Values of a version 1 envelope returned %v, %v", results, err)
+	}
+}
+
+// TestDecompressLimit ensures that compressed data can not inflate beyond the largest ciphertext
+func TestDecompressLimit(t *testing.T) {
+	helper := NewCKKSHelper()
+	limit := MaxCiphertextSize(helper.Params)
+
+	for size, accepted := range map[int]bool{limit: true, limit + 1: false} {
+		compressed, err := Compress(make([]byte, size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompressed, err := Decompress(compressed, helper.Params)
+		if accepted && (err != nil || len(decompressed) != size) {
+			t.Errorf("Decompress of %d bytes returned %d bytes and %v, expected them back", size, len(decompressed), err)
+		}
+		if !accepted && err == nil {
+			t.Errorf("Decompress accepted %d bytes, above the limit of %d", size, limit)
+		}
+	}
+}
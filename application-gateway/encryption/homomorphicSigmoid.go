@@ -0,0 +1,79 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// ErrSigmoidInterval is returned when the interval of a sigmoid approximation is empty
+var ErrSigmoidInterval = errors.New("sigmoid interval must not be empty")
+
+// SigmoidPolicy configures the Chebyshev approximation of the logistic function
+// The approximation is only valid on [Lo, Hi]; it diverges quickly outside of it.
+type SigmoidPolicy struct {
+	// Lo and Hi bound the interval the polynomial is fitted on
+	Lo float64
+	Hi float64
+
+	// Degree is the degree of the Chebyshev polynomial, disabled when zero
+	Degree int
+}
+
+// DefaultSigmoidPolicy returns a sigmoid approximation covering every score CreditEvaluation can return,
+// from -1 for a failed preselection up to W0 + W1/MinDTI, with an error below 1e-6
+func DefaultSigmoidPolicy() SigmoidPolicy {
+	return SigmoidPolicy{
+		Lo:     -2,
+		Hi:     52,
+		Degree: 127,
+	}
+}
+
+// Enabled reports whether the policy applies a sigmoid
+func (p SigmoidPolicy) Enabled() bool {
+	return p.Degree > 0
+}
+
+// Depth returns the number of levels consumed by the sigmoid, including the change of variable into [-1, 1]
+func (p SigmoidPolicy) Depth() int {
+	if !p.Enabled() {
+		return 0
+	}
+	// The baby-step giant-step evaluation of a degree d polynomial consumes ceil(log2(d+1)) levels
+	return 1 + bits.Len(uint(p.Degree))
+}
+
+// Sigmoid computes the logistic function 1/(1+exp(-x)) of encrypted values declared to lie in [policy.Lo, policy.Hi]
+// The function is interpolated by a Chebyshev polynomial and evaluated with the baby-step giant-step algorithm.
+// It consumes policy.Depth() levels.
+func (c *CKKSEvaluator) Sigmoid(ct *rlwe.Ciphertext, policy SigmoidPolicy) (*rlwe.Ciphertext, error) {
+	if !policy.Enabled() {
+		return nil, fmt.Errorf("sigmoid needs a positive degree, got %d", policy.Degree)
+	}
+	lo, hi := policy.Lo, policy.Hi
+	if lo >= hi {
+		return nil, fmt.Errorf("%w: [%g, %g]", ErrSigmoidInterval, lo, hi)
+	}
+	ct, err := c.refresh(ct, policy.Depth())
+	if err != nil {
+		return nil, err
+	}
+
+	polynomial := ckks.Approximate(func(x float64) float64 {
+		return 1 / (1 + math.Exp(-x))
+	}, lo, hi, policy.Degree)
+
+	// Change of variable from [lo, hi] into [-1, 1], where the Chebyshev basis is defined
+	result := c.Evaluator.MultByConstNew(ct, 2/(hi-lo))
+	c.Evaluator.AddConst(result, (-lo-hi)/(hi-lo), result)
+	if err = c.rescale(result); err != nil {
+		return nil, err
+	}
+
+	return c.Evaluator.EvaluatePoly(result, polynomial, c.Scale)
+}
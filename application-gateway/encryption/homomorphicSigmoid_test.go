@@ -0,0 +1,55 @@
+package encryption
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSigmoidInterval ensures that the sigmoid approximation holds over the whole fitted interval
+func TestSigmoidInterval(t *testing.T) {
+	helper := NewCKKSHelper()
+	policy := DefaultSigmoidPolicy()
+
+	values := []float64{policy.Lo, -1, -0.25, 0, 0.5, 3, 10, 25.5, policy.Hi}
+	ciphertext, err := helper.EncryptVectorPu(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resultCiphertext, err := helper.Sigmoid(ciphertext, policy)
+	if err != nil {
+		t.Fatalf("Sigmoid failed: %v", err)
+	}
+
+	results := helper.DecryptVector(resultCiphertext, len(values))
+	for i, value := range values {
+		expected := 1 / (1 + math.Exp(-value))
+		if math.Abs(results[i]-expected) > precision {
+			t.Errorf("Sigmoid(%f) = %f, expected %f", value, results[i], expected)
+		}
+	}
+}
+
+// TestCreditEvaluationProbability ensures that a policy with a sigmoid returns the probability as a ciphertext
+func TestCreditEvaluationProbability(t *testing.T) {
+	policy := DefaultScoringPolicy()
+	policy.Sigmoid = DefaultSigmoidPolicy()
+
+	helper, err := NewCKKSHelperWithOptions(HelperOptions{ParameterSet: PN15QP880, Policy: policy})
+	if err != nil {
+		t.Fatalf("NewCKKSHelperWithOptions failed: %v", err)
+	}
+
+	creditScore, dti := 420.0, 0.9
+	resultCiphertext, err := helper.CreditEvaluation(nil, nil, helper.EncryptPu(creditScore), helper.EncryptPu(dti))
+	if err != nil {
+		t.Fatalf("CreditEvaluation failed: %v", err)
+	}
+
+	result := helper.Decrypt(resultCiphertext)
+	score := W0*(creditScore-MinCreditScore)/(MaxCreditScore-MinCreditScore) + W1/dti
+	expected := 1 / (1 + math.Exp(-score))
+	if math.Abs(result-expected) > 0.001 {
+		t.Errorf("CreditEvaluation probability failed. Got %f, expected %f", result, expected)
+	}
+}
@@ -0,0 +1,543 @@
+package encryption
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/dckks"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/tuneinsight/lattigo/v4/utils"
+)
+
+// ThresholdAggregatorName is the transport name of the aggregator, which collects and combines the shares of the parties
+const ThresholdAggregatorName = "aggregator"
+
+// Kinds of the messages exchanged by the threshold protocols
+const (
+	ckgShareKind             = "ckg-share"
+	rkgRoundOneKind          = "rkg-round-one"
+	rkgRoundOneAggregateKind = "rkg-round-one-aggregate"
+	rkgRoundTwoKind          = "rkg-round-two"
+	shamirShareKind          = "shamir-share"
+	decryptionRequestKind    = "decryption-request"
+	decryptionShareKind      = "decryption-share"
+)
+
+var (
+	ErrThresholdConfig  = errors.New("invalid threshold configuration")
+	ErrNotEnoughParties = errors.New("not enough parties to reach the decryption threshold")
+	ErrUnexpectedSender = errors.New("message from an unexpected sender")
+	ErrKeysNotGenerated = errors.New("collective keys have not been generated")
+)
+
+// ThresholdConfig describes the parties sharing a collective CKKS key and how many of them must cooperate to decrypt
+// Every party and the aggregator must use the same configuration.
+type ThresholdConfig struct {
+	// Parties are the names of the key holders, e.g. the owner, the issuer and the government
+	Parties []string
+
+	// Threshold is the number of parties needed to decrypt, between 1 and len(Parties)
+	Threshold int
+
+	// Seed is the common reference string the public parts of the protocols are derived from
+	Seed []byte
+
+	// Flooding sizes the noise each party adds to its decryption and re-encryption shares, so that the decrypted
+	// values do not leak the collective key. Every party adds all of it, which keeps a decryption flooded as long as
+	// one active party is honest; the values then carry sqrt(Threshold) times the flooding noise.
+	Flooding NoiseFlooding
+
+	// SmudgingSigma overrides the standard deviation of the noise added to the coefficients of the shares
+	SmudgingSigma float64
+
+	// Options selects the CKKS parameters and the scoring policy of the collective key
+	Options HelperOptions
+}
+
+// validate checks the configuration and resolves its parameters
+func (c ThresholdConfig) validate() (ckks.Parameters, ScoringPolicy, error) {
+	if len(c.Parties) == 0 {
+		return ckks.Parameters{}, ScoringPolicy{}, fmt.Errorf("%w: no parties", ErrThresholdConfig)
+	}
+	if c.Threshold < 1 || c.Threshold > len(c.Parties) {
+		return ckks.Parameters{}, ScoringPolicy{}, fmt.Errorf("%w: threshold %d out of %d parties", ErrThresholdConfig, c.Threshold, len(c.Parties))
+	}
+	if len(c.Seed) == 0 {
+		return ckks.Parameters{}, ScoringPolicy{}, fmt.Errorf("%w: empty seed", ErrThresholdConfig)
+	}
+
+	seen := make(map[string]bool, len(c.Parties))
+	for _, party := range c.Parties {
+		if party == "" || party == ThresholdAggregatorName || seen[party] {
+			return ckks.Parameters{}, ScoringPolicy{}, fmt.Errorf("%w: invalid or duplicate party name %q", ErrThresholdConfig, party)
+		}
+		seen[party] = true
+	}
+
+	return c.Options.resolve()
+}
+
+// point returns the Shamir public point of a party, or 0 when the party is unknown
+func (c ThresholdConfig) point(party string) drlwe.ShamirPublicPoint {
+	for i, name := range c.Parties {
+		if name == party {
+			return drlwe.ShamirPublicPoint(i + 1)
+		}
+	}
+	return 0
+}
+
+// points returns the Shamir public points of the parties
+func (c ThresholdConfig) points(parties []string) []drlwe.ShamirPublicPoint {
+	points := make([]drlwe.ShamirPublicPoint, len(parties))
+	for i, party := range parties {
+		points[i] = c.point(party)
+	}
+	return points
+}
+
+// crs returns the common reference string of a protocol, identical for every party
+func (c ThresholdConfig) crs(protocol string) (drlwe.CRS, error) {
+	key := append(append([]byte{}, c.Seed...), protocol...)
+	return utils.NewKeyedPRNG(key)
+}
+
+// smudgingSigma returns the noise added to the coefficients of decryption and re-encryption shares
+func (c ThresholdConfig) smudgingSigma(params ckks.Parameters) float64 {
+	if c.SmudgingSigma <= 0 {
+		return c.Flooding.smudgingSigma(params)
+	}
+	return c.SmudgingSigma
+}
+
+// ThresholdParty holds one share of a collective secret key
+// It never holds the whole key: once the key generation is done, it only keeps its Shamir share.
+type ThresholdParty struct {
+	Name   string
+	Params ckks.Parameters
+
+	config         ThresholdConfig
+	transport      Transport
+	secretKey      *rlwe.SecretKey
+	thresholdShare *drlwe.ShamirSecretShare
+	combiner       *drlwe.Combiner
+	cks            *drlwe.CKSProtocol
+}
+
+// ThresholdAggregator runs the public side of the threshold protocols
+// It combines the shares of the parties into the collective public and relinearization keys,
+// and into decryptions once enough parties agreed to decrypt a ciphertext.
+type ThresholdAggregator struct {
+	Params       ckks.Parameters
+	Encoder      ckks.Encoder
+	PublicKey    *rlwe.PublicKey
+	Relinearizer *rlwe.RelinearizationKey
+	LogSlots     int
+
+	config    ThresholdConfig
+	policy    ScoringPolicy
+	transport Transport
+	cks       *drlwe.CKSProtocol
+	decryptor rlwe.Decryptor
+}
+
+// decryptionRequest asks a party for its share of the decryption of a ciphertext
+type decryptionRequest struct {
+	Active     []string `json:"Active"`
+	Ciphertext []byte   `json:"Ciphertext"`
+}
+
+// NewThresholdParty initializes a party with a fresh secret key share
+func NewThresholdParty(name string, config ThresholdConfig, transport Transport) (*ThresholdParty, error) {
+	params, _, err := config.validate()
+	if err != nil {
+		return nil, err
+	}
+	if config.point(name) == 0 {
+		return nil, fmt.Errorf("%w: %s is not one of the parties", ErrThresholdConfig, name)
+	}
+
+	return &ThresholdParty{
+		Name:      name,
+		Params:    params,
+		config:    config,
+		transport: transport,
+		secretKey: ckks.NewKeyGenerator(params).GenSecretKey(),
+		cks:       dckks.NewCKSProtocol(params, rlwe.DefaultSigma),
+	}, nil
+}
+
+// NewThresholdAggregator initializes the aggregator of a collective key
+func NewThresholdAggregator(config ThresholdConfig, transport Transport) (*ThresholdAggregator, error) {
+	params, policy, err := config.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThresholdAggregator{
+		Params:    params,
+		Encoder:   ckks.NewEncoder(params),
+		LogSlots:  params.LogSlots(),
+		config:    config,
+		policy:    policy,
+		transport: transport,
+		cks:       dckks.NewCKSProtocol(params, rlwe.DefaultSigma),
+		// Key-switched ciphertexts are encrypted under the zero key, which anyone can decrypt
+		decryptor: ckks.NewDecryptor(params, rlwe.NewSecretKey(params.Parameters)),
+	}, nil
+}
+
+// GenerateKeys runs the party side of the collective key generation, concurrently with the other parties
+// and the aggregator. It generates the shares of the public and relinearization keys, then turns the secret key
+// into Shamir shares so that any Threshold parties can later decrypt together.
+func (p *ThresholdParty) GenerateKeys() error {
+	if p.secretKey == nil {
+		return fmt.Errorf("%w: keys were already generated", ErrThresholdConfig)
+	}
+
+	// Collective public key
+	ckg := dckks.NewCKGProtocol(p.Params)
+	ckgCRS, err := p.config.crs(ckgShareKind)
+	if err != nil {
+		return err
+	}
+	ckgShare := ckg.AllocateShare()
+	ckg.GenShare(p.secretKey, ckg.SampleCRP(ckgCRS), ckgShare)
+	if err = p.send(ThresholdAggregatorName, ckgShareKind, ckgShare); err != nil {
+		return err
+	}
+
+	// Collective relinearization key, in two rounds
+	rkg := dckks.NewRKGProtocol(p.Params)
+	rkgCRS, err := p.config.crs(rkgRoundOneKind)
+	if err != nil {
+		return err
+	}
+	ephemeralKey, roundOne, roundTwo := rkg.AllocateShare()
+	rkg.GenShareRoundOne(p.secretKey, rkg.SampleCRP(rkgCRS), ephemeralKey, roundOne)
+	if err = p.send(ThresholdAggregatorName, rkgRoundOneKind, roundOne); err != nil {
+		return err
+	}
+
+	roundOneAggregate := new(drlwe.RKGShare)
+	if _, err = receiveShare(p.transport, p.Name, rkgRoundOneAggregateKind, []string{ThresholdAggregatorName}, roundOneAggregate); err != nil {
+		return err
+	}
+	rkg.GenShareRoundTwo(ephemeralKey, p.secretKey, roundOneAggregate, roundTwo)
+	if err = p.send(ThresholdAggregatorName, rkgRoundTwoKind, roundTwo); err != nil {
+		return err
+	}
+
+	return p.thresholdize()
+}
+
+// thresholdize deals Shamir shares of the secret key to every party, sums the shares dealt by the others,
+// then forgets the secret key
+func (p *ThresholdParty) thresholdize() error {
+	thresholdizer := drlwe.NewThresholdizer(p.Params.Parameters)
+	polynomial, err := thresholdizer.GenShamirPolynomial(p.config.Threshold, p.secretKey)
+	if err != nil {
+		return err
+	}
+
+	own := thresholdizer.AllocateThresholdSecretShare()
+	others := make([]string, 0, len(p.config.Parties)-1)
+	for _, party := range p.config.Parties {
+		if party == p.Name {
+			thresholdizer.GenShamirSecretShare(p.config.point(party), polynomial, own)
+			continue
+		}
+		others = append(others, party)
+
+		share := thresholdizer.AllocateThresholdSecretShare()
+		thresholdizer.GenShamirSecretShare(p.config.point(party), polynomial, share)
+		if err = p.send(party, shamirShareKind, share); err != nil {
+			return err
+		}
+	}
+
+	for range others {
+		share := thresholdizer.AllocateThresholdSecretShare()
+		sender, err := receiveShare(p.transport, p.Name, shamirShareKind, others, share)
+		if err != nil {
+			return err
+		}
+		others = remove(others, sender)
+		thresholdizer.AggregateShares(own, share, own)
+	}
+
+	p.thresholdShare = own
+	p.combiner = drlwe.NewCombiner(p.Params.Parameters, p.config.point(p.Name), p.config.points(p.config.Parties), p.config.Threshold)
+	p.secretKey = nil
+	return nil
+}
+
+// ServeDecryption waits for a decryption request from the aggregator and answers it with the party's share
+func (p *ThresholdParty) ServeDecryption() error {
+	if p.thresholdShare == nil {
+		return ErrKeysNotGenerated
+	}
+
+	msg, err := p.transport.Receive(p.Name, decryptionRequestKind)
+	if err != nil {
+		return err
+	}
+	if msg.From != ThresholdAggregatorName {
+		return fmt.Errorf("%w: %s", ErrUnexpectedSender, msg.From)
+	}
+
+	var request decryptionRequest
+	if err = json.Unmarshal(msg.Data, &request); err != nil {
+		return fmt.Errorf("malformed decryption request: %w", err)
+	}
+	ct := new(rlwe.Ciphertext)
+	if err = ct.UnmarshalBinary(request.Ciphertext); err != nil {
+		return fmt.Errorf("malformed decryption request: %w", err)
+	}
+
+	share, err := p.decryptionShare(ct, request.Active)
+	if err != nil {
+		return err
+	}
+	return p.send(ThresholdAggregatorName, decryptionShareKind, share)
+}
+
+// decryptionShare returns the share of the party in the decryption of ct by the active parties
+// The Shamir share is first turned into an additive share of the secret key among the active parties,
+// then used to switch the ciphertext to the zero key, smudged with noise.
+func (p *ThresholdParty) decryptionShare(ct *rlwe.Ciphertext, active []string) (*drlwe.CKSShare, error) {
+	additiveShare, err := p.additiveShare(active)
+	if err != nil {
+		return nil, err
+	}
+
+	share := p.cks.AllocateShare(ct.Level())
+	p.cks.GenShare(additiveShare, rlwe.NewSecretKey(p.Params.Parameters), ct, share)
+	if err = floodShare(p.Params, p.config.smudgingSigma(p.Params), share.Value, ct.Level(), ct.IsNTT); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// additiveShare turns the Shamir share of the party into an additive share of the secret key among the active parties
+func (p *ThresholdParty) additiveShare(active []string) (*rlwe.SecretKey, error) {
+	if len(active) < p.config.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughParties, len(active), p.config.Threshold)
+	}
+	if !contains(active, p.Name) {
+		return nil, fmt.Errorf("%w: %s is not an active party", ErrThresholdConfig, p.Name)
+	}
+
+	additiveShare := rlwe.NewSecretKey(p.Params.Parameters)
+	p.combiner.GenAdditiveShare(p.config.points(active), p.config.point(p.Name), p.thresholdShare, additiveShare)
+	return additiveShare, nil
+}
+
+// send serializes a share and sends it to another party
+func (p *ThresholdParty) send(to string, kind string, share encoding.BinaryMarshaler) error {
+	return sendShare(p.transport, p.Name, to, kind, share)
+}
+
+// GenerateKeys runs the aggregator side of the collective key generation and stores the collective public
+// and relinearization keys
+func (a *ThresholdAggregator) GenerateKeys() error {
+	parties := a.config.Parties
+
+	// Collective public key
+	ckg := dckks.NewCKGProtocol(a.Params)
+	ckgCRS, err := a.config.crs(ckgShareKind)
+	if err != nil {
+		return err
+	}
+	ckgCRP := ckg.SampleCRP(ckgCRS)
+	ckgAggregate := ckg.AllocateShare()
+	if err = aggregateShares(a.transport, ckgShareKind, parties, ckg.AllocateShare, ckgAggregate, func(share *drlwe.CKGShare) {
+		ckg.AggregateShares(ckgAggregate, share, ckgAggregate)
+	}); err != nil {
+		return err
+	}
+	pk := rlwe.NewPublicKey(a.Params.Parameters)
+	ckg.GenPublicKey(ckgAggregate, ckgCRP, pk)
+
+	// Collective relinearization key, in two rounds
+	rkg := dckks.NewRKGProtocol(a.Params)
+	_, roundOne, roundTwo := rkg.AllocateShare()
+	newRKGShare := func() *drlwe.RKGShare { return new(drlwe.RKGShare) }
+
+	if err = aggregateShares(a.transport, rkgRoundOneKind, parties, newRKGShare, roundOne, func(share *drlwe.RKGShare) {
+		rkg.AggregateShares(roundOne, share, roundOne)
+	}); err != nil {
+		return err
+	}
+	for _, party := range parties {
+		if err = sendShare(a.transport, ThresholdAggregatorName, party, rkgRoundOneAggregateKind, roundOne); err != nil {
+			return err
+		}
+	}
+
+	if err = aggregateShares(a.transport, rkgRoundTwoKind, parties, newRKGShare, roundTwo, func(share *drlwe.RKGShare) {
+		rkg.AggregateShares(roundTwo, share, roundTwo)
+	}); err != nil {
+		return err
+	}
+	rlk := rlwe.NewRelinearizationKey(a.Params.Parameters, 1)
+	rkg.GenRelinearizationKey(roundOne, roundTwo, rlk)
+
+	a.PublicKey = pk
+	a.Relinearizer = rlk
+	return nil
+}
+
+// aggregateShares receives one share of the given kind from every party and folds them into aggregate
+// The first share received is decoded into aggregate directly, the next ones into a fresh share passed to add.
+func aggregateShares[T encoding.BinaryUnmarshaler](transport Transport, kind string, parties []string, allocate func() T, aggregate T, add func(T)) error {
+	pending := append([]string{}, parties...)
+	for i := range parties {
+		share := aggregate
+		if i > 0 {
+			share = allocate()
+		}
+
+		sender, err := receiveShare(transport, ThresholdAggregatorName, kind, pending, share)
+		if err != nil {
+			return err
+		}
+		pending = remove(pending, sender)
+
+		if i > 0 {
+			add(share)
+		}
+	}
+	return nil
+}
+
+// Encryptor returns an encryptor under the collective public key
+func (a *ThresholdAggregator) Encryptor() (*CKKSEncryptor, error) {
+	if a.PublicKey == nil {
+		return nil, ErrKeysNotGenerated
+	}
+	return newCKKSEncryptor(a.Params, a.Encoder, a.PublicKey), nil
+}
+
+// Evaluator returns an evaluator using the collective relinearization key
+func (a *ThresholdAggregator) Evaluator() (*CKKSEvaluator, error) {
+	if a.Relinearizer == nil {
+		return nil, ErrKeysNotGenerated
+	}
+	return newCKKSEvaluator(a.Params, a.policy, a.Encoder, a.Relinearizer), nil
+}
+
+// Decrypt decrypts the first slot of a ciphertext encrypted under the collective key
+// It needs the cooperation of Threshold of the active parties, each of them serving one decryption request.
+func (a *ThresholdAggregator) Decrypt(ct *rlwe.Ciphertext, active []string) (float64, error) {
+	values, err := a.DecryptVector(ct, 1, active)
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// DecryptVector decrypts the first n slots of a ciphertext encrypted under the collective key
+// Only the first Threshold active parties are asked for a share, each flooded as configured by Flooding.
+func (a *ThresholdAggregator) DecryptVector(ct *rlwe.Ciphertext, n int, active []string) ([]float64, error) {
+	if n < 1 || n > 1<<a.LogSlots {
+		return nil, fmt.Errorf("%w: %d values for %d slots", ErrTooManyValues, n, 1<<a.LogSlots)
+	}
+	active, err := a.activeParties(active)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask the active parties for their decryption shares
+	data, err := ct.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	request, err := json.Marshal(decryptionRequest{Active: active, Ciphertext: data})
+	if err != nil {
+		return nil, err
+	}
+	for _, party := range active {
+		if err = a.transport.Send(Message{From: ThresholdAggregatorName, To: party, Kind: decryptionRequestKind, Data: request}); err != nil {
+			return nil, err
+		}
+	}
+
+	combined := a.cks.AllocateShare(ct.Level())
+	if err = aggregateShares(a.transport, decryptionShareKind, active, func() *drlwe.CKSShare { return new(drlwe.CKSShare) }, combined, func(share *drlwe.CKSShare) {
+		a.cks.AggregateShares(combined, share, combined)
+	}); err != nil {
+		return nil, err
+	}
+
+	// Switch the ciphertext to the zero key, then decrypt it
+	switched := ckks.NewCiphertext(a.Params, 1, ct.Level())
+	a.cks.KeySwitch(ct, combined, switched)
+
+	decoded := a.Encoder.Decode(a.decryptor.DecryptNew(switched), a.LogSlots)
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = real(decoded[i])
+	}
+	return values, nil
+}
+
+// activeParties checks the parties cooperating in a decryption and keeps the first Threshold of them
+func (a *ThresholdAggregator) activeParties(active []string) ([]string, error) {
+	if len(active) < a.config.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughParties, len(active), a.config.Threshold)
+	}
+	for i, party := range active {
+		if a.config.point(party) == 0 || contains(active[:i], party) {
+			return nil, fmt.Errorf("%w: invalid or duplicate active party %q", ErrThresholdConfig, party)
+		}
+	}
+	return active[:a.config.Threshold], nil
+}
+
+// sendShare serializes a share and sends it through the transport
+func sendShare(transport Transport, from, to, kind string, share encoding.BinaryMarshaler) error {
+	data, err := share.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", kind, err)
+	}
+	return transport.Send(Message{From: from, To: to, Kind: kind, Data: data})
+}
+
+// receiveShare receives a share of the given kind from one of the expected senders and returns the sender
+func receiveShare(transport Transport, party, kind string, senders []string, share encoding.BinaryUnmarshaler) (string, error) {
+	msg, err := transport.Receive(party, kind)
+	if err != nil {
+		return "", err
+	}
+	if !contains(senders, msg.From) {
+		return "", fmt.Errorf("%w: %s sent a %s", ErrUnexpectedSender, msg.From, kind)
+	}
+	if err = share.UnmarshalBinary(msg.Data); err != nil {
+		return "", fmt.Errorf("malformed %s from %s: %w", kind, msg.From, err)
+	}
+	return msg.From, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+package encryption
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+)
+
+// newThresholdTestSetup runs the collective key generation of the given configuration over a local transport
+func newThresholdTestSetup(t *testing.T, config ThresholdConfig) (*ThresholdAggregator, map[string]*ThresholdParty) {
+	t.Helper()
+
+	transport := NewLocalTransport(append([]string{ThresholdAggregatorName}, config.Parties...)...)
+	t.Cleanup(transport.Close)
+
+	aggregator, err := NewThresholdAggregator(config, transport)
+	if err != nil {
+		t.Fatalf("NewThresholdAggregator failed: %v", err)
+	}
+	parties := make(map[string]*ThresholdParty, len(config.Parties))
+	for _, name := range config.Parties {
+		if parties[name], err = NewThresholdParty(name, config, transport); err != nil {
+			t.Fatalf("NewThresholdParty failed: %v", err)
+		}
+	}
+
+	// Every party runs its side of the protocol concurrently, as it would on its own machine
+	var wg sync.WaitGroup
+	errs := make(chan error, len(parties))
+	for _, party := range parties {
+		wg.Add(1)
+		go func(party *ThresholdParty) {
+			defer wg.Done()
+			errs <- party.GenerateKeys()
+		}(party)
+	}
+	if err = aggregator.GenerateKeys(); err != nil {
+		t.Fatalf("aggregator key generation failed: %v", err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("party key generation failed: %v", err)
+		}
+	}
+
+	return aggregator, parties
+}
+
+// serveDecryption lets the named parties answer one decryption request each
+func serveDecryption(t *testing.T, parties map[string]*ThresholdParty, names ...string) *sync.WaitGroup {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(party *ThresholdParty) {
+			defer wg.Done()
+			if err := party.ServeDecryption(); err != nil {
+				t.Errorf("%s failed to serve the decryption: %v", party.Name, err)
+			}
+		}(parties[name])
+	}
+	return &wg
+}
+
+// TestThresholdDecryption ensures that a result computed under the collective key is decrypted by any 2 of 3 parties
+func TestThresholdDecryption(t *testing.T) {
+	config := ThresholdConfig{
+		Parties:   []string{"owner", "issuer", "government"},
+		Threshold: 2,
+		Seed:      []byte("threshold test"),
+	}
+	aggregator, parties := newThresholdTestSetup(t, config)
+
+	encryptor, err := aggregator.Encryptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator, err := aggregator.Evaluator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The product needs the collective relinearization key
+	value1, value2 := 3.5, -2.25
+	product, err := evaluator.Multiply(encryptor.EncryptPu(value1), encryptor.EncryptPu(value2))
+	if err != nil {
+		t.Fatalf("Multiply failed: %v", err)
+	}
+
+	for _, active := range [][]string{{"owner", "issuer"}, {"government", "owner"}, {"issuer", "government"}} {
+		wg := serveDecryption(t, parties, active...)
+		result, err := aggregator.Decrypt(product, active)
+		wg.Wait()
+		if err != nil {
+			t.Fatalf("Decrypt with %v failed: %v", active, err)
+		}
+		if math.Abs(result-value1*value2) > DefaultFloodingPrecision*math.Sqrt(float64(config.Threshold)) {
+			t.Errorf("Decrypt with %v failed. Got %f, expected %f", active, result, value1*value2)
+		}
+	}
+
+	// Every active party floods its share, so the values carry sqrt(Threshold) times the flooding noise
+	slots := 1 << aggregator.LogSlots
+	zeros, err := encryptor.EncryptVectorPu(make([]float64, slots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := serveDecryption(t, parties, "owner", "issuer")
+	values, err := aggregator.DecryptVector(zeros, slots, []string{"owner", "issuer"})
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("DecryptVector failed: %v", err)
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v * v
+	}
+	expected := config.Flooding.sigma() * math.Sqrt(float64(config.Threshold))
+	if deviation := math.Sqrt(sum / float64(slots)); math.Abs(deviation-expected) > 0.1*expected {
+		t.Errorf("flooding noise has a standard deviation of %g, expected %g", deviation, expected)
+	}
+
+	// A single party cannot decrypt
+	if _, err = aggregator.Decrypt(product, []string{"owner"}); !errors.Is(err, ErrNotEnoughParties) {
+		t.Errorf("Decrypt with a single party returned %v, expected %v", err, ErrNotEnoughParties)
+	}
+	if _, err = aggregator.Decrypt(product, []string{"owner", "owner"}); !errors.Is(err, ErrThresholdConfig) {
+		t.Errorf("Decrypt with a duplicate party returned %v, expected %v", err, ErrThresholdConfig)
+	}
+}
+
+// TestThresholdConfig ensures that inconsistent configurations are refused
+func TestThresholdConfig(t *testing.T) {
+	transport := NewLocalTransport()
+	for name, config := range map[string]ThresholdConfig{
+		"no parties":      {Threshold: 1, Seed: []byte("seed")},
+		"threshold":       {Parties: []string{"owner", "issuer"}, Threshold: 3, Seed: []byte("seed")},
+		"seed":            {Parties: []string{"owner", "issuer"}, Threshold: 2},
+		"duplicate party": {Parties: []string{"owner", "owner"}, Threshold: 2, Seed: []byte("seed")},
+		"aggregator name": {Parties: []string{"owner", ThresholdAggregatorName}, Threshold: 2, Seed: []byte("seed")},
+	} {
+		if _, err := NewThresholdAggregator(config, transport); !errors.Is(err, ErrThresholdConfig) {
+			t.Errorf("%s: NewThresholdAggregator returned %v, expected %v", name, err, ErrThresholdConfig)
+		}
+	}
+
+	config := ThresholdConfig{Parties: []string{"owner", "issuer"}, Threshold: 2, Seed: []byte("seed")}
+	if _, err := NewThresholdParty("lender", config, transport); !errors.Is(err, ErrThresholdConfig) {
+		t.Errorf("NewThresholdParty for an unknown party returned %v, expected %v", err, ErrThresholdConfig)
+	}
+}
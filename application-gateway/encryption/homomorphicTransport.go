@@ -0,0 +1,95 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrTransportClosed = errors.New("transport is closed")
+	ErrUnknownParty    = errors.New("unknown party")
+)
+
+// Message is a serialized protocol message exchanged between the parties of a threshold key
+type Message struct {
+	From string
+	To   string
+	Kind string
+	Data []byte
+}
+
+// Transport carries protocol messages between the parties of a threshold key
+// Receive blocks until a message of the given kind addressed to the party arrives; messages of other kinds
+// are kept for later calls, so that parties progressing at different speeds do not lose messages.
+type Transport interface {
+	Send(msg Message) error
+	Receive(party string, kind string) (Message, error)
+}
+
+// LocalTransport is an in-process Transport, used to run every party of a protocol in a single program
+type LocalTransport struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[string][]Message
+	closed  bool
+}
+
+// NewLocalTransport creates an in-process transport between the named parties
+func NewLocalTransport(parties ...string) *LocalTransport {
+	transport := &LocalTransport{pending: make(map[string][]Message, len(parties))}
+	transport.cond = sync.NewCond(&transport.mu)
+	for _, party := range parties {
+		transport.pending[party] = nil
+	}
+	return transport
+}
+
+// Send queues a message for its recipient
+func (t *LocalTransport) Send(msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrTransportClosed
+	}
+	if _, ok := t.pending[msg.To]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownParty, msg.To)
+	}
+
+	t.pending[msg.To] = append(t.pending[msg.To], msg)
+	t.cond.Broadcast()
+	return nil
+}
+
+// Receive returns the oldest message of the given kind queued for the party, waiting for one if needed
+func (t *LocalTransport) Receive(party string, kind string) (Message, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.pending[party]; !ok {
+		return Message{}, fmt.Errorf("%w: %s", ErrUnknownParty, party)
+	}
+
+	for {
+		for i, msg := range t.pending[party] {
+			if msg.Kind == kind {
+				t.pending[party] = append(t.pending[party][:i], t.pending[party][i+1:]...)
+				return msg, nil
+			}
+		}
+		if t.closed {
+			return Message{}, ErrTransportClosed
+		}
+		t.cond.Wait()
+	}
+}
+
+// Close wakes up every pending Receive, which then returns ErrTransportClosed
+func (t *LocalTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	t.cond.Broadcast()
+}
@@ -0,0 +1,178 @@
+package encryption
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"fmt"
+	"math/bits"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Encrypted features cannot pick a branch, so every leaf of a tree is weighted by the indicator of its path: the
+// product of step(x - threshold) for each split taken to the right and 1 - step(x - threshold) for each split taken
+// to the left. Away from the thresholds exactly one indicator is about 1 and the others about 0; within
+// policy.Margin() of a threshold, the score interpolates between the leaves on both sides.
+
+// split identifies a comparison shared by several nodes of an ensemble
+type split struct {
+	feature   int
+	threshold float64
+}
+
+// TreeDepth returns the number of levels consumed by TreeScore: the comparisons, which are all evaluated on the
+// features, the products along the deepest path and the weighting by the leaf values
+func TreeDepth(ensemble *credit_evaluation.TreeEnsemble, policy ComparisonPolicy) int {
+	depth := ensemble.Depth()
+	if depth == 0 {
+		return 0
+	}
+	return policy.Depth() + bits.Len(uint(depth-1)) + 1
+}
+
+// EnsembleSigmoidPolicy returns a sigmoid approximation covering every score of the ensemble
+func EnsembleSigmoidPolicy(ensemble *credit_evaluation.TreeEnsemble) SigmoidPolicy {
+	return sigmoidPolicyFor(ensemble.ScoreRange())
+}
+
+// TreeScore evaluates the score of a tree ensemble on encrypted raw features
+// Each distinct split is compared once for the whole ensemble. Away from the thresholds, the error of a tree is
+// about its depth times SignPrecision times its largest leaf value. It consumes TreeDepth levels.
+func (c *CKKSEvaluator) TreeScore(ensemble *credit_evaluation.TreeEnsemble, features []*rlwe.Ciphertext, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	if err := ensemble.Validate(); err != nil {
+		return nil, err
+	}
+	if len(features) != len(ensemble.Features) {
+		return nil, fmt.Errorf("%w: got %d, expected %d", credit_evaluation.ErrFeatureMismatch, len(features), len(ensemble.Features))
+	}
+	if ensemble.Depth() == 0 {
+		return nil, fmt.Errorf("%w: the ensemble has no split", credit_evaluation.ErrInvalidModel)
+	}
+	if err := c.checkCircuitDepth(TreeDepth(ensemble, policy), features...); err != nil {
+		return nil, err
+	}
+
+	t := &treeEvaluation{
+		evaluator: c,
+		ensemble:  ensemble,
+		features:  features,
+		policy:    policy,
+		steps:     make(map[split][2]*rlwe.Ciphertext),
+		constant:  ensemble.BaseScore,
+	}
+	for _, tree := range ensemble.Trees {
+		if err := t.walk(tree, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// Every tree has a split, so the score holds at least one weighted leaf
+	c.Evaluator.AddConst(t.score, t.constant, t.score)
+	return t.score, nil
+}
+
+// TreeOutput evaluates the output of a tree ensemble on encrypted raw features, applying the sigmoid of
+// EnsembleSigmoidPolicy to the score for a logistic link
+// The features must lie in their declared ranges, outside of which the comparisons diverge.
+func (c *CKKSEvaluator) TreeOutput(ensemble *credit_evaluation.TreeEnsemble, features []*rlwe.Ciphertext, policy ComparisonPolicy) (*rlwe.Ciphertext, error) {
+	if ensemble.Link != credit_evaluation.LinkLogistic {
+		return c.TreeScore(ensemble, features, policy)
+	}
+
+	sigmoid := EnsembleSigmoidPolicy(ensemble)
+	if err := c.checkCircuitDepth(TreeDepth(ensemble, policy)+sigmoid.Depth(), features...); err != nil {
+		return nil, err
+	}
+	score, err := c.TreeScore(ensemble, features, policy)
+	if err != nil {
+		return nil, err
+	}
+	return c.Sigmoid(score, sigmoid)
+}
+
+// treeEvaluation accumulates the weighted leaves of an ensemble
+type treeEvaluation struct {
+	evaluator *CKKSEvaluator
+	ensemble  *credit_evaluation.TreeEnsemble
+	features  []*rlwe.Ciphertext
+	policy    ComparisonPolicy
+
+	// steps caches the left and right indicators of each split
+	steps map[split][2]*rlwe.Ciphertext
+
+	// score sums the weighted leaves, constant the base score and the leaves reached without any split
+	score    *rlwe.Ciphertext
+	constant float64
+}
+
+// walk adds the leaves of a subtree to the score, path holding the indicators of the splits leading to it
+func (t *treeEvaluation) walk(node *credit_evaluation.TreeNode, path []*rlwe.Ciphertext) error {
+	c := t.evaluator
+	if node.IsLeaf() {
+		if len(path) == 0 {
+			t.constant += node.Value
+			return nil
+		}
+
+		indicator, err := c.product(path)
+		if err != nil {
+			return err
+		}
+
+		t.score, err = c.accumulate(t.score, indicator, node.Value)
+		return err
+	}
+
+	indicators, err := t.indicators(node)
+	if err != nil {
+		return err
+	}
+
+	// The path is copied so that both branches extend their own
+	left := append(append([]*rlwe.Ciphertext{}, path...), indicators[0])
+	if err := t.walk(node.Left, left); err != nil {
+		return err
+	}
+	right := append(append([]*rlwe.Ciphertext{}, path...), indicators[1])
+	return t.walk(node.Right, right)
+}
+
+// indicators returns 1 - step(x - threshold) and step(x - threshold) for the split of a node
+func (t *treeEvaluation) indicators(node *credit_evaluation.TreeNode) ([2]*rlwe.Ciphertext, error) {
+	i := t.ensemble.FeatureIndex(node.Feature)
+	key := split{feature: i, threshold: node.Threshold}
+	if indicators, ok := t.steps[key]; ok {
+		return indicators, nil
+	}
+
+	c := t.evaluator
+	f := t.ensemble.Features[i]
+	step, err := c.GreaterThan(t.features[i], node.Threshold, f.Min, f.Max, t.policy)
+	if err != nil {
+		return [2]*rlwe.Ciphertext{}, fmt.Errorf("feature %s: %w", f.Name, err)
+	}
+	complement := c.Evaluator.NegNew(step)
+	c.Evaluator.AddConst(complement, 1, complement)
+
+	indicators := [2]*rlwe.Ciphertext{complement, step}
+	t.steps[key] = indicators
+	return indicators, nil
+}
+
+// product multiplies ciphertexts pairwise, so that n factors consume ceil(log2(n)) levels
+func (c *CKKSEvaluator) product(cts []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+	for len(cts) > 1 {
+		next := make([]*rlwe.Ciphertext, 0, (len(cts)+1)/2)
+		for i := 0; i+1 < len(cts); i += 2 {
+			result, err := c.Multiply(cts[i], cts[i+1])
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, result)
+		}
+		if len(cts)%2 == 1 {
+			next = append(next, cts[len(cts)-1])
+		}
+		cts = next
+	}
+	return cts[0], nil
+}
@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// testEnsemble is a small gradient-boosted classifier with a balanced tree, an unbalanced tree and a single leaf,
+// with salaries in millions
+var testEnsemble = credit_evaluation.TreeEnsemble{
+	Name: "test",
+	Features: []credit_evaluation.FeatureRange{
+		{Name: "CreditScore", Min: MinCreditScore, Max: MaxCreditScore},
+		{Name: "DTI", Min: 0, Max: MaxDTI},
+		{Name: "Salary", Min: 0, Max: 200},
+	},
+	Trees: []*credit_evaluation.TreeNode{
+		{
+			Feature: "CreditScore", Threshold: 650,
+			Left: &credit_evaluation.TreeNode{
+				Feature: "Salary", Threshold: 50,
+				Left:  &credit_evaluation.TreeNode{Value: -1.1},
+				Right: &credit_evaluation.TreeNode{Value: -0.3},
+			},
+			Right: &credit_evaluation.TreeNode{
+				Feature: "DTI", Threshold: 0.4,
+				Left:  &credit_evaluation.TreeNode{Value: 0.9},
+				Right: &credit_evaluation.TreeNode{Value: 0.2},
+			},
+		},
+		{
+			Feature: "DTI", Threshold: 0.6,
+			Left: &credit_evaluation.TreeNode{
+				Feature: "CreditScore", Threshold: 500,
+				Left:  &credit_evaluation.TreeNode{Value: 0.1},
+				Right: &credit_evaluation.TreeNode{Value: 0.5},
+			},
+			Right: &credit_evaluation.TreeNode{Value: -0.8},
+		},
+		{Value: 0.05},
+	},
+	BaseScore: -0.1,
+	Link:      credit_evaluation.LinkLogistic,
+}
+
+// TestTreeEnsembleParity ensures that the encrypted score and probability of a tree ensemble match its plaintext
+// evaluation for a batch of applicants away from the thresholds
+func TestTreeEnsembleParity(t *testing.T) {
+	policy := ComparisonPolicy{Expansions: 2, Refinements: 2}
+	depth := TreeDepth(&testEnsemble, policy) + EnsembleSigmoidPolicy(&testEnsemble).Depth()
+	helper := newLevelsTestHelper(t, depth)
+
+	applicants := [][]float64{
+		{800, 0.2, 150},
+		{420, 0.9, 12},
+		{700, 0.7, 30},
+		{550, 0.3, 100},
+		{300, 0, 0},
+		{850, 1, 200},
+	}
+
+	features := encryptColumns(t, helper, applicants, len(testEnsemble.Features))
+
+	scoreCiphertext, err := helper.TreeScore(&testEnsemble, features, policy)
+	if err != nil {
+		t.Fatalf("TreeScore failed: %v", err)
+	}
+	outputCiphertext, err := helper.TreeOutput(&testEnsemble, features, policy)
+	if err != nil {
+		t.Fatalf("TreeOutput failed: %v", err)
+	}
+
+	scores := helper.DecryptVector(scoreCiphertext, len(applicants))
+	outputs := helper.DecryptVector(outputCiphertext, len(applicants))
+	for j, applicant := range applicants {
+		expectedScore, err := testEnsemble.Score(applicant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedOutput, _ := testEnsemble.Output(applicant)
+		if math.Abs(scores[j]-expectedScore) > 1e-3 {
+			t.Errorf("applicant %d: TreeScore got %f, expected %f", j, scores[j], expectedScore)
+		}
+		if math.Abs(outputs[j]-expectedOutput) > 1e-3 {
+			t.Errorf("applicant %d: TreeOutput got %f, expected %f", j, outputs[j], expectedOutput)
+		}
+	}
+
+	if _, err = helper.TreeScore(&testEnsemble, features[:2], policy); !errors.Is(err, credit_evaluation.ErrFeatureMismatch) {
+		t.Errorf("TreeScore with a missing feature returned %v, expected %v", err, credit_evaluation.ErrFeatureMismatch)
+	}
+	shallow := make([]*rlwe.Ciphertext, len(features))
+	for i, feature := range features {
+		shallow[i] = helper.Evaluator.DropLevelNew(feature, feature.Level()-TreeDepth(&testEnsemble, policy)+1)
+	}
+	if _, err = helper.TreeScore(&testEnsemble, shallow, policy); !errors.Is(err, ErrDepthExhausted) {
+		t.Errorf("TreeScore without enough levels returned %v, expected %v", err, ErrDepthExhausted)
+	}
+}
+
+// newLevelsTestHelper creates a small helper with the given number of levels
+func newLevelsTestHelper(t *testing.T, depth int) *CKKSHelper {
+	logQ := []int{55}
+	for i := 0; i < depth; i++ {
+		logQ = append(logQ, 40)
+	}
+
+	helper, err := NewCKKSHelperWithOptions(HelperOptions{LogN: 13, LogQ: logQ, LogP: []int{55, 55}})
+	if err != nil {
+		t.Fatalf("NewCKKSHelperWithOptions failed: %v", err)
+	}
+	return helper
+}
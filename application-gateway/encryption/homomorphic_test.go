@@ -1,6 +1,7 @@
 package encryption
 
 import (
+	"errors"
 	"math"
 	"testing"
 )
@@ -87,7 +88,10 @@ func TestAdd(t *testing.T) {
 	ct2 := helper.EncryptPu(value2)
 
 	// Perform homomorphic addition
-	ctAdd := helper.Add(ct1, ct2)
+	ctAdd, err := helper.Add(ct1, ct2)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
 
 	// Decrypt the result
 	result := helper.Decrypt(ctAdd)
@@ -133,7 +137,10 @@ func TestMultiply(t *testing.T) {
 	ct2 := helper.EncryptPu(value2)
 
 	// Perform homomorphic multiplication
-	ctMul := helper.Multiply(ct1, ct2)
+	ctMul, err := helper.Multiply(ct1, ct2)
+	if err != nil {
+		t.Fatalf("Multiply failed: %v", err)
+	}
 
 	// Decrypt the result
 	result := helper.Decrypt(ctMul)
@@ -156,7 +163,10 @@ func TestMultiplyPlain(t *testing.T) {
 	ct1 := helper.EncryptPu(value1)
 
 	// Perform homomorphic multiplication with a plaintext
-	ctMulPlain := helper.MultiplyPlain(ct1, value2)
+	ctMulPlain, err := helper.MultiplyPlain(ct1, value2)
+	if err != nil {
+		t.Fatalf("MultiplyPlain failed: %v", err)
+	}
 
 	// Decrypt the result
 	result := helper.Decrypt(ctMulPlain)
@@ -179,7 +189,10 @@ func TestDivideByPlain(t *testing.T) {
 	ct1 := helper.EncryptPu(value1)
 
 	// Perform homomorphic division by a plaintext
-	ctDivPlain := helper.DivideByPlain(ct1, value2)
+	ctDivPlain, err := helper.DivideByPlain(ct1, value2)
+	if err != nil {
+		t.Fatalf("DivideByPlain failed: %v", err)
+	}
 
 	// Decrypt the result
 	result := helper.Decrypt(ctDivPlain)
@@ -202,8 +215,11 @@ func TestDivide(t *testing.T) {
 	ct1 := helper.EncryptPu(value1)
 	ct2 := helper.EncryptPu(value2)
 
-	// Perform homomorphic division
-	ctDiv := helper.Divide(ct1, ct2)
+	// Perform homomorphic division, declaring the divisor to lie in [1, 4]
+	ctDiv, err := helper.Divide(ct1, ct2, 1, 4, InverseIterations(1, 4, 1e-7))
+	if err != nil {
+		t.Fatalf("Divide failed: %v", err)
+	}
 
 	// Decrypt the result
 	result := helper.Decrypt(ctDiv)
@@ -228,7 +244,10 @@ func TestSigmoid(t *testing.T) {
 	ct := helper.EncryptPu(value)
 
 	// Apply the homomorphic sigmoid function
-	ctSigmoid := helper.sigmoid(helper, ct)
+	ctSigmoid, err := helper.Sigmoid(ct, DefaultSigmoidPolicy())
+	if err != nil {
+		t.Fatalf("Sigmoid failed: %v", err)
+	}
 
 	// Decrypt the result
 	result := helper.Decrypt(ctSigmoid)
@@ -241,3 +260,37 @@ func TestSigmoid(t *testing.T) {
 		t.Errorf("Sigmoid failed. Got %f, expected %f", result, expected)
 	}
 }
+
+// TestInverse tests the homomorphic inverse over a declared range, including negative ranges
+func TestInverse(t *testing.T) {
+	helper := NewCKKSHelper()
+
+	tests := []struct {
+		value, lo, hi float64
+	}{
+		{0.5, 0.1, 1},
+		{0.1, 0.1, 1},
+		{3, 1, 4},
+		{-2, -4, -1},
+	}
+
+	for _, tt := range tests {
+		ct := helper.EncryptPu(tt.value)
+
+		ctInv, err := helper.Inverse(ct, tt.lo, tt.hi, InverseIterations(tt.lo, tt.hi, 1e-7))
+		if err != nil {
+			t.Fatalf("Inverse failed: %v", err)
+		}
+
+		// The inverse amplifies the encryption noise by 1/value^2, so the check is relative
+		result := helper.Decrypt(ctInv)
+		expected := 1 / tt.value
+		if math.Abs(result-expected) > 1e-3*math.Abs(expected) {
+			t.Errorf("Inverse(%f) over [%f, %f] failed. Got %f, expected %f", tt.value, tt.lo, tt.hi, result, expected)
+		}
+	}
+
+	if _, err := helper.Inverse(helper.EncryptPu(1), -1, 1, 3); !errors.Is(err, ErrInverseRange) {
+		t.Errorf("Inverse over a range containing zero returned %v, expected %v", err, ErrInverseRange)
+	}
+}
@@ -0,0 +1,266 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// SchemeECIES names the scheme of envelopes holding hybrid ciphertexts of non-numeric fields
+const SchemeECIES = "ecies"
+
+// eciesInfo is the shared info of the key derivation, binding the derived keys to this scheme
+const eciesInfo = "credit-evaluation ecies p256 aes-256-gcm"
+
+var (
+	ErrHybridDecryption = errors.New("hybrid ciphertext cannot be decrypted")
+	ErrNotHybridKey     = errors.New("encoded key is not a P-256 ECDH public key")
+	ErrMissingKey       = errors.New("no key for the scheme of the field")
+)
+
+// GenHybridKey generates a P-256 key pair for the hybrid encryption of non-numeric fields
+func GenHybridKey() (*ecdh.PrivateKey, error) {
+	return ecdh.P256().GenerateKey(rand.Reader)
+}
+
+// HybridEncrypt encrypts a message to a P-256 public key, ECIES style: an ephemeral key agreement derives an
+// AES-256-GCM key, and the result is the ephemeral public key, the nonce and the sealed message.
+// The associated data, such as the field name, is authenticated but not encrypted.
+func HybridEncrypt(pub *ecdh.PublicKey, message, associatedData []byte) ([]byte, error) {
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	aead, err := newHybridAEAD(shared, ephemeralPub, pub.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	data := append(append([]byte{}, ephemeralPub...), nonce...)
+	return aead.Seal(data, nonce, message, associatedData), nil
+}
+
+// HybridDecrypt decrypts a message encrypted by HybridEncrypt with the matching private key
+func HybridDecrypt(priv *ecdh.PrivateKey, data, associatedData []byte) ([]byte, error) {
+	// Uncompressed P-256 points are 65 bytes long
+	const pointSize = 65
+	if len(data) < pointSize {
+		return nil, ErrHybridDecryption
+	}
+	ephemeral, err := ecdh.P256().NewPublicKey(data[:pointSize])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHybridDecryption, err)
+	}
+	shared, err := priv.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHybridDecryption, err)
+	}
+
+	aead, err := newHybridAEAD(shared, data[:pointSize], priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < pointSize+aead.NonceSize() {
+		return nil, ErrHybridDecryption
+	}
+	nonce := data[pointSize : pointSize+aead.NonceSize()]
+	message, err := aead.Open(nil, nonce, data[pointSize+aead.NonceSize():], associatedData)
+	if err != nil {
+		return nil, ErrHybridDecryption
+	}
+	return message, nil
+}
+
+// newHybridAEAD derives the AES-256-GCM key of a hybrid ciphertext with the ANSI X9.63 KDF of SEC 1,
+// over the shared secret and both public keys
+func newHybridAEAD(shared, ephemeralPub, recipientPub []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write(shared)
+	h.Write(binary.BigEndian.AppendUint32(nil, 1))
+	h.Write(ephemeralPub)
+	h.Write(recipientPub)
+	h.Write([]byte(eciesInfo))
+
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncodeHybridKey serializes a P-256 ECDH public key as base64 PKIX, to be published on the ledger
+func EncodeHybridKey(pub *ecdh.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// DecodeHybridKey parses a P-256 ECDH public key encoded by EncodeHybridKey
+func DecodeHybridKey(encoded string) (*ecdh.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encoded hybrid key is malformed: %w", err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("encoded hybrid key is malformed: %w", err)
+	}
+	// PKIX parses P-256 keys as ECDSA keys, which convert to ECDH keys
+	switch key := pub.(type) {
+	case *ecdh.PublicKey:
+		return key, nil
+	case *ecdsa.PublicKey:
+		return key.ECDH()
+	default:
+		return nil, ErrNotHybridKey
+	}
+}
+
+// WriteHybridKey stores a hybrid private key as PKCS #8 PEM, readable by its owner only
+func WriteHybridKey(path string, privateKey *ecdh.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+}
+
+// ReadHybridKey reads a hybrid private key written by WriteHybridKey
+func ReadHybridKey(path string) (*ecdh.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("hybrid key file %s is not PEM encoded", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	// PKCS #8 parses P-256 keys as ECDSA keys, which convert to ECDH keys
+	switch key := key.(type) {
+	case *ecdh.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key.ECDH()
+	default:
+		return nil, fmt.Errorf("hybrid key file %s does not hold a P-256 key", path)
+	}
+}
+
+// HybridKeyID returns a short digest identifying a P-256 ECDH public key
+func HybridKeyID(pub *ecdh.PublicKey) string {
+	sum := sha256.Sum256(pub.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// FieldEncryptor encrypts the fields of a document to their owner: numbers under the owner's CKKS public key,
+// so that they can be evaluated, and text under the owner's hybrid key
+type FieldEncryptor struct {
+	CKKS   *CKKSEncryptor
+	Hybrid *ecdh.PublicKey
+}
+
+// EncryptNumber encrypts a numeric field into a compressed CKKS envelope
+func (f *FieldEncryptor) EncryptNumber(value float64) (string, error) {
+	if f.CKKS == nil {
+		return "", fmt.Errorf("%w: %s", ErrMissingKey, SchemeCKKS)
+	}
+	envelope, err := f.CKKS.Seal(value)
+	if err != nil {
+		return "", err
+	}
+	// Public-key ciphertexts cannot be seeded, gzip still saves about a quarter of the ledger space
+	if err = envelope.Compress(); err != nil {
+		return "", err
+	}
+	return EncodeEnvelope(envelope, EnvelopeBinary)
+}
+
+// EncryptText encrypts a non-numeric field into a hybrid envelope, bound to the name of the field
+func (f *FieldEncryptor) EncryptText(name, text string) (string, error) {
+	if f.Hybrid == nil {
+		return "", fmt.Errorf("%w: %s", ErrMissingKey, SchemeECIES)
+	}
+	payload, err := HybridEncrypt(f.Hybrid, []byte(text), []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return EncodeEnvelope(&Envelope{
+		Version:  EnvelopeVersion,
+		Scheme:   SchemeECIES,
+		KeyID:    HybridKeyID(f.Hybrid),
+		Payload:  payload,
+		Checksum: checksum(payload),
+	}, EnvelopeBinary)
+}
+
+// FieldDecryptor decrypts the fields of a document, whichever scheme each of them was encrypted with
+type FieldDecryptor struct {
+	CKKS   *CKKSKeyOwner
+	Hybrid *ecdh.PrivateKey
+}
+
+// DecryptField decrypts a field encrypted by FieldEncryptor, dispatching on the scheme of its envelope
+// Numbers are formatted back as text. Bare ciphertexts stored before envelopes existed are CKKS ciphertexts.
+func (f *FieldDecryptor) DecryptField(name, encoded string) (string, error) {
+	envelope, err := DecodeEnvelope(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	switch envelope.Scheme {
+	case SchemeCKKS:
+		if f.CKKS == nil {
+			return "", fmt.Errorf("%w: %s", ErrMissingKey, SchemeCKKS)
+		}
+		values, err := f.CKKS.Values(envelope)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(values[0], 'f', 2, 64), nil
+	case SchemeECIES:
+		if f.Hybrid == nil {
+			return "", fmt.Errorf("%w: %s", ErrMissingKey, SchemeECIES)
+		}
+		if envelope.Checksum != checksum(envelope.Payload) {
+			return "", ErrChecksumMismatch
+		}
+		if keyID := HybridKeyID(f.Hybrid.PublicKey()); envelope.KeyID != keyID {
+			return "", fmt.Errorf("%w: expected %s, got %s", ErrKeyMismatch, keyID, envelope.KeyID)
+		}
+		text, err := HybridDecrypt(f.Hybrid, envelope.Payload, []byte(name))
+		if err != nil {
+			return "", err
+		}
+		return string(text), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedScheme, envelope.Scheme)
+	}
+}
@@ -0,0 +1,111 @@
+package encryption
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// TestHybridEncryption ensures that a hybrid ciphertext is only decrypted by the recipient and under its field name
+func TestHybridEncryption(t *testing.T) {
+	recipient, err := GenHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("IR820540102680020817909002")
+	data, err := HybridEncrypt(recipient.PublicKey(), message, []byte("AccountNumber"))
+	if err != nil {
+		t.Fatalf("HybridEncrypt failed: %v", err)
+	}
+
+	decrypted, err := HybridDecrypt(recipient, data, []byte("AccountNumber"))
+	if err != nil {
+		t.Fatalf("HybridDecrypt failed: %v", err)
+	}
+	if string(decrypted) != string(message) {
+		t.Errorf("HybridDecrypt got %q, expected %q", decrypted, message)
+	}
+
+	if _, err = HybridDecrypt(other, data, []byte("AccountNumber")); !errors.Is(err, ErrHybridDecryption) {
+		t.Errorf("HybridDecrypt with another key returned %v, expected %v", err, ErrHybridDecryption)
+	}
+	if _, err = HybridDecrypt(recipient, data, []byte("Employer")); !errors.Is(err, ErrHybridDecryption) {
+		t.Errorf("HybridDecrypt under another field name returned %v, expected %v", err, ErrHybridDecryption)
+	}
+	if _, err = HybridDecrypt(recipient, data[:70], []byte("AccountNumber")); !errors.Is(err, ErrHybridDecryption) {
+		t.Errorf("HybridDecrypt of a truncated ciphertext returned %v, expected %v", err, ErrHybridDecryption)
+	}
+}
+
+// TestHybridKeyEncoding ensures that published and stored hybrid keys are read back
+func TestHybridKeyEncoding(t *testing.T) {
+	key, err := GenHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := EncodeHybridKey(key.PublicKey())
+	if err != nil {
+		t.Fatalf("EncodeHybridKey failed: %v", err)
+	}
+	pub, err := DecodeHybridKey(encoded)
+	if err != nil {
+		t.Fatalf("DecodeHybridKey failed: %v", err)
+	}
+	if !pub.Equal(key.PublicKey()) {
+		t.Error("decoded public key differs from the encoded one")
+	}
+
+	path := filepath.Join(t.TempDir(), "hybrid.key")
+	if err = WriteHybridKey(path, key); err != nil {
+		t.Fatalf("WriteHybridKey failed: %v", err)
+	}
+	loaded, err := ReadHybridKey(path)
+	if err != nil {
+		t.Fatalf("ReadHybridKey failed: %v", err)
+	}
+	if !loaded.Equal(key) {
+		t.Error("loaded private key differs from the stored one")
+	}
+}
+
+// TestDocumentFields ensures that numeric and text fields are each decrypted with the scheme they were encrypted with
+func TestDocumentFields(t *testing.T) {
+	helper := NewCKKSHelper()
+	hybridKey, err := GenHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptor := FieldEncryptor{CKKS: helper.CKKSEncryptor, Hybrid: hybridKey.PublicKey()}
+	decryptor := FieldDecryptor{CKKS: helper.CKKSKeyOwner, Hybrid: hybridKey}
+
+	salary, err := encryptor.EncryptNumber(52000000)
+	if err != nil {
+		t.Fatalf("EncryptNumber failed: %v", err)
+	}
+	employer, err := encryptor.EncryptText("Employer", "Acme Trading Co.")
+	if err != nil {
+		t.Fatalf("EncryptText failed: %v", err)
+	}
+
+	if value, err := decryptor.DecryptField("Salary", salary); err != nil || value != "52000000.00" {
+		t.Errorf("DecryptField of a number returned %q, %v", value, err)
+	}
+	if value, err := decryptor.DecryptField("Employer", employer); err != nil || value != "Acme Trading Co." {
+		t.Errorf("DecryptField of a text returned %q, %v", value, err)
+	}
+
+	// A text moved to another field does not decrypt
+	if _, err = decryptor.DecryptField("Notes", employer); !errors.Is(err, ErrHybridDecryption) {
+		t.Errorf("DecryptField under another name returned %v, expected %v", err, ErrHybridDecryption)
+	}
+	// Each scheme needs its own key
+	if _, err = (&FieldDecryptor{CKKS: helper.CKKSKeyOwner}).DecryptField("Employer", employer); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("DecryptField without the hybrid key returned %v, expected %v", err, ErrMissingKey)
+	}
+}
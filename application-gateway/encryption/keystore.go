@@ -0,0 +1,452 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeystoreVersion is the version of the key entry format written by FileKeystore
+const KeystoreVersion = 1
+
+// DefaultScryptLogN is the base 2 logarithm of the scrypt cost used to derive the key encryption keys, which takes
+// about 100ms per key
+const DefaultScryptLogN = 15
+
+// maxScryptMemory bounds the memory the key derivation of an entry may take, 128 * 2^LogN * R bytes, so that a
+// crafted entry cannot make opening it arbitrarily expensive
+const maxScryptMemory = 1 << 30
+
+// maxScryptR and maxScryptP bound the block size and the parallelization of the key derivation of an entry
+const (
+	maxScryptR = 32
+	maxScryptP = 16
+)
+
+// keystoreExtension is the extension of the key entry files in a keystore directory
+const keystoreExtension = ".json"
+
+var (
+	ErrKeyNotFound      = errors.New("key not found in the keystore")
+	ErrKeyExists        = errors.New("key already exists in the keystore")
+	ErrWrongPassword    = errors.New("wrong keystore password or corrupted key")
+	ErrKeyTypeMismatch  = errors.New("key of a different type")
+	ErrEmptyPassword    = errors.New("keystore password must not be empty")
+	ErrInvalidKeyID     = errors.New("invalid key ID")
+	ErrKeystoreVersion  = errors.New("unsupported keystore version")
+	ErrUnsupportedKDF   = errors.New("unsupported key derivation function")
+	ErrNoKeyMaterial    = errors.New("key material must not be empty")
+	ErrInvalidKeyParams = errors.New("invalid key derivation parameters")
+)
+
+// KeyInfo describes a key of a keystore without its private material
+// The keys of a label, such as "signing", form its rotation history: the latest one is active and the previous ones
+// are kept retired, so that the documents they signed or encrypted can still be checked or decrypted.
+type KeyInfo struct {
+	ID        string     `json:"ID"`
+	Label     string     `json:"Label"`
+	Type      string     `json:"Type"`
+	PublicKey string     `json:"PublicKey,omitempty"`
+	Created   time.Time  `json:"Created"`
+	Retired   *time.Time `json:"Retired,omitempty"`
+}
+
+// Active reports whether the key is the current key of its label
+func (k KeyInfo) Active() bool {
+	return k.Retired == nil
+}
+
+// Keystore keeps private keys at rest under key IDs
+type Keystore interface {
+	// List returns the keys of the store, oldest first
+	List() ([]KeyInfo, error)
+
+	// Add stores the private material of a key under a new ID, as the active key of its label, and retires the
+	// previous active key of the label
+	Add(label string, keyType string, publicKey string, material []byte) (KeyInfo, error)
+
+	// Get returns a key with its private material
+	Get(id string) (KeyInfo, []byte, error)
+
+	// Export returns a key in a portable form, still protected by the password of the store
+	Export(id string) ([]byte, error)
+
+	// Import adds a key exported from a store protected by password, as the active key of its label unless it was
+	// retired
+	Import(exported []byte, password []byte) (KeyInfo, error)
+
+	// Delete removes a key for good
+	Delete(id string) error
+}
+
+// ActiveKey returns the active key of a label
+// Should an interrupted rotation leave several active keys, the newest one wins.
+func ActiveKey(ks Keystore, label string) (KeyInfo, error) {
+	keys, err := ks.List()
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i].Label == label && keys[i].Active() {
+			return keys[i], nil
+		}
+	}
+	return KeyInfo{}, fmt.Errorf("%w: no active %s key", ErrKeyNotFound, label)
+}
+
+// KeystoreOptions configures a FileKeystore
+// The zero value selects DefaultScryptLogN.
+type KeystoreOptions struct {
+	// ScryptLogN is the base 2 logarithm of the scrypt cost of the keys added to the store
+	ScryptLogN int
+}
+
+// scryptParams are the parameters the key encryption key of an entry was derived with
+type scryptParams struct {
+	Name string `json:"Name"`
+	Salt []byte `json:"Salt"`
+	LogN int    `json:"LogN"`
+	R    int    `json:"R"`
+	P    int    `json:"P"`
+}
+
+// keyEntry is the on-disk representation of a key: its description in the clear and its material encrypted with
+// AES-256-GCM under a key derived from the password, the description being authenticated along with it
+type keyEntry struct {
+	Version    int          `json:"Version"`
+	Info       KeyInfo      `json:"Info"`
+	KDF        scryptParams `json:"KDF"`
+	Nonce      []byte       `json:"Nonce"`
+	Ciphertext []byte       `json:"Ciphertext"`
+}
+
+// FileKeystore is a Keystore keeping one encrypted file per key in a directory
+type FileKeystore struct {
+	dir      string
+	password []byte
+	logN     int
+}
+
+// OpenFileKeystore opens the keystore kept in dir, creating the directory if needed
+func OpenFileKeystore(dir string, password []byte) (*FileKeystore, error) {
+	return OpenFileKeystoreWithOptions(dir, password, KeystoreOptions{})
+}
+
+// OpenFileKeystoreWithOptions opens the keystore kept in dir with custom options
+// The password is checked against the newest key of the store, if any.
+func OpenFileKeystoreWithOptions(dir string, password []byte, opts KeystoreOptions) (*FileKeystore, error) {
+	if len(password) == 0 {
+		return nil, ErrEmptyPassword
+	}
+	if opts.ScryptLogN == 0 {
+		opts.ScryptLogN = DefaultScryptLogN
+	}
+	if err := checkScryptParams(opts.ScryptLogN, 8, 1); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	ks := &FileKeystore{dir: dir, password: append([]byte{}, password...), logN: opts.ScryptLogN}
+	keys, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		if _, _, err := ks.Get(keys[len(keys)-1].ID); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// List returns the keys of the store, oldest first
+func (ks *FileKeystore) List() ([]KeyInfo, error) {
+	files, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]KeyInfo, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), keystoreExtension) {
+			continue
+		}
+		entry, err := ks.readEntry(strings.TrimSuffix(file.Name(), keystoreExtension))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, entry.Info)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	return keys, nil
+}
+
+// Add stores the private material of a key under a new ID, as the active key of its label
+func (ks *FileKeystore) Add(label string, keyType string, publicKey string, material []byte) (KeyInfo, error) {
+	if len(material) == 0 {
+		return KeyInfo{}, ErrNoKeyMaterial
+	}
+	id, err := newKeyID()
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	info := KeyInfo{
+		ID:        id,
+		Label:     label,
+		Type:      keyType,
+		PublicKey: publicKey,
+		Created:   time.Now().UTC(),
+	}
+	entry, err := ks.seal(info, material)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	return info, ks.activate(entry)
+}
+
+// Get returns a key with its private material
+func (ks *FileKeystore) Get(id string) (KeyInfo, []byte, error) {
+	entry, err := ks.readEntry(id)
+	if err != nil {
+		return KeyInfo{}, nil, err
+	}
+	material, err := entry.open(ks.password)
+	if err != nil {
+		return KeyInfo{}, nil, err
+	}
+	return entry.Info, material, nil
+}
+
+// Export returns the encrypted entry of a key, which another store opens with the password of this one
+func (ks *FileKeystore) Export(id string) ([]byte, error) {
+	entry, err := ks.readEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(entry)
+}
+
+// Import adds a key exported from a store protected by password, re-encrypting it under the password of this store
+func (ks *FileKeystore) Import(exported []byte, password []byte) (KeyInfo, error) {
+	var entry keyEntry
+	if err := json.Unmarshal(exported, &entry); err != nil {
+		return KeyInfo{}, fmt.Errorf("exported key is malformed: %w", err)
+	}
+	if err := entry.check(); err != nil {
+		return KeyInfo{}, err
+	}
+	if _, err := os.Stat(ks.path(entry.Info.ID)); err == nil {
+		return KeyInfo{}, fmt.Errorf("%w: %s", ErrKeyExists, entry.Info.ID)
+	}
+
+	material, err := entry.open(password)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	sealed, err := ks.seal(entry.Info, material)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	if !sealed.Info.Active() {
+		return sealed.Info, ks.writeEntry(sealed)
+	}
+	return sealed.Info, ks.activate(sealed)
+}
+
+// Delete removes a key for good
+func (ks *FileKeystore) Delete(id string) error {
+	if _, err := ks.readEntry(id); err != nil {
+		return err
+	}
+	return os.Remove(ks.path(id))
+}
+
+// activate writes a new active entry and retires the previous active keys of its label
+// The new entry is written first, so that a failure never leaves the label without an active key.
+func (ks *FileKeystore) activate(entry *keyEntry) error {
+	keys, err := ks.List()
+	if err != nil {
+		return err
+	}
+	if err := ks.writeEntry(entry); err != nil {
+		return err
+	}
+
+	retired := time.Now().UTC()
+	for _, key := range keys {
+		if key.Label != entry.Info.Label || !key.Active() {
+			continue
+		}
+		previous, err := ks.readEntry(key.ID)
+		if err != nil {
+			return err
+		}
+		previous.Info.Retired = &retired
+		if err := ks.writeEntry(previous); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seal encrypts key material under a key derived from the password of the store
+func (ks *FileKeystore) seal(info KeyInfo, material []byte) (*keyEntry, error) {
+	entry := &keyEntry{
+		Version: KeystoreVersion,
+		Info:    info,
+		KDF:     scryptParams{Name: "scrypt", Salt: make([]byte, 32), LogN: ks.logN, R: 8, P: 1},
+		Nonce:   make([]byte, 12),
+	}
+	if _, err := rand.Read(entry.KDF.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(entry.Nonce); err != nil {
+		return nil, err
+	}
+
+	aead, err := entry.aead(ks.password)
+	if err != nil {
+		return nil, err
+	}
+	entry.Ciphertext = aead.Seal(nil, entry.Nonce, material, entry.additionalData())
+	return entry, nil
+}
+
+// open decrypts the key material of an entry
+func (e *keyEntry) open(password []byte) ([]byte, error) {
+	aead, err := e.aead(password)
+	if err != nil {
+		return nil, err
+	}
+	material, err := aead.Open(nil, e.Nonce, e.Ciphertext, e.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %s", ErrWrongPassword, e.Info.ID)
+	}
+	return material, nil
+}
+
+// aead derives the key encryption key of an entry from the password
+func (e *keyEntry) aead(password []byte) (cipher.AEAD, error) {
+	if e.KDF.Name != "scrypt" {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKDF, e.KDF.Name)
+	}
+	if err := checkScryptParams(e.KDF.LogN, e.KDF.R, e.KDF.P); err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(password, e.KDF.Salt, 1<<e.KDF.LogN, e.KDF.R, e.KDF.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyParams, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkScryptParams refuses key derivation parameters outside the bounds of the keystore
+func checkScryptParams(logN int, r int, p int) error {
+	if r < 1 || r > maxScryptR || p < 1 || p > maxScryptP {
+		return fmt.Errorf("%w: scrypt r=%d p=%d", ErrInvalidKeyParams, r, p)
+	}
+	if logN < 1 || logN > 30 || 128*r<<logN > maxScryptMemory {
+		return fmt.Errorf("%w: scrypt cost 2^%d with r=%d", ErrInvalidKeyParams, logN, r)
+	}
+	return nil
+}
+
+// additionalData binds the ciphertext of an entry to the ID, label and type of its key, which may not be swapped
+// with those of another entry. The retirement is left out, since retiring a key must not need the password.
+func (e *keyEntry) additionalData() []byte {
+	data, _ := json.Marshal([]any{e.Version, e.Info.ID, e.Info.Label, e.Info.Type, e.Info.PublicKey})
+	return data
+}
+
+// check validates the version and the ID of an entry
+func (e *keyEntry) check() error {
+	if e.Version != KeystoreVersion {
+		return fmt.Errorf("%w: %d", ErrKeystoreVersion, e.Version)
+	}
+	return checkKeyID(e.Info.ID)
+}
+
+// readEntry reads the entry of a key
+func (ks *FileKeystore) readEntry(id string) (*keyEntry, error) {
+	if err := checkKeyID(id); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(ks.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entry keyEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("key %s is malformed: %w", id, err)
+	}
+	if err := entry.check(); err != nil {
+		return nil, err
+	}
+	if entry.Info.ID != id {
+		return nil, fmt.Errorf("%w: file of %s holds %s", ErrInvalidKeyID, id, entry.Info.ID)
+	}
+	return &entry, nil
+}
+
+// writeEntry writes the entry of a key, readable by its owner only
+// The entry is written to a temporary file first, so that a failure never leaves a truncated key behind.
+func (ks *FileKeystore) writeEntry(entry *keyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp := ks.path(entry.Info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.path(entry.Info.ID))
+}
+
+// path returns the file of a key entry
+func (ks *FileKeystore) path(id string) string {
+	return filepath.Join(ks.dir, id+keystoreExtension)
+}
+
+// newKeyID returns a random key ID
+func newKeyID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// checkKeyID refuses IDs that are not lower-case hexadecimal, which could escape the keystore directory
+func checkKeyID(id string) error {
+	if id == "" {
+		return ErrInvalidKeyID
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return fmt.Errorf("%w: %q", ErrInvalidKeyID, id)
+		}
+	}
+	return nil
+}
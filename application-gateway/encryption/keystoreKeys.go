@@ -0,0 +1,244 @@
+package encryption
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Types of the keys kept in a keystore
+const (
+	SigningKeyType = "ecdsa-p256"
+	HybridKeyType  = "ecdh-p256"
+	CKKSKeyType    = "ckks-keys"
+)
+
+// ckksKeySet is the key material of a CKKS entry: the keys of a helper, each serialized by marshalKey so that it
+// carries the fingerprint of its parameters
+type ckksKeySet struct {
+	SecretKey          []byte `json:"SecretKey"`
+	PublicKey          []byte `json:"PublicKey"`
+	RelinearizationKey []byte `json:"RelinearizationKey"`
+}
+
+// AddSigningKey stores an ECDSA private key as the active key of a label
+func AddSigningKey(ks Keystore, label string, key *ecdsa.PrivateKey) (KeyInfo, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	publicKey, err := EncodeSigningKey(&key.PublicKey)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	return ks.Add(label, SigningKeyType, publicKey, der)
+}
+
+// RotateSigningKey generates a new ECDSA key as the active key of a label, retiring the previous one
+func RotateSigningKey(ks Keystore, label string) (*ecdsa.PrivateKey, KeyInfo, error) {
+	key, err := GenKey()
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	info, err := AddSigningKey(ks, label, key)
+	return key, info, err
+}
+
+// ReadSigningKeyFrom reads the ECDSA private key with the given ID from a keystore
+func ReadSigningKeyFrom(ks Keystore, id string) (*ecdsa.PrivateKey, error) {
+	material, err := keyMaterial(ks, id, SigningKeyType)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseECPrivateKey(material)
+}
+
+// ActiveSigningKey reads the active ECDSA private key of a label
+func ActiveSigningKey(ks Keystore, label string) (*ecdsa.PrivateKey, KeyInfo, error) {
+	info, err := ActiveKey(ks, label)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	key, err := ReadSigningKeyFrom(ks, info.ID)
+	return key, info, err
+}
+
+// ImportSigningKeyFile stores an ECDSA private key written by WriteSigningKey as the active key of a label, so that a
+// key kept in the clear can be moved into a keystore
+func ImportSigningKeyFile(ks Keystore, label string, path string) (*ecdsa.PrivateKey, KeyInfo, error) {
+	key, err := ReadSigningKey(path)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	info, err := AddSigningKey(ks, label, key)
+	return key, info, err
+}
+
+// AddHybridKey stores a hybrid private key as the active key of a label
+func AddHybridKey(ks Keystore, label string, key *ecdh.PrivateKey) (KeyInfo, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	publicKey, err := EncodeHybridKey(key.PublicKey())
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	return ks.Add(label, HybridKeyType, publicKey, der)
+}
+
+// RotateHybridKey generates a new hybrid key as the active key of a label, retiring the previous one
+// The retired key stays in the keystore to decrypt the fields encrypted to it before the rotation.
+func RotateHybridKey(ks Keystore, label string) (*ecdh.PrivateKey, KeyInfo, error) {
+	key, err := GenHybridKey()
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	info, err := AddHybridKey(ks, label, key)
+	return key, info, err
+}
+
+// ReadHybridKeyFrom reads the hybrid private key with the given ID from a keystore
+func ReadHybridKeyFrom(ks Keystore, id string) (*ecdh.PrivateKey, error) {
+	material, err := keyMaterial(ks, id, HybridKeyType)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(material)
+	if err != nil {
+		return nil, err
+	}
+	// PKCS #8 parses P-256 keys as ECDSA keys, which convert to ECDH keys
+	switch key := key.(type) {
+	case *ecdh.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key.ECDH()
+	default:
+		return nil, fmt.Errorf("%w: key %s is not a P-256 key", ErrKeyTypeMismatch, id)
+	}
+}
+
+// ActiveHybridKey reads the active hybrid private key of a label
+func ActiveHybridKey(ks Keystore, label string) (*ecdh.PrivateKey, KeyInfo, error) {
+	info, err := ActiveKey(ks, label)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	key, err := ReadHybridKeyFrom(ks, info.ID)
+	return key, info, err
+}
+
+// ImportHybridKeyFile stores a hybrid private key written by WriteHybridKey as the active key of a label
+func ImportHybridKeyFile(ks Keystore, label string, path string) (*ecdh.PrivateKey, KeyInfo, error) {
+	key, err := ReadHybridKey(path)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	info, err := AddHybridKey(ks, label, key)
+	return key, info, err
+}
+
+// AddCKKSKeys stores the secret, public and relinearization keys of a helper as the active key of a label
+// The public key is too large to be listed, so the entry only records the fingerprint of the parameters.
+func AddCKKSKeys(ks Keystore, label string, helper *CKKSHelper) (KeyInfo, error) {
+	var set ckksKeySet
+	var err error
+	if set.SecretKey, err = marshalKey(SecretKeyKind, helper.Params, helper.secretKey); err != nil {
+		return KeyInfo{}, err
+	}
+	if set.PublicKey, err = marshalKey(PublicKeyKind, helper.Params, helper.publicKey); err != nil {
+		return KeyInfo{}, err
+	}
+	if set.RelinearizationKey, err = marshalKey(RelinearizationKeyKind, helper.Params, helper.Relinearizer); err != nil {
+		return KeyInfo{}, err
+	}
+	material, err := json.Marshal(set)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+
+	fingerprint, err := ParamsFingerprint(helper.Params)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	return ks.Add(label, CKKSKeyType, fingerprint, material)
+}
+
+// RotateCKKSKeys generates a new CKKS key set as the active key of a label, retiring the previous one
+// The retired keys stay in the keystore to decrypt the values encrypted to them before the rotation.
+func RotateCKKSKeys(ks Keystore, label string, opts HelperOptions) (*CKKSHelper, KeyInfo, error) {
+	helper, err := NewCKKSHelperWithOptions(opts)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	info, err := AddCKKSKeys(ks, label, helper)
+	return helper, info, err
+}
+
+// ImportCKKSKeyDir stores the keys written by ExportKeys into dir as the active key of a label
+func ImportCKKSKeyDir(ks Keystore, label string, dir string, opts HelperOptions) (*CKKSHelper, KeyInfo, error) {
+	helper, err := LoadCKKSHelper(dir, opts)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	info, err := AddCKKSKeys(ks, label, helper)
+	return helper, info, err
+}
+
+// LoadKeystoreCKKSHelper initializes a CKKSHelper from the CKKS key set with the given ID
+// opts must select the parameters the keys were generated under
+func LoadKeystoreCKKSHelper(ks Keystore, id string, opts HelperOptions) (*CKKSHelper, error) {
+	params, policy, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+	material, err := keyMaterial(ks, id, CKKSKeyType)
+	if err != nil {
+		return nil, err
+	}
+	var set ckksKeySet
+	if err := json.Unmarshal(material, &set); err != nil {
+		return nil, fmt.Errorf("CKKS key set %s is malformed: %w", id, err)
+	}
+
+	sk := rlwe.NewSecretKey(params.Parameters)
+	if err := unmarshalKey(set.SecretKey, SecretKeyKind, params, sk); err != nil {
+		return nil, err
+	}
+	pk := rlwe.NewPublicKey(params.Parameters)
+	if err := unmarshalKey(set.PublicKey, PublicKeyKind, params, pk); err != nil {
+		return nil, err
+	}
+	rlk := new(rlwe.RelinearizationKey)
+	if err := unmarshalKey(set.RelinearizationKey, RelinearizationKeyKind, params, rlk); err != nil {
+		return nil, err
+	}
+	return newCKKSHelper(params, policy, sk, pk, rlk)
+}
+
+// ActiveCKKSHelper initializes a CKKSHelper from the active CKKS key set of a label
+func ActiveCKKSHelper(ks Keystore, label string, opts HelperOptions) (*CKKSHelper, KeyInfo, error) {
+	info, err := ActiveKey(ks, label)
+	if err != nil {
+		return nil, KeyInfo{}, err
+	}
+	helper, err := LoadKeystoreCKKSHelper(ks, info.ID, opts)
+	return helper, info, err
+}
+
+// keyMaterial reads the private material of a key, refusing keys of another type
+func keyMaterial(ks Keystore, id string, keyType string) ([]byte, error) {
+	info, material, err := ks.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Type != keyType {
+		return nil, fmt.Errorf("%w: key %s is a %s key, expected %s", ErrKeyTypeMismatch, id, info.Type, keyType)
+	}
+	return material, nil
+}
@@ -0,0 +1,256 @@
+package encryption
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testKeystoreOptions keeps the key derivation cheap in tests
+var testKeystoreOptions = KeystoreOptions{ScryptLogN: 10}
+
+// TestFileKeystore ensures that keys survive reopening the store, that rotations keep the retired keys readable and
+// that the password is checked
+func TestFileKeystore(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := OpenFileKeystoreWithOptions(dir, []byte("correct horse"), testKeystoreOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ActiveSigningKey(ks, "signing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("active key of an empty store returned %v, expected %v", err, ErrKeyNotFound)
+	}
+	first, firstInfo, err := RotateSigningKey(ks, "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hybrid, _, err := RotateHybridKey(ks, "hybrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, secondInfo, err := RotateSigningKey(ks, "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen the store, as an application does on its next run
+	if ks, err = OpenFileKeystoreWithOptions(dir, []byte("correct horse"), testKeystoreOptions); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || keys[0].ID != firstInfo.ID || keys[0].Active() || !keys[2].Active() {
+		t.Errorf("List returned %+v, expected the first signing key retired and the second one active", keys)
+	}
+
+	active, info, err := ActiveSigningKey(ks, "signing")
+	if err != nil || info.ID != secondInfo.ID || !active.Equal(second) {
+		t.Errorf("active signing key is %s (%v), expected %s", info.ID, err, secondInfo.ID)
+	}
+	if retired, err := ReadSigningKeyFrom(ks, firstInfo.ID); err != nil || !retired.Equal(first) {
+		t.Errorf("retired signing key could not be read back: %v", err)
+	}
+	if activeHybrid, _, err := ActiveHybridKey(ks, "hybrid"); err != nil || !activeHybrid.Equal(hybrid) {
+		t.Errorf("hybrid key could not be read back: %v", err)
+	}
+	if encoded, _ := EncodeSigningKey(&second.PublicKey); info.PublicKey != encoded {
+		t.Errorf("listed public key %s does not match the signing key", info.PublicKey)
+	}
+
+	if _, err := ReadHybridKeyFrom(ks, firstInfo.ID); !errors.Is(err, ErrKeyTypeMismatch) {
+		t.Errorf("reading a signing key as a hybrid key returned %v, expected %v", err, ErrKeyTypeMismatch)
+	}
+	if _, _, err := ks.Get("../secret"); !errors.Is(err, ErrInvalidKeyID) {
+		t.Errorf("reading a key outside the store returned %v, expected %v", err, ErrInvalidKeyID)
+	}
+	if _, err := OpenFileKeystoreWithOptions(dir, []byte("wrong horse"), testKeystoreOptions); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("opening with a wrong password returned %v, expected %v", err, ErrWrongPassword)
+	}
+
+	if err := ks.Delete(firstInfo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSigningKeyFrom(ks, firstInfo.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("reading a deleted key returned %v, expected %v", err, ErrKeyNotFound)
+	}
+}
+
+// TestActiveKeyInterruptedRotation ensures that the newest key wins when a rotation stopped before retiring the
+// previous one
+func TestActiveKeyInterruptedRotation(t *testing.T) {
+	ks, err := OpenFileKeystoreWithOptions(t.TempDir(), []byte("rotation"), testKeystoreOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, first, err := RotateSigningKey(ks, "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := RotateSigningKey(ks, "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Undo the retirement of the first key, as if the rotation had stopped right after writing the second one
+	entry, err := ks.readEntry(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Info.Retired = nil
+	if err := ks.writeEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if active, err := ActiveKey(ks, "signing"); err != nil || active.ID != second.ID {
+		t.Errorf("ActiveKey returned %s (%v), expected the newest key %s", active.ID, err, second.ID)
+	}
+}
+
+// TestKeystoreExportImport ensures that an exported key moves to a store protected by another password
+func TestKeystoreExportImport(t *testing.T) {
+	source, err := OpenFileKeystoreWithOptions(t.TempDir(), []byte("source"), testKeystoreOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination, err := OpenFileKeystoreWithOptions(t.TempDir(), []byte("destination"), testKeystoreOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, info, err := RotateSigningKey(source, "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, previous, err := RotateSigningKey(destination, "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := source.Export(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A crafted entry may not make the key derivation arbitrarily expensive
+	var crafted keyEntry
+	if err := json.Unmarshal(exported, &crafted); err != nil {
+		t.Fatal(err)
+	}
+	crafted.KDF.R, crafted.KDF.P = 1<<20, 1<<20
+	craftedJSON, _ := json.Marshal(crafted)
+	if _, err := destination.Import(craftedJSON, []byte("source")); !errors.Is(err, ErrInvalidKeyParams) {
+		t.Errorf("importing a key with an unbounded scrypt cost returned %v, expected %v", err, ErrInvalidKeyParams)
+	}
+
+	if _, err := destination.Import(exported, []byte("destination")); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("importing with the wrong password returned %v, expected %v", err, ErrWrongPassword)
+	}
+	if _, err := destination.Import(exported, []byte("source")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := destination.Import(exported, []byte("source")); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("importing a key twice returned %v, expected %v", err, ErrKeyExists)
+	}
+
+	// The imported key is the active one and is now protected by the password of the destination
+	imported, active, err := ActiveSigningKey(destination, "signing")
+	if err != nil || active.ID != info.ID || !imported.Equal(key) {
+		t.Errorf("active key after the import is %s (%v), expected %s", active.ID, err, info.ID)
+	}
+	keys, err := destination.List()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("List returned %+v, %v, expected 2 keys", keys, err)
+	}
+	for _, key := range keys {
+		if key.ID == previous.ID && key.Active() {
+			t.Errorf("the previous key of the destination was not retired: %+v", key)
+		}
+	}
+}
+
+// TestKeystoreCKKSKeys ensures that a CKKS key set loaded from a keystore decrypts what the original helper encrypted
+func TestKeystoreCKKSKeys(t *testing.T) {
+	ks, err := OpenFileKeystoreWithOptions(t.TempDir(), []byte("persona"), testKeystoreOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	helper, _, err := RotateCKKSKeys(ks, "ckks", HelperOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, info, err := ActiveCKKSHelper(ks, "ckks", HelperOptions{})
+	if err != nil {
+		t.Fatalf("ActiveCKKSHelper failed: %v", err)
+	}
+	if fingerprint, _ := ParamsFingerprint(helper.Params); info.PublicKey != fingerprint {
+		t.Errorf("CKKS entry records %s, expected the fingerprint %s", info.PublicKey, fingerprint)
+	}
+
+	ciphertext, err := helper.EncryptVectorPu([]float64{3.5, -1.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := loaded.Multiply(ciphertext, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := loaded.DecryptVector(product, 2)
+	if math.Abs(values[0]-12.25) > 1e-3 || math.Abs(values[1]-1.5625) > 1e-3 {
+		t.Errorf("loaded helper decrypted %v, expected [12.25 1.5625]", values)
+	}
+
+	if _, err := LoadKeystoreCKKSHelper(ks, info.ID, HelperOptions{ParameterSet: "PN15QP880"}); !errors.Is(err, ErrParamsMismatch) {
+		t.Errorf("loading under other parameters returned %v, expected %v", err, ErrParamsMismatch)
+	}
+}
+
+// TestKeystoreImportFiles ensures that keys kept in the clear move into a keystore unchanged
+func TestKeystoreImportFiles(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := OpenFileKeystoreWithOptions(dir, []byte("organization"), testKeystoreOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingKey, err := GenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hybridKey, err := GenHybridKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSigningKey(filepath.Join(dir, "signing.key"), signingKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteHybridKey(filepath.Join(dir, "hybrid.key"), hybridKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ImportSigningKeyFile(ks, "signing", filepath.Join(dir, "signing.key")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ImportHybridKeyFile(ks, "hybrid", filepath.Join(dir, "hybrid.key")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ImportSigningKeyFile(ks, "signing", filepath.Join(dir, "missing.key")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("importing a missing file returned %v, expected %v", err, os.ErrNotExist)
+	}
+
+	// The key files left next to the entries are not listed
+	if keys, err := ks.List(); err != nil || len(keys) != 2 {
+		t.Errorf("List returned %+v, %v, expected the 2 imported keys", keys, err)
+	}
+	if key, _, err := ActiveSigningKey(ks, "signing"); err != nil || !key.Equal(signingKey) {
+		t.Errorf("imported signing key could not be read back: %v", err)
+	}
+	if key, _, err := ActiveHybridKey(ks, "hybrid"); err != nil || !key.Equal(hybridKey) {
+		t.Errorf("imported hybrid key could not be read back: %v", err)
+	}
+}
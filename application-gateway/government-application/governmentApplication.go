@@ -1 +1,46 @@
 package government_application
+
+import (
+	"credit-evaluation/application-gateway/encryption"
+	"errors"
+	"os"
+)
+
+// signingKeyLabel is the label of the key the government signs official documents with in its keystore
+const signingKeyLabel = "signing"
+
+// OpenKeystore opens the keystore of the government, kept in GOVERNMENT_KEY_DIR and protected by
+// GOVERNMENT_KEY_PASSWORD
+func OpenKeystore() (*encryption.FileKeystore, error) {
+	keyDir := "government-keys"
+	if dir := os.Getenv("GOVERNMENT_KEY_DIR"); dir != "" {
+		keyDir = dir
+	}
+	password := os.Getenv("GOVERNMENT_KEY_PASSWORD")
+	if password == "" {
+		return nil, errors.New("set GOVERNMENT_KEY_PASSWORD to the password of the government keystore")
+	}
+	return encryption.OpenFileKeystore(keyDir, []byte(password))
+}
+
+// LoadSigner returns a signer with the active signing key of the government, generating the key on first use
+func LoadSigner(ks encryption.Keystore) (*encryption.Signer, error) {
+	signingKey, _, err := encryption.ActiveSigningKey(ks, signingKeyLabel)
+	if errors.Is(err, encryption.ErrKeyNotFound) {
+		signingKey, _, err = encryption.RotateSigningKey(ks, signingKeyLabel)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewSigner(signingKey), nil
+}
+
+// RotateSigner replaces the signing key of the government by a new one and returns its signer
+// The previous key is kept retired in the keystore to check the documents it signed.
+func RotateSigner(ks encryption.Keystore) (*encryption.Signer, encryption.KeyInfo, error) {
+	signingKey, info, err := encryption.RotateSigningKey(ks, signingKeyLabel)
+	if err != nil {
+		return nil, encryption.KeyInfo{}, err
+	}
+	return encryption.NewSigner(signingKey), info, nil
+}
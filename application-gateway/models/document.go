@@ -2,6 +2,9 @@ package models
 
 import (
 	"credit-evaluation/application-gateway/encryption"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -14,14 +17,45 @@ type Document struct {
 	Time  time.Time         `json:"Time"`
 	Data  map[string]string `json:"Data"`
 
+	Commitments map[string]string `json:"Commitments,omitempty"`
+
 	OrgSignature   string `json:"OrgSignature"`
 	OwnerSignature string `json:"OwnerSignature"`
 }
 
-func (d *Document) Decrypt(privateKey string) (map[string]string, error) {
-	var decryptedData map[string]string
+// Decrypt decrypts every field of the document with the scheme its envelope names
+func (d *Document) Decrypt(decryptor *encryption.FieldDecryptor) (map[string]string, error) {
+	decryptedData := make(map[string]string, len(d.Data))
 	for key, value := range d.Data {
-		decryptedData[key], _ = encryption.Decrypt([]byte(value), privateKey)
+		decrypted, err := decryptor.DecryptField(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt field %s: %w", key, err)
+		}
+		decryptedData[key] = decrypted
 	}
 	return decryptedData, nil
 }
+
+// CheckCommitments checks every field of the document against the commitment the issuer signed for it, so that the
+// owner knows the ciphertexts hold the values the issuer vouched for. Fields without a commitment are rejected.
+// issuerKey returns the signing key of the issuer with the ID a commitment names.
+func (d *Document) CheckCommitments(decryptor *encryption.FieldDecryptor, issuerKey func(keyID string) (*ecdsa.PublicKey, error)) error {
+	for key, value := range d.Data {
+		commitmentJSON, ok := d.Commitments[key]
+		if !ok {
+			return fmt.Errorf("field %s has no commitment", key)
+		}
+		var commitment encryption.FieldCommitment
+		if err := json.Unmarshal([]byte(commitmentJSON), &commitment); err != nil {
+			return fmt.Errorf("commitment of field %s is malformed: %w", key, err)
+		}
+		signingKey, err := issuerKey(commitment.KeyID)
+		if err != nil {
+			return fmt.Errorf("commitment of field %s: %w", key, err)
+		}
+		if err := decryptor.CheckCommitment(&commitment, signingKey, key, value); err != nil {
+			return fmt.Errorf("field %s does not match its commitment: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -11,12 +11,14 @@ import (
 	"context"
 	"credit-evaluation/application-gateway/encryption"
 	"credit-evaluation/chaincode"
+	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
@@ -38,13 +40,21 @@ const (
 	gatewayPeer  = "peer0.org1.example.com"
 )
 
+// signingKeyFile names the file the key the organization signs documents and commitments with was kept in, in the
+// clear, before it moved into the keystore
+const signingKeyFile = "signing.key"
+
+// signingKeyLabel is the label of the signing key in the keystore of the organization
+const signingKeyLabel = "signing"
+
 var now = time.Now()
 var assetId = fmt.Sprintf("asset%d", now.Unix()*1e3+int64(now.Nanosecond())/1e6)
 
 type OrgApplication struct {
-	contract   *client.Contract
-	signer     *encryption.Signer
-	ckksHelper *encryption.CKKSHelper
+	contract    *client.Contract
+	keystore    encryption.Keystore
+	signer      *encryption.Signer
+	ckksOptions encryption.HelperOptions
 }
 
 func NewOrgApplication() (*OrgApplication, error) {
@@ -86,16 +96,79 @@ func NewOrgApplication() (*OrgApplication, error) {
 	network := gw.GetNetwork(channelName)
 	contract := network.GetContract(chaincodeName)
 
-	docSignPrKey, err := encryption.GenKey()
+	ks, keyDir, err := openKeystore()
+	if err != nil {
+		return nil, err
+	}
+	docSignPrKey, err := loadSigningKey(ks, keyDir)
+	if err != nil {
+		return nil, err
+	}
 
-	helper := encryption.NewCKKSHelper()
 	return &OrgApplication{
-		contract:   contract,
-		signer:     encryption.NewSigner(docSignPrKey),
-		ckksHelper: helper,
+		contract:    contract,
+		keystore:    ks,
+		signer:      encryption.NewSigner(docSignPrKey),
+		ckksOptions: encryption.HelperOptions{ParameterSet: os.Getenv("CKKS_PARAMETER_SET")},
 	}, nil
 }
 
+// openKeystore opens the keystore of the organization, kept in ORG_KEY_DIR and protected by ORG_KEY_PASSWORD
+func openKeystore() (*encryption.FileKeystore, string, error) {
+	keyDir := "org-keys"
+	if dir := os.Getenv("ORG_KEY_DIR"); dir != "" {
+		keyDir = dir
+	}
+	password := os.Getenv("ORG_KEY_PASSWORD")
+	if password == "" {
+		return nil, "", errors.New("set ORG_KEY_PASSWORD to the password of the organization keystore")
+	}
+
+	ks, err := encryption.OpenFileKeystore(keyDir, []byte(password))
+	if err != nil {
+		return nil, "", err
+	}
+	return ks, keyDir, nil
+}
+
+// loadSigningKey loads the active signing key of the organization, generating it on first use, so that the key
+// owners check commitments against stays the same across runs
+func loadSigningKey(ks encryption.Keystore, keyDir string) (*ecdsa.PrivateKey, error) {
+	signingKey, _, err := encryption.ActiveSigningKey(ks, signingKeyLabel)
+	if err == nil || !errors.Is(err, encryption.ErrKeyNotFound) {
+		return signingKey, err
+	}
+
+	// Move the key earlier runs kept in the clear into the keystore, so that the published key stays valid
+	legacyFile := path.Join(keyDir, signingKeyFile)
+	signingKey, _, err = encryption.ImportSigningKeyFile(ks, signingKeyLabel, legacyFile)
+	if err == nil {
+		return signingKey, os.Remove(legacyFile)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	signingKey, _, err = encryption.RotateSigningKey(ks, signingKeyLabel)
+	return signingKey, err
+}
+
+// RotateSigningKey replaces the signing key of the organization by a new one, which must be published again for
+// owners to check the documents signed from now on. The previous key is kept retired in the keystore, and in the key
+// history of the ledger for the documents it signed.
+func (app *OrgApplication) RotateSigningKey() (encryption.KeyInfo, error) {
+	signingKey, info, err := encryption.RotateSigningKey(app.keystore, signingKeyLabel)
+	if err != nil {
+		return encryption.KeyInfo{}, err
+	}
+	app.signer = encryption.NewSigner(signingKey)
+	return info, nil
+}
+
+// ListKeys returns the keys of the organization keystore, oldest first
+func (app *OrgApplication) ListKeys() ([]encryption.KeyInfo, error) {
+	return app.keystore.List()
+}
+
 // newGrpcConnection creates a gRPC connection to the Gateway server.
 func newGrpcConnection() *grpc.ClientConn {
 	certificatePEM, err := os.ReadFile(tlsCertPath)
@@ -211,7 +284,11 @@ func (app OrgApplication) CreateDocument(document chaincode.Document) string {
 		return ""
 	}
 
-	currentTime := time.Now().Format(time.RFC3339)
+	commitmentsJSON, err := json.Marshal(document.Commitments)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("failed to marshal document commitments %s", err.Error()))
+		return ""
+	}
 
 	fmt.Println(string(dataJSON))
 
@@ -219,10 +296,13 @@ func (app OrgApplication) CreateDocument(document chaincode.Document) string {
 		document.OrgID,
 		document.OwnerID,
 		document.Title,
-		currentTime,
+		document.Time.UTC().Format(chaincode.DocumentTimeFormat),
 		string(dataJSON),
-		"orgSignature",
-		"ownerSignature",
+		string(commitmentsJSON),
+		document.OrgSignature,
+		document.OwnerSignature,
+		document.OrgKeyID,
+		document.OwnerKeyID,
 	)
 	if err != nil {
 		fmt.Println(fmt.Sprintf("failed to submit transaction: %s", err.Error()))
@@ -258,11 +338,133 @@ func (app OrgApplication) ReadDocumentByOwnerId(userId string) (string, error) {
 	return result, nil
 }
 
+// GetUserPubKey returns the homomorphic public key a user published on the ledger
 func (app OrgApplication) GetUserPubKey(userId string) (string, error) {
-	// todo: implement
-	panic("implement me")
+	user, err := app.readUser(userId)
+	if err != nil {
+		return "", err
+	}
+	if user.PublicKey == "" {
+		return "", fmt.Errorf("user %s has not published a public key", userId)
+	}
+	return user.PublicKey, nil
+}
+
+// readUser gets a user record from the ledger
+func (app OrgApplication) readUser(userId string) (*chaincode.User, error) {
+	evaluateResult, err := app.contract.EvaluateTransaction("ReadUser", userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user %s from blockchain: %w", userId, err)
+	}
+
+	var user chaincode.User
+	if err := json.Unmarshal(evaluateResult, &user); err != nil {
+		return nil, fmt.Errorf("malformed user %s: %w", userId, err)
+	}
+	return &user, nil
 }
 
+// ownerEncryptor returns an encryptor under the public keys of a document owner, so that only the owner can
+// decrypt the uploaded fields and open their commitments. Owners must have published both keys.
+func (app OrgApplication) ownerEncryptor(ownerId string) (*encryption.FieldEncryptor, error) {
+	user, err := app.readUser(ownerId)
+	if err != nil {
+		return nil, err
+	}
+	if user.PublicKey == "" {
+		return nil, fmt.Errorf("user %s has not published a public key", ownerId)
+	}
+
+	encryptor := &encryption.FieldEncryptor{}
+	if encryptor.CKKS, err = encryption.DecodeCKKSEncryptor(user.PublicKey, app.ckksOptions); err != nil {
+		return nil, err
+	}
+	// The hybrid key also carries the openings of the commitments, even for numeric fields
+	if user.EncryptionKey == "" {
+		return nil, fmt.Errorf("user %s has not published an encryption key", ownerId)
+	}
+	if encryptor.Hybrid, err = encryption.DecodeHybridKey(user.EncryptionKey); err != nil {
+		return nil, err
+	}
+	return encryptor, nil
+}
+
+// PublishSigningKey publishes the signing key of the organization in its own record on the ledger, where owners
+// read it to check the commitments of their documents
+func (app OrgApplication) PublishSigningKey(orgId string, name string) error {
+	signingKey, err := encryption.EncodeSigningKey(app.signer.PublicKey())
+	if err != nil {
+		return err
+	}
+
+	if user, err := app.readUser(orgId); err != nil {
+		fmt.Printf("\n--> Submit Transaction: CreateUser, registers the organization %s\n", orgId)
+		if _, err := app.contract.SubmitTransaction("CreateUser", orgId, name, "", ""); err != nil {
+			return fmt.Errorf("failed to submit transaction: %w", err)
+		}
+	} else if user.ClientID == "" {
+		fmt.Printf("\n--> Submit Transaction: ClaimUser, binds the organization %s to this client\n", orgId)
+		if _, err := app.contract.SubmitTransaction("ClaimUser", orgId); err != nil {
+			return fmt.Errorf("failed to submit transaction: %w", err)
+		}
+	}
+
+	fmt.Printf("\n--> Submit Transaction: UpdateSigningKey, publishes the signing key of %s\n", orgId)
+	if _, err := app.contract.SubmitTransaction("UpdateSigningKey", orgId, signingKey); err != nil {
+		return fmt.Errorf("failed to submit transaction: %w", err)
+	}
+
+	fmt.Printf("*** Transaction committed successfully\n")
+	return nil
+}
+
+// HasConsent asks the ledger whether the owner consented to share the results on a resource with the lender,
+// which, with LenderKey and ResourceDigest, lets the application serve as the consent checker of a re-encryption
+func (app OrgApplication) HasConsent(ownerID, lenderID, resourceID string) (bool, error) {
+	evaluateResult, err := app.contract.EvaluateTransaction("HasConsent", ownerID, lenderID, resourceID)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate transaction: %w", err)
+	}
+
+	var granted bool
+	if err := json.Unmarshal(evaluateResult, &granted); err != nil {
+		return false, fmt.Errorf("malformed consent result: %w", err)
+	}
+	return granted, nil
+}
+
+// LenderKey returns the homomorphic public key a lender published on the ledger
+func (app OrgApplication) LenderKey(lenderID string) (string, error) {
+	return app.GetUserPubKey(lenderID)
+}
+
+// ResourceDigest returns the digest of the envelope stored as a resource of an owner, the resource ID naming a
+// field of a document as "<document ID>/<field>"
+func (app OrgApplication) ResourceDigest(ownerID, resourceID string) (string, error) {
+	documentID, field, ok := strings.Cut(resourceID, "/")
+	if !ok {
+		return "", fmt.Errorf("resource %s does not name a document field", resourceID)
+	}
+	evaluateResult, err := app.contract.EvaluateTransaction("ReadDocument", documentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read document %s from blockchain: %w", documentID, err)
+	}
+	var document chaincode.Document
+	if err := json.Unmarshal(evaluateResult, &document); err != nil {
+		return "", fmt.Errorf("malformed document %s: %w", documentID, err)
+	}
+	if document.OwnerID != ownerID {
+		return "", fmt.Errorf("document %s does not belong to %s", documentID, ownerID)
+	}
+	value, ok := document.Data[field]
+	if !ok {
+		return "", fmt.Errorf("document %s has no field %s", documentID, field)
+	}
+	return encryption.EnvelopeDigest(value)
+}
+
+var _ encryption.ConsentChecker = OrgApplication{}
+
 // Submit transaction asynchronously, blocking until the transaction has been sent to the orderer, and allowing
 // this thread to process the chaincode response (e.g. update a UI) without waiting for the commit notification
 func transferAssetAsync(contract *client.Contract) {
@@ -12,7 +12,11 @@ func main() {
 	// todo: run a goroutine to receive docs from people
 
 	reader := bufio.NewReader(os.Stdin)
-	orgApplication, _ := NewOrgApplication()
+	orgApplication, err := NewOrgApplication()
+	if err != nil {
+		fmt.Println("can not start the organization application:", err)
+		os.Exit(1)
+	}
 	localDocuments := make([]chaincode.Document, 0)
 	//signedDocuments := make([]chaincode.Document, 0)
 
@@ -24,7 +28,10 @@ func main() {
 			"\n3. put a document on blockchain" +
 			"\n4. get a document from blockchain" +
 			"\n5. get all documents of a person from blockchain" +
-			"\n6. get all documents from blockchain",
+			"\n6. get all documents from blockchain" +
+			"\n7. publish the organization signing key on blockchain" +
+			"\n8. rotate the organization signing key" +
+			"\n9. list the organization keys",
 		)
 
 		text, _ := reader.ReadString('\n')
@@ -64,6 +71,25 @@ func main() {
 			// TODO: NOT NECESSARY
 			fmt.Println("6")
 
+		case "7": // publish the signing key of the organization
+			if err := PublishSigningKey(orgApplication); err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Println("signing key published successfully.")
+
+		case "8": // rotate the signing key of the organization
+			if err := RotateSigningKey(orgApplication); err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Println("signing key rotated and published successfully.")
+
+		case "9": // list the keys of the organization
+			if err := ListKeys(orgApplication); err != nil {
+				fmt.Println(err)
+			}
+
 		default:
 			fmt.Println("not a valid option!", text)
 		}
@@ -3,8 +3,8 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"credit-evaluation/application-gateway/encryption"
 	"credit-evaluation/chaincode"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -36,22 +36,62 @@ func UploadDocument(application *OrgApplication) (chaincode.Document, error) {
 		OrgID:   tempDocument.OrgID,
 		OwnerID: tempDocument.OwnerID,
 		Title:   tempDocument.Title,
+		Time:    time.Now().UTC(),
 		Data:    make(map[string]string),
+
+		Commitments: make(map[string]string),
+	}
+
+	// Commitments and signature name the signing key, so that they still verify once it is rotated
+	keyID, err := chaincode.SigningKeyID(application.signer.PublicKey())
+	if err != nil {
+		return chaincode.Document{}, err
+	}
+
+	// Encrypt every field under the owner's key, fetched from the ledger
+	encryptor, err := application.ownerEncryptor(document.OwnerID)
+	if err != nil {
+		fmt.Println("can not get the public key of the owner.", err)
+		return chaincode.Document{}, err
 	}
 
 	fmt.Println(string(data))
 	tempDocData := tempDocument.Data
 	for key, value := range tempDocData {
-		cipherText := *application.ckksHelper.EncryptPu(value)
-		serializedCiphertext, err := cipherText.MarshalBinary()
+		// Numbers are encrypted homomorphically to be evaluated, any other value as text
+		var opening *encryption.Opening
+		switch value := value.(type) {
+		case float64:
+			if document.Data[key], err = encryptor.EncryptNumber(value); err == nil {
+				opening, err = encryption.NumberOpening(value)
+			}
+		case string:
+			if document.Data[key], err = encryptor.EncryptText(key, value); err == nil {
+				opening, err = encryption.NewOpening(value)
+			}
+		default:
+			err = fmt.Errorf("field %s must be a number or a string", key)
+		}
+		if err != nil {
+			return chaincode.Document{}, err
+		}
+
+		// Commit to the plaintext, so that the owner can check the ciphertext holds what the organization vouches for
+		commitment, err := encryptor.CommitField(document.OrgID, keyID, application.signer, key, document.Data[key], opening)
+		if err != nil {
+			return chaincode.Document{}, err
+		}
+		commitmentJSON, err := json.Marshal(commitment)
 		if err != nil {
 			return chaincode.Document{}, err
 		}
-		document.Data[key] = base64.StdEncoding.EncodeToString(serializedCiphertext)
+		document.Commitments[key] = string(commitmentJSON)
 	}
 
-	// todo: encrypt document
-	// todo: sign document
+	// The owner and the chaincode check this signature against the canonical form of the document
+	if err := chaincode.SignDocument(&document, chaincode.OrgRole, keyID, application.signer.SignHash); err != nil {
+		return chaincode.Document{}, err
+	}
 
 	return document, nil
 }
@@ -101,6 +141,44 @@ func PutDocumentOnBlockchain(localDocuments []chaincode.Document, orgApplication
 	return true
 }
 
+func PublishSigningKey(application *OrgApplication) error {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Println("enter the id of the organization")
+	orgId, _ := reader.ReadString('\n')
+	orgId = strings.Replace(orgId, "\n", "", -1)
+	fmt.Println("enter the name of the organization (only used if it is not registered yet)")
+	name, _ := reader.ReadString('\n')
+	name = strings.Replace(name, "\n", "", -1)
+
+	return application.PublishSigningKey(orgId, name)
+}
+
+func RotateSigningKey(application *OrgApplication) error {
+	info, err := application.RotateSigningKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println("new signing key", info.ID)
+
+	// Owners check commitments against the published key, so the new one is published right away
+	return PublishSigningKey(application)
+}
+
+func ListKeys(application *OrgApplication) error {
+	keys, err := application.ListKeys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		state := "active"
+		if !key.Active() {
+			state = "retired " + key.Retired.Format(time.RFC3339)
+		}
+		fmt.Printf("%s  %-8s %-10s created %s  %s\n", key.ID, key.Label, key.Type, key.Created.Format(time.RFC3339), state)
+	}
+	return nil
+}
+
 func GetDocumentById(application *OrgApplication) (string, error) {
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Println("alright. let's input the id of the document.")
@@ -129,9 +207,9 @@ type TempDocument struct {
 	OrgID   string `json:"OrgID"`
 	OwnerID string `json:"OwnerID"`
 
-	Title string             `json:"Title"`
-	Time  time.Time          `json:"Time"`
-	Data  map[string]float64 `json:"Data"`
+	Title string         `json:"Title"`
+	Time  time.Time      `json:"Time"`
+	Data  map[string]any `json:"Data"`
 
 	OrgSignature   string `json:"OrgSignature"`
 	OwnerSignature string `json:"OwnerSignature"`
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"credit-evaluation/application-gateway/encryption"
+	"credit-evaluation/application-gateway/models"
+	"credit-evaluation/chaincode"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/hash"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	mspID        = "Org2MSP"
+	cryptoPath   = "/home/hosain/go/src/github.com/47817615/fabric-samples/test-network/organizations/peerOrganizations/org2.example.com"
+	certPath     = cryptoPath + "/users/User1@org2.example.com/msp/signcerts"
+	keyPath      = cryptoPath + "/users/User1@org2.example.com/msp/keystore"
+	tlsCertPath  = cryptoPath + "/peers/peer0.org2.example.com/tls/ca.crt"
+	peerEndpoint = "dns:///localhost:9051"
+	gatewayPeer  = "peer0.org2.example.com"
+)
+
+// Names of the files the signing and hybrid keys were kept in, in the clear, next to the CKKS keys before they all
+// moved into the keystore
+const (
+	signingKeyFile = "signing.key"
+	hybridKeyFile  = "hybrid.key"
+)
+
+// Labels of the keys of the persona in its keystore
+const (
+	ckksKeyLabel    = "ckks"
+	hybridKeyLabel  = "hybrid"
+	signingKeyLabel = "signing"
+)
+
+// PersonaKeys holds the keys of the document owner: the CKKS key set and the hybrid key the organizations encrypt
+// the owner's numeric and text fields to, and the ECDSA key the owner signs documents with
+type PersonaKeys struct {
+	CKKS     *encryption.CKKSHelper
+	Hybrid   *ecdh.PrivateKey
+	Signer   *encryption.Signer
+	Keystore encryption.Keystore
+}
+
+// loadPersonaKeys loads the keys kept in the keystore of the persona, kept in PERSONA_KEY_DIR and protected by
+// PERSONA_KEY_PASSWORD, generating them on first use
+// Keys earlier runs kept in the clear in the same directory are moved into the keystore, so that the published keys
+// stay valid.
+func loadPersonaKeys() (*PersonaKeys, error) {
+	keyDir := "persona-keys"
+	if dir := os.Getenv("PERSONA_KEY_DIR"); dir != "" {
+		keyDir = dir
+	}
+	password := os.Getenv("PERSONA_KEY_PASSWORD")
+	if password == "" {
+		return nil, errors.New("set PERSONA_KEY_PASSWORD to the password of the persona keystore")
+	}
+	opts := encryption.HelperOptions{ParameterSet: os.Getenv("CKKS_PARAMETER_SET")}
+
+	ks, err := encryption.OpenFileKeystore(keyDir, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+	keys := &PersonaKeys{Keystore: ks}
+	if keys.CKKS, err = loadCKKSKeys(ks, keyDir, opts); err != nil {
+		return nil, err
+	}
+	if keys.Hybrid, err = loadHybridKey(ks, keyDir); err != nil {
+		return nil, err
+	}
+	signingKey, err := loadSigningKey(ks, keyDir)
+	if err != nil {
+		return nil, err
+	}
+	keys.Signer = encryption.NewSigner(signingKey)
+	return keys, nil
+}
+
+// loadCKKSKeys loads the active CKKS key set, importing or generating it on first use
+func loadCKKSKeys(ks encryption.Keystore, keyDir string, opts encryption.HelperOptions) (*encryption.CKKSHelper, error) {
+	helper, _, err := encryption.ActiveCKKSHelper(ks, ckksKeyLabel, opts)
+	if err == nil || !errors.Is(err, encryption.ErrKeyNotFound) {
+		return helper, err
+	}
+
+	if _, err := os.Stat(path.Join(keyDir, encryption.SecretKeyFile)); err == nil {
+		if helper, _, err = encryption.ImportCKKSKeyDir(ks, ckksKeyLabel, keyDir, opts); err != nil {
+			return nil, err
+		}
+		for _, file := range []string{encryption.SecretKeyFile, encryption.PublicKeyFile, encryption.RelinearizationKeyFile} {
+			if err := os.Remove(path.Join(keyDir, file)); err != nil {
+				return nil, err
+			}
+		}
+		return helper, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	helper, _, err = encryption.RotateCKKSKeys(ks, ckksKeyLabel, opts)
+	return helper, err
+}
+
+// loadHybridKey loads the active hybrid key, importing or generating it on first use
+func loadHybridKey(ks encryption.Keystore, keyDir string) (*ecdh.PrivateKey, error) {
+	hybridKey, _, err := encryption.ActiveHybridKey(ks, hybridKeyLabel)
+	if err == nil || !errors.Is(err, encryption.ErrKeyNotFound) {
+		return hybridKey, err
+	}
+
+	legacyFile := path.Join(keyDir, hybridKeyFile)
+	hybridKey, _, err = encryption.ImportHybridKeyFile(ks, hybridKeyLabel, legacyFile)
+	if err == nil {
+		return hybridKey, os.Remove(legacyFile)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	hybridKey, _, err = encryption.RotateHybridKey(ks, hybridKeyLabel)
+	return hybridKey, err
+}
+
+// loadSigningKey loads the active signing key, importing or generating it on first use
+func loadSigningKey(ks encryption.Keystore, keyDir string) (*ecdsa.PrivateKey, error) {
+	signingKey, _, err := encryption.ActiveSigningKey(ks, signingKeyLabel)
+	if err == nil || !errors.Is(err, encryption.ErrKeyNotFound) {
+		return signingKey, err
+	}
+
+	legacyFile := path.Join(keyDir, signingKeyFile)
+	signingKey, _, err = encryption.ImportSigningKeyFile(ks, signingKeyLabel, legacyFile)
+	if err == nil {
+		return signingKey, os.Remove(legacyFile)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	signingKey, _, err = encryption.RotateSigningKey(ks, signingKeyLabel)
+	return signingKey, err
+}
+
+// RotateSigningKey replaces the signing key of the persona by a new one, which must be published again
+// The previous key is kept retired in the keystore.
+func (k *PersonaKeys) RotateSigningKey() (encryption.KeyInfo, error) {
+	signingKey, info, err := encryption.RotateSigningKey(k.Keystore, signingKeyLabel)
+	if err != nil {
+		return encryption.KeyInfo{}, err
+	}
+	k.Signer = encryption.NewSigner(signingKey)
+	return info, nil
+}
+
+// PublishKeys publishes the public keys of the persona in its user record, creating the record if needed
+func (k *PersonaKeys) PublishKeys(contract *client.Contract, userID string, name string) error {
+	publicKey, err := k.CKKS.EncodedPublicKey()
+	if err != nil {
+		return err
+	}
+	signingKey, err := encryption.EncodeSigningKey(k.Signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	encryptionKey, err := encryption.EncodeHybridKey(k.Hybrid.PublicKey())
+	if err != nil {
+		return err
+	}
+
+	if userJSON, err := contract.EvaluateTransaction("ReadUser", userID); err != nil {
+		fmt.Printf("\n--> Submit Transaction: CreateUser, creates the user %s with its public key\n", userID)
+		if _, err := contract.SubmitTransaction("CreateUser", userID, name, "", publicKey); err != nil {
+			return fmt.Errorf("failed to submit transaction: %w", err)
+		}
+	} else {
+		// Users registered before they were bound to their clients must be claimed before their keys are replaced
+		var user chaincode.User
+		if err := json.Unmarshal(userJSON, &user); err != nil {
+			return fmt.Errorf("malformed user %s: %w", userID, err)
+		}
+		if user.ClientID == "" {
+			fmt.Printf("\n--> Submit Transaction: ClaimUser, binds the user %s to this client\n", userID)
+			if _, err := contract.SubmitTransaction("ClaimUser", userID); err != nil {
+				return fmt.Errorf("failed to submit transaction: %w", err)
+			}
+		}
+	}
+
+	fmt.Printf("\n--> Submit Transaction: UpdateUserKey, publishes the public keys of %s\n", userID)
+	if _, err := contract.SubmitTransaction("UpdateUserKey", userID, publicKey, signingKey, encryptionKey); err != nil {
+		return fmt.Errorf("failed to submit transaction: %w", err)
+	}
+
+	fmt.Printf("*** Transaction committed successfully\n")
+	return nil
+}
+
+// DecryptDocument decrypts the fields of a document encrypted under the persona's public keys
+func (k *PersonaKeys) DecryptDocument(document chaincode.Document) (map[string]string, error) {
+	decryptor := &encryption.FieldDecryptor{CKKS: k.CKKS.CKKSKeyOwner, Hybrid: k.Hybrid}
+	return (&models.Document{Data: document.Data}).Decrypt(decryptor)
+}
+
+// CheckCommitments checks the fields of a document against the commitments its issuer signed, with the signing
+// keys the issuing organization published on the ledger
+func (k *PersonaKeys) CheckCommitments(contract *client.Contract, document chaincode.Document) error {
+	issuer, err := readOrg(contract, document.OrgID)
+	if err != nil {
+		return err
+	}
+	issuerKey := func(keyID string) (*ecdsa.PublicKey, error) {
+		return signingKeyByID(issuer, keyID)
+	}
+
+	decryptor := &encryption.FieldDecryptor{CKKS: k.CKKS.CKKSKeyOwner, Hybrid: k.Hybrid}
+	return (&models.Document{Data: document.Data, Commitments: document.Commitments}).CheckCommitments(decryptor, issuerKey)
+}
+
+// CheckOrgSignature checks the signature of the issuing organization on a document, with the signing key it
+// published on the ledger under the key ID of the signature
+func (k *PersonaKeys) CheckOrgSignature(contract *client.Contract, document chaincode.Document) error {
+	issuer, err := readOrg(contract, document.OrgID)
+	if err != nil {
+		return err
+	}
+	issuerKey, err := signingKeyByID(issuer, document.OrgKeyID)
+	if err != nil {
+		return err
+	}
+	return chaincode.VerifyDocument(&document, chaincode.OrgRole, issuerKey)
+}
+
+// SignDocument signs a document as its owner, over the same canonical form the organization signed
+func (k *PersonaKeys) SignDocument(document *chaincode.Document) error {
+	keyID, err := chaincode.SigningKeyID(k.Signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	return chaincode.SignDocument(document, chaincode.OwnerRole, keyID, k.Signer.SignHash)
+}
+
+// readOrg reads the record of an organization from the ledger
+func readOrg(contract *client.Contract, orgID string) (*chaincode.User, error) {
+	evaluateResult, err := contract.EvaluateTransaction("ReadUser", orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read organization %s from blockchain: %w", orgID, err)
+	}
+	var issuer chaincode.User
+	if err := json.Unmarshal(evaluateResult, &issuer); err != nil {
+		return nil, fmt.Errorf("malformed organization %s: %w", orgID, err)
+	}
+	return &issuer, nil
+}
+
+// signingKeyByID decodes the signing key an organization published under a key ID, current or retired
+func signingKeyByID(issuer *chaincode.User, keyID string) (*ecdsa.PublicKey, error) {
+	signingKey, err := issuer.SigningKeyByID(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.DecodeSigningKey(signingKey)
+}
+
+// newContract connects to the Gateway and returns the credit evaluation contract
+func newContract() (*client.Contract, error) {
+	clientConnection, err := newGrpcConnection()
+	if err != nil {
+		return nil, err
+	}
+	id, err := newIdentity()
+	if err != nil {
+		return nil, err
+	}
+	sign, err := newSign()
+	if err != nil {
+		return nil, err
+	}
+
+	gw, err := client.Connect(
+		id,
+		client.WithSign(sign),
+		client.WithHash(hash.SHA256),
+		client.WithClientConnection(clientConnection),
+		// Default timeouts for different gRPC calls
+		client.WithEvaluateTimeout(5*time.Second),
+		client.WithEndorseTimeout(15*time.Second),
+		client.WithSubmitTimeout(5*time.Second),
+		client.WithCommitStatusTimeout(1*time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Override default values for chaincode and channel name as they may differ in testing contexts.
+	chaincodeName := "basic"
+	if ccname := os.Getenv("CHAINCODE_NAME"); ccname != "" {
+		chaincodeName = ccname
+	}
+
+	channelName := "mychannel"
+	if cname := os.Getenv("CHANNEL_NAME"); cname != "" {
+		channelName = cname
+	}
+
+	return gw.GetNetwork(channelName).GetContract(chaincodeName), nil
+}
+
+// newGrpcConnection creates a gRPC connection to the Gateway server.
+func newGrpcConnection() (*grpc.ClientConn, error) {
+	certificatePEM, err := os.ReadFile(tlsCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS certifcate file: %w", err)
+	}
+
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(certificate)
+	transportCredentials := credentials.NewClientTLSFromCert(certPool, gatewayPeer)
+
+	connection, err := grpc.NewClient(peerEndpoint, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
+	}
+
+	return connection, nil
+}
+
+// newIdentity creates a client identity for this Gateway connection using an X.509 certificate.
+func newIdentity() (*identity.X509Identity, error) {
+	certificatePEM, err := readFirstFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return identity.NewX509Identity(mspID, certificate)
+}
+
+// newSign creates a function that generates a digital signature from a message digest using a private key.
+func newSign() (identity.Sign, error) {
+	privateKeyPEM, err := readFirstFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
+	}
+
+	privateKey, err := identity.PrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return identity.NewPrivateKeySign(privateKey)
+}
+
+func readFirstFile(dirPath string) ([]byte, error) {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNames, err := dir.Readdirnames(1)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path.Join(dirPath, fileNames[0]))
+}
@@ -12,13 +12,18 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
-const privateKey = "privateKey" // todo
 var receivedDos = make([]chaincode.Document, 0)
 var signedDos = make([]chaincode.Document, 0)
 
 func main() {
+	keys, err := loadPersonaKeys()
+	if err != nil {
+		log.Fatalf("failed to load the persona keys: %v", err)
+	}
+
 	http.HandleFunc("/document", handleDocument)
 	go func() {
 		log.Fatal(http.ListenAndServe(":8082", nil))
@@ -29,18 +34,26 @@ func main() {
 			"\nwhat do you want to do?" +
 			"\n1. read and sign received documents" +
 			"\n2. send signed document to organization" +
-			"\n3. get my documents from blockchain")
+			"\n3. get my documents from blockchain" +
+			"\n4. publish my public keys on blockchain" +
+			"\n5. list my keys" +
+			"\n6. rotate my signing key")
 
 		text, _ := reader.ReadString('\n')
 		text = strings.Replace(text, "\n", "", -1)
 		switch text {
 		case "1": // read and sign document
-			// todo: check org signature (VERIFY)
-			readDocuments()
+			readDocuments(keys)
 		case "2": // send document to organization
 			fmt.Println("Hello world")
 		case "3": // retrieve documents from blockchain
 			fmt.Println("Hello world")
+		case "4": // publish public keys
+			publishKeys(keys)
+		case "5": // list the keys of the keystore
+			listKeys(keys)
+		case "6": // rotate the signing key
+			rotateSigningKey(keys)
 		default:
 			fmt.Println("not a valid option!", text)
 		}
@@ -74,7 +87,7 @@ func handleDocument(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func readDocuments() {
+func readDocuments(keys *PersonaKeys) {
 	if len(receivedDos) == 0 {
 		fmt.Println("No documents received")
 		return
@@ -94,20 +107,103 @@ func readDocuments() {
 		return
 	}
 
-	// todo: decrypt
-	docJson, err := json.Marshal(receivedDos[optionNum-1])
+	document := receivedDos[optionNum-1]
+	data, err := keys.DecryptDocument(document)
+	if err != nil {
+		fmt.Println("can not decrypt the document", err)
+		return
+	}
+
+	// Check that the organization signed the document and that the fields hold the values it committed to before
+	// relying on them
+	contract, err := newContract()
+	if err != nil {
+		fmt.Println("can not connect to the blockchain to check the commitments", err)
+		return
+	}
+	if err := keys.CheckOrgSignature(contract, document); err != nil {
+		fmt.Println("the document is not signed by the organization", err)
+		return
+	}
+	if err := keys.CheckCommitments(contract, document); err != nil {
+		fmt.Println("the document does not match the commitments of the organization", err)
+		return
+	}
+	fmt.Println("every field matches the commitment of the organization.")
+
+	decrypted := document
+	decrypted.Data = data
+	docJson, err := json.Marshal(decrypted)
 	if err != nil {
 		fmt.Println("doc format is malformed", err)
 		return
 	}
 	fmt.Println(string(docJson))
 
-	// todo: sign option
+	// The owner signs the document as issued, with its encrypted fields
+	fmt.Println("do you want to sign the document? (y/n)")
+	answer, _ := reader.ReadString('\n')
+	if strings.TrimSpace(answer) == "y" {
+		if err := keys.SignDocument(&document); err != nil {
+			fmt.Println("can not sign the document", err)
+			return
+		}
+		signedDos = append(signedDos, document)
+		fmt.Println("document signed.")
+	}
 
 	fmt.Println("press enter to get back to the menu")
 	_, _ = reader.ReadString('\n')
 }
 
+func publishKeys(keys *PersonaKeys) {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Println("enter your user id")
+	userID, _ := reader.ReadString('\n')
+	userID = strings.Replace(userID, "\n", "", -1)
+	fmt.Println("enter your name (only used if you are not registered yet)")
+	name, _ := reader.ReadString('\n')
+	name = strings.Replace(name, "\n", "", -1)
+
+	contract, err := newContract()
+	if err != nil {
+		fmt.Println("can not connect to the blockchain", err)
+		return
+	}
+	if err := keys.PublishKeys(contract, userID, name); err != nil {
+		fmt.Println("can not publish the keys", err)
+		return
+	}
+	fmt.Println("public keys published successfully.")
+}
+
+func listKeys(keys *PersonaKeys) {
+	infos, err := keys.Keystore.List()
+	if err != nil {
+		fmt.Println("can not list the keys", err)
+		return
+	}
+	for _, info := range infos {
+		state := "active"
+		if !info.Active() {
+			state = "retired " + info.Retired.Format(time.RFC3339)
+		}
+		fmt.Printf("%s  %-8s %-10s created %s  %s\n", info.ID, info.Label, info.Type, info.Created.Format(time.RFC3339), state)
+	}
+}
+
+func rotateSigningKey(keys *PersonaKeys) {
+	info, err := keys.RotateSigningKey()
+	if err != nil {
+		fmt.Println("can not rotate the signing key", err)
+		return
+	}
+	fmt.Println("new signing key", info.ID)
+
+	// The new key is only trusted once published
+	publishKeys(keys)
+}
+
 func calcSignature(document []byte) []byte {
 	h := sha256.New()
 	h.Write(document)
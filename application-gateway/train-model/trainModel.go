@@ -0,0 +1,91 @@
+package main
+
+import (
+	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
+	"credit-evaluation/application-gateway/encryption"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// train-model fits a logistic regression scoring model on a labeled CSV and writes it as a model file, which both
+// LinearModel.Probability and CKKSEvaluator.LinearProbability evaluate
+//
+//	go run ./application-gateway/train-model -data applicants.csv -label Default -l2 0.01 -out model.json
+func main() {
+	data := flag.String("data", "", "labeled CSV with a header row: numeric features and the outcome column")
+	label := flag.String("label", "Default", "name of the outcome column, 1 for a default and 0 otherwise")
+	out := flag.String("out", "model.json", "path of the model file to write")
+	name := flag.String("name", "logistic", "name of the model")
+	normalization := flag.String("normalization", string(credit_evaluation.NormalizationZScore), "normalization of the features: zscore or minmax")
+	l2 := flag.Float64("l2", 0.01, "strength of the L2 penalty on the weights")
+	epochs := flag.Int("epochs", 2000, "number of gradient descent epochs")
+	learningRate := flag.Float64("rate", 0.5, "learning rate of the gradient descent")
+	validation := flag.Float64("validation", 0.2, "share of the samples held out for validation, negative to train on every sample")
+	seed := flag.Int64("seed", 1, "seed of the split between training and validation samples")
+	maxLogit := flag.Float64("max-logit", credit_evaluation.DefaultMaxLogit, "largest absolute logit over the feature ranges")
+	flag.Parse()
+
+	if *data == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*data, *label, *out, *name, credit_evaluation.TrainOptions{
+		Normalization:      credit_evaluation.Normalization(*normalization),
+		L2:                 *l2,
+		LearningRate:       *learningRate,
+		Epochs:             *epochs,
+		ValidationFraction: *validation,
+		Seed:               *seed,
+		MaxLogit:           *maxLogit,
+	}); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run trains the model, reports it with its validation metrics and writes it
+func run(dataPath string, label string, out string, name string, opts credit_evaluation.TrainOptions) error {
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := credit_evaluation.ReadTrainingData(file, label)
+	if err != nil {
+		return err
+	}
+	model, metrics, err := credit_evaluation.Train(data, opts)
+	if err != nil {
+		return err
+	}
+	model.Name = name
+
+	fmt.Printf("Trained on %d samples with %d features\n", metrics.TrainingSamples, len(model.Features))
+	for _, f := range model.Features {
+		fmt.Printf("  %-20s weight %10.4f  range [%g, %g]\n", f.Name, f.Weight, f.Min, f.Max)
+	}
+	fmt.Printf("  %-20s        %10.4f\n", "bias", model.Bias)
+
+	lo, hi := model.LogitRange()
+	policy := encryption.ModelSigmoidPolicy(model)
+	fmt.Printf("Logit range: [%.3f, %.3f], encrypted sigmoid of degree %d over [%.3f, %.3f]\n", lo, hi, policy.Degree, policy.Lo, policy.Hi)
+
+	if opts.ValidationFraction < 0 {
+		fmt.Printf("No validation share, metrics on the %d training samples:\n", metrics.Samples)
+	} else {
+		fmt.Printf("Validation on %d samples:\n", metrics.Samples)
+	}
+	fmt.Printf("  Accuracy:  %.4f\n", metrics.Accuracy)
+	fmt.Printf("  Precision: %.4f\n", metrics.Precision)
+	fmt.Printf("  Recall:    %.4f\n", metrics.Recall)
+	fmt.Printf("  Log loss:  %.4f\n", metrics.LogLoss)
+	fmt.Printf("  AUC:       %.4f\n", metrics.AUC)
+
+	if err := credit_evaluation.WriteModelFile(out, model); err != nil {
+		return err
+	}
+	fmt.Println("Model saved to", out)
+	return nil
+}
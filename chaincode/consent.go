@@ -0,0 +1,87 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// consentDomain separates the digests of consents from any other data signed with the same keys
+const consentDomain = "credit-evaluation/consent/v1"
+
+// ConsentAction tells whether an owner signs the grant or the revocation of a consent, so that one is never
+// accepted for the other
+type ConsentAction string
+
+const (
+	GrantAction  ConsentAction = "grant"
+	RevokeAction ConsentAction = "revoke"
+)
+
+var (
+	ErrInvalidConsentSignature = errors.New("consent signature is invalid")
+	ErrUnknownConsentAction    = errors.New("unknown consent action")
+)
+
+// Digest returns the SHA-256 hash an owner signs for an action on a consent, covering the domain, the action, the
+// owner, the lender, the resource, the expiry in UTC as DocumentTimeFormat and the sequence
+// The sequence counts the grants and revocations of the consent, so that an older signature can not be replayed.
+func (c *Consent) Digest(action ConsentAction) ([]byte, error) {
+	if action != GrantAction && action != RevokeAction {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConsentAction, action)
+	}
+	h := sha256.New()
+	for _, part := range []string{consentDomain, string(action), c.OwnerID, c.LenderID, c.ResourceID,
+		c.ExpiresAt.UTC().Format(DocumentTimeFormat), strconv.FormatUint(c.Sequence, 10)} {
+		h.Write(binary.AppendUvarint(nil, uint64(len(part))))
+		h.Write([]byte(part))
+	}
+	return h.Sum(nil), nil
+}
+
+// SignConsent signs the digest of an action on a consent and returns the base64 signature
+// signHash receives the digest, e.g. the SignHash method of a signer of the applications.
+func SignConsent(c *Consent, action ConsentAction, signHash func(digest []byte) ([]byte, error)) (string, error) {
+	digest, err := c.Digest(action)
+	if err != nil {
+		return "", err
+	}
+	signature, err := signHash(digest)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyConsent checks the signature of an action on a consent with the public key of the owner
+func VerifyConsent(c *Consent, action ConsentAction, signature string, pub *ecdsa.PublicKey) error {
+	digest, err := c.Digest(action)
+	if err != nil {
+		return err
+	}
+	if signature == "" {
+		return fmt.Errorf("%w: the %s is not signed", ErrInvalidConsentSignature, action)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s signature is malformed: %v", ErrInvalidConsentSignature, action, err)
+	}
+	if !ecdsa.VerifyASN1(pub, digest, decoded) {
+		return fmt.Errorf("%w: %s signature does not match", ErrInvalidConsentSignature, action)
+	}
+	return nil
+}
+
+// VerifyConsentWithKey checks the signature of an action on a consent with a signing key published on the ledger,
+// encoded as base64 PKIX
+func VerifyConsentWithKey(c *Consent, action ConsentAction, signature string, signingKey string) error {
+	pub, err := parseSigningKey(signingKey)
+	if err != nil {
+		return err
+	}
+	return VerifyConsent(c, action, signature, pub)
+}
@@ -0,0 +1,54 @@
+package chaincode
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestConsentSignature ensures that an owner signature only vouches for one action on one consent at one sequence
+func TestConsentSignature(t *testing.T) {
+	key := newTestSigner(t)
+
+	consent := Consent{
+		OwnerID:    "alice",
+		LenderID:   "bank",
+		ResourceID: "123456",
+		ExpiresAt:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sequence:   1,
+	}
+	grant, err := SignConsent(&consent, GrantAction, key.signHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyConsent(&consent, GrantAction, grant, &key.PublicKey); err != nil {
+		t.Errorf("VerifyConsent of the grant failed: %v", err)
+	}
+
+	// The grant does not revoke, and does not grant again once the consent moved on
+	if err := VerifyConsent(&consent, RevokeAction, grant, &key.PublicKey); !errors.Is(err, ErrInvalidConsentSignature) {
+		t.Errorf("grant accepted as revocation: %v", err)
+	}
+	replayed := consent
+	replayed.Sequence = 3
+	if err := VerifyConsent(&replayed, GrantAction, grant, &key.PublicKey); !errors.Is(err, ErrInvalidConsentSignature) {
+		t.Errorf("replayed grant returned %v, expected %v", err, ErrInvalidConsentSignature)
+	}
+	other := consent
+	other.LenderID = "other bank"
+	if err := VerifyConsent(&other, GrantAction, grant, &key.PublicKey); !errors.Is(err, ErrInvalidConsentSignature) {
+		t.Errorf("grant to another lender returned %v, expected %v", err, ErrInvalidConsentSignature)
+	}
+	extended := consent
+	extended.ExpiresAt = time.Time{}
+	if err := VerifyConsent(&extended, GrantAction, grant, &key.PublicKey); !errors.Is(err, ErrInvalidConsentSignature) {
+		t.Errorf("grant with another expiry returned %v, expected %v", err, ErrInvalidConsentSignature)
+	}
+
+	if err := VerifyConsent(&consent, RevokeAction, "", &key.PublicKey); !errors.Is(err, ErrInvalidConsentSignature) {
+		t.Errorf("unsigned revocation returned %v, expected %v", err, ErrInvalidConsentSignature)
+	}
+	if _, err := consent.Digest("renew"); !errors.Is(err, ErrUnknownConsentAction) {
+		t.Errorf("unknown action returned %v, expected %v", err, ErrUnknownConsentAction)
+	}
+}
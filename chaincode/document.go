@@ -0,0 +1,212 @@
+package chaincode
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DocumentTimeFormat is the format of the time of a document in its canonical form and in transaction arguments
+const DocumentTimeFormat = time.RFC3339Nano
+
+// documentDomain separates the digests of documents from any other data signed with the same keys
+const documentDomain = "credit-evaluation/document/v1"
+
+// SignatureRole tells who signs a document, so that a signature of one role is never accepted for the other
+type SignatureRole string
+
+const (
+	OrgRole   SignatureRole = "org"
+	OwnerRole SignatureRole = "owner"
+)
+
+var (
+	ErrMissingSignature = errors.New("document is not signed")
+	ErrInvalidSignature = errors.New("document signature is invalid")
+	ErrUnknownRole      = errors.New("unknown signature role")
+)
+
+// canonicalDocument is the signed content of a document, its fields declared in sorted order
+// The ID is left out, as the ledger assigns it once the organization signed, and so are the signatures and their key
+// IDs, which only the digest of their role covers. Previous
+// chains every version to the one it replaces, so that the signatures of an older version can not be replayed.
+type canonicalDocument struct {
+	Commitments map[string]string `json:"Commitments"`
+	Data        map[string]string `json:"Data"`
+	OrgID       string            `json:"OrgID"`
+	OwnerID     string            `json:"OwnerID"`
+	Previous    string            `json:"Previous"`
+	Time        string            `json:"Time"`
+	Title       string            `json:"Title"`
+}
+
+// CanonicalBytes returns the bytes of the document that are signed: compact JSON with sorted keys, without the ID
+// and the signatures, the time in UTC as DocumentTimeFormat and missing maps written as empty ones
+func (d *Document) CanonicalBytes() ([]byte, error) {
+	canonical := canonicalDocument{
+		Commitments: d.Commitments,
+		Data:        d.Data,
+		OrgID:       d.OrgID,
+		OwnerID:     d.OwnerID,
+		Previous:    d.Previous,
+		Time:        d.Time.UTC().Format(DocumentTimeFormat),
+		Title:       d.Title,
+	}
+	if canonical.Commitments == nil {
+		canonical.Commitments = map[string]string{}
+	}
+	if canonical.Data == nil {
+		canonical.Data = map[string]string{}
+	}
+
+	// encoding/json sorts the keys of maps; HTML escaping is turned off so that the bytes are plain JSON
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(canonical); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Hash returns the hex SHA-256 of the canonical bytes, which the next version of the document records as Previous
+func (d *Document) Hash() (string, error) {
+	canonical, err := d.CanonicalBytes()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Digest returns the SHA-256 hash a role signs, covering the domain, the role, the ID of the signing key of the role
+// and the canonical bytes
+// The key ID is left out of the canonical form, so that the owner can sign with any of its keys after the
+// organization signed.
+func (d *Document) Digest(role SignatureRole) ([]byte, error) {
+	if role != OrgRole && role != OwnerRole {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+	canonical, err := d.CanonicalBytes()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(documentDomain), []byte(role), []byte(*d.keyID(role)), canonical} {
+		h.Write(binary.AppendUvarint(nil, uint64(len(part))))
+		h.Write(part)
+	}
+	return h.Sum(nil), nil
+}
+
+// SignDocument signs the digest of a document for a role with the key of ID keyID, as SigningKeyID returns it, and
+// stores the key ID and the base64 signature in the fields of the role
+// signHash receives the digest, e.g. the SignHash method of a signer of the applications.
+func SignDocument(d *Document, role SignatureRole, keyID string, signHash func(digest []byte) ([]byte, error)) error {
+	if role != OrgRole && role != OwnerRole {
+		return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+	*d.keyID(role) = keyID
+	digest, err := d.Digest(role)
+	if err != nil {
+		return err
+	}
+	signature, err := signHash(digest)
+	if err != nil {
+		return err
+	}
+	*d.signature(role) = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// VerifyDocument checks the signature of a role on a document with the public key of the signer, which must be the
+// key the document records for the role
+func VerifyDocument(d *Document, role SignatureRole, pub *ecdsa.PublicKey) error {
+	digest, err := d.Digest(role)
+	if err != nil {
+		return err
+	}
+	encoded := *d.signature(role)
+	if encoded == "" {
+		return fmt.Errorf("%w by the %s", ErrMissingSignature, role)
+	}
+	keyID, err := SigningKeyID(pub)
+	if err != nil {
+		return err
+	}
+	if recorded := *d.keyID(role); recorded != keyID {
+		return fmt.Errorf("%w: %s signed with key %q, not %s", ErrInvalidSignature, role, recorded, keyID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %s signature is malformed: %v", ErrInvalidSignature, role, err)
+	}
+	if !ecdsa.VerifyASN1(pub, digest, signature) {
+		return fmt.Errorf("%w: %s signature does not match", ErrInvalidSignature, role)
+	}
+	return nil
+}
+
+// VerifyDocumentWithKey checks the signature of a role with a signing key published on the ledger, encoded as
+// base64 PKIX
+func VerifyDocumentWithKey(d *Document, role SignatureRole, signingKey string) error {
+	pub, err := parseSigningKey(signingKey)
+	if err != nil {
+		return err
+	}
+	return VerifyDocument(d, role, pub)
+}
+
+// SigningKeyID returns the ID of a signing key: the hex SHA-256 of its PKIX encoding, truncated to 16 bytes
+func SigningKeyID(pub *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// signature returns the field holding the signature of a role, which must be known
+func (d *Document) signature(role SignatureRole) *string {
+	if role == OrgRole {
+		return &d.OrgSignature
+	}
+	return &d.OwnerSignature
+}
+
+// keyID returns the field holding the ID of the signing key of a role, which must be known
+func (d *Document) keyID(role SignatureRole) *string {
+	if role == OrgRole {
+		return &d.OrgKeyID
+	}
+	return &d.OwnerKeyID
+}
+
+// parseSigningKey parses a signing key as the applications publish it on the ledger
+func parseSigningKey(encoded string) (*ecdsa.PublicKey, error) {
+	if encoded == "" {
+		return nil, errors.New("no signing key is published")
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("signing key is malformed: %w", err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("signing key is malformed: %w", err)
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("signing key is not an ECDSA public key")
+	}
+	return key, nil
+}
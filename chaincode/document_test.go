@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testSigner signs digests like the signers of the applications
+type testSigner struct {
+	*ecdsa.PrivateKey
+}
+
+// newTestSigner generates a signing key as the applications do
+func newTestSigner(t *testing.T) testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{key}
+}
+
+func (s testSigner) signHash(digest []byte) ([]byte, error) {
+	return ecdsa.SignASN1(rand.Reader, s.PrivateKey, digest)
+}
+
+// encodeTestKey encodes a signing key as the applications publish it on the ledger
+func encodeTestKey(pub *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// TestCanonicalBytes pins the bytes every signature of a document covers, which must never change for the documents
+// already on the ledger to keep verifying
+func TestCanonicalBytes(t *testing.T) {
+	document := Document{
+		ID:          "123456",
+		OrgID:       "bank",
+		OwnerID:     "alice",
+		Title:       "salary <statement> & more",
+		Time:        time.Date(2024, 5, 17, 10, 30, 0, 123456789, time.FixedZone("CEST", 2*3600)),
+		Data:        map[string]string{"Salary": "ct-salary", "Age": "ct-age"},
+		Commitments: map[string]string{"Salary": "c-salary", "Age": "c-age"},
+		Previous:    "previous-hash",
+		OrgKeyID:    "org-key",
+		OwnerKeyID:  "owner-key",
+	}
+
+	canonical, err := document.CanonicalBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Commitments":{"Age":"c-age","Salary":"c-salary"},"Data":{"Age":"ct-age","Salary":"ct-salary"},` +
+		`"OrgID":"bank","OwnerID":"alice","Previous":"previous-hash","Time":"2024-05-17T08:30:00.123456789Z",` +
+		`"Title":"salary <statement> & more"}`
+	if string(canonical) != expected {
+		t.Errorf("canonical form is\n%s\nexpected\n%s", canonical, expected)
+	}
+
+	hash, err := document.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "f0ec7613ab730a73f5bbd955a48020e2c5d1100c7ecd11c0fb3ad8605478cea5"; hash != expected {
+		t.Errorf("Hash returned %s, expected %s", hash, expected)
+	}
+	for role, expected := range map[SignatureRole]string{
+		OrgRole:   "fe7f97e7a72ffa461cf7ce04ee2c163da0899b344ede2779a75064b6494ea7b1",
+		OwnerRole: "9b6079a0675b54bbea40ba7ef73662aeb93ef64257cfdd01710afb7917eba853",
+	} {
+		digest, err := document.Digest(role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(digest) != expected {
+			t.Errorf("Digest(%s) returned %x, expected %s", role, digest, expected)
+		}
+	}
+	if _, err := document.Digest("lender"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("Digest of an unknown role returned %v, expected %v", err, ErrUnknownRole)
+	}
+
+	// Missing and empty maps have the same canonical form
+	empty := Document{OrgID: "bank", Data: map[string]string{}, Commitments: map[string]string{}}
+	missing := Document{OrgID: "bank"}
+	emptyBytes, _ := empty.CanonicalBytes()
+	missingBytes, _ := missing.CanonicalBytes()
+	if string(emptyBytes) != string(missingBytes) {
+		t.Errorf("canonical forms differ: %s and %s", emptyBytes, missingBytes)
+	}
+}
+
+// TestSignDocument ensures that document signatures only depend on the canonical form of the document, the role and
+// the signing key, and survive the round trip through the ledger
+func TestSignDocument(t *testing.T) {
+	org, owner := newTestSigner(t), newTestSigner(t)
+
+	issued := time.Date(2024, 5, 17, 10, 30, 0, 123456789, time.FixedZone("CEST", 2*3600))
+	document := Document{
+		OrgID:       "bank",
+		OwnerID:     "alice",
+		Title:       "salary <statement> & more",
+		Time:        issued,
+		Data:        map[string]string{"Salary": "ct-salary", "Age": "ct-age"},
+		Commitments: map[string]string{"Salary": "c-salary", "Age": "c-age"},
+	}
+	orgKeyID, err := SigningKeyID(&org.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerKeyID, err := SigningKeyID(&owner.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignDocument(&document, OrgRole, orgKeyID, org.signHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignDocument(&document, OwnerRole, ownerKeyID, owner.signHash); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ledger assigns the ID and stores the document as JSON, in another time zone
+	stored, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read Document
+	if err := json.Unmarshal(stored, &read); err != nil {
+		t.Fatal(err)
+	}
+	read.ID = "123456"
+	read.Time = read.Time.In(time.UTC)
+	if err := VerifyDocument(&read, OrgRole, &org.PublicKey); err != nil {
+		t.Errorf("organization signature of the stored document: %v", err)
+	}
+	if err := VerifyDocument(&read, OwnerRole, &owner.PublicKey); err != nil {
+		t.Errorf("owner signature of the stored document: %v", err)
+	}
+	encoded, err := encodeTestKey(&org.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyDocumentWithKey(&read, OrgRole, encoded); err != nil {
+		t.Errorf("organization signature with the published key: %v", err)
+	}
+
+	// A signature of one role is not accepted for the other, nor under another key ID
+	swapped := read
+	swapped.OwnerSignature, swapped.OwnerKeyID = read.OrgSignature, read.OrgKeyID
+	if err := VerifyDocument(&swapped, OwnerRole, &org.PublicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("organization signature accepted as owner signature: %v", err)
+	}
+	renamed := read
+	renamed.OrgKeyID = ownerKeyID
+	if err := VerifyDocument(&renamed, OrgRole, &org.PublicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("signature under another key ID returned %v, expected %v", err, ErrInvalidSignature)
+	}
+
+	tampered := read
+	tampered.Data = map[string]string{"Salary": "other", "Age": "ct-age"}
+	if err := VerifyDocument(&tampered, OrgRole, &org.PublicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered document returned %v, expected %v", err, ErrInvalidSignature)
+	}
+	tampered = read
+	tampered.Time = issued.Add(time.Nanosecond)
+	if err := VerifyDocument(&tampered, OrgRole, &org.PublicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("document with another time returned %v, expected %v", err, ErrInvalidSignature)
+	}
+
+	// The signatures of a version do not carry over to an update chained to it
+	update := read
+	if update.Previous, err = read.Hash(); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyDocument(&update, OrgRole, &org.PublicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("replayed signature returned %v, expected %v", err, ErrInvalidSignature)
+	}
+
+	unsigned := read
+	unsigned.OwnerSignature = ""
+	if err := VerifyDocument(&unsigned, OwnerRole, &owner.PublicKey); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("unsigned document returned %v, expected %v", err, ErrMissingSignature)
+	}
+
+}
@@ -22,8 +22,18 @@ type Document struct {
 	Time  time.Time         `json:"Time"`
 	Data  map[string]string `json:"Data"`
 
+	// Commitments holds, per field, the issuer-signed commitment to the plaintext of its ciphertext
+	Commitments map[string]string `json:"Commitments,omitempty"`
+
+	// Previous holds the Hash of the version an update replaced, empty for the first version
+	Previous string `json:"Previous,omitempty"`
+
 	OrgSignature   string `json:"OrgSignature"`
 	OwnerSignature string `json:"OwnerSignature"`
+
+	// OrgKeyID and OwnerKeyID name the signing keys of the signatures, so that they still verify after a rotation
+	OrgKeyID   string `json:"OrgKeyID,omitempty"`
+	OwnerKeyID string `json:"OwnerKeyID,omitempty"`
 }
 
 // InitLedger adds the fist document to the ledger
@@ -52,21 +62,36 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	return err
 }
 
-func (s *SmartContract) CreateDocument(ctx contractapi.TransactionContextInterface, orgID string, ownerID string, title string, time time.Time, data map[string]string, orgSignature string, ownerSignature string) (string, error) {
+// CreateDocument adds a document to the world state under the ID derived from its owner, title and time
+// A document that already exists is not replaced, so that the first version can not be submitted again over the
+// updates that followed it.
+func (s *SmartContract) CreateDocument(ctx contractapi.TransactionContextInterface, orgID string, ownerID string, title string, time time.Time, data map[string]string, commitments map[string]string, orgSignature string, ownerSignature string, orgKeyID string, ownerKeyID string) (string, error) {
 	document := Document{
 		OrgID:          orgID,
 		OwnerID:        ownerID,
 		Title:          title,
 		Time:           time,
 		Data:           data,
+		Commitments:    commitments,
 		OrgSignature:   orgSignature,
 		OwnerSignature: ownerSignature,
+		OrgKeyID:       orgKeyID,
+		OwnerKeyID:     ownerKeyID,
+	}
+	if err := s.verifySignatures(ctx, &document); err != nil {
+		return "", err
 	}
 	id, err := document.getID()
 	if err != nil {
 		return "", err
 	}
-	id = "123456"
+	exists, err := s.DocumentExists(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("the document %s already exists", id)
+	}
 	document.ID = id
 
 	documentJSON, err := json.Marshal(document)
@@ -102,23 +127,38 @@ func (s *SmartContract) ReadDocument(ctx contractapi.TransactionContextInterface
 }
 
 // UpdateDocument updates an existing document in the world state with provided parameters.
-func (s *SmartContract) UpdateDocument(ctx contractapi.TransactionContextInterface, id string, title string, time time.Time, data map[string]string, orgSignature string, ownerSignature string) error {
-	exists, err := s.DocumentExists(ctx, id)
+// The signatures must cover the Hash of the stored version as Previous, and a document its owner signed must be
+// signed by the owner again.
+func (s *SmartContract) UpdateDocument(ctx contractapi.TransactionContextInterface, id string, title string, time time.Time, data map[string]string, commitments map[string]string, orgSignature string, ownerSignature string, orgKeyID string, ownerKeyID string) error {
+	existing, err := s.ReadDocument(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the document %s does not exist", id)
+	if existing.OwnerSignature != "" && ownerSignature == "" {
+		return fmt.Errorf("the document %s is signed by its owner, who must sign the update too", id)
+	}
+	previous, err := existing.Hash()
+	if err != nil {
+		return err
 	}
 
-	// overwriting original document with new document
+	// overwriting original document with new document, which stays between the same organization and owner
 	document := Document{
 		ID:             id,
+		OrgID:          existing.OrgID,
+		OwnerID:        existing.OwnerID,
 		Title:          title,
 		Time:           time,
 		Data:           data,
+		Commitments:    commitments,
+		Previous:       previous,
 		OrgSignature:   orgSignature,
 		OwnerSignature: ownerSignature,
+		OrgKeyID:       orgKeyID,
+		OwnerKeyID:     ownerKeyID,
+	}
+	if err := s.verifySignatures(ctx, &document); err != nil {
+		return err
 	}
 	documentJSON, err := json.Marshal(document)
 	if err != nil {
@@ -209,6 +249,31 @@ func (s *SmartContract) GetAllDocumentsByOwner(ctx contractapi.TransactionContex
 	return documents, nil
 }
 
+// verifySignatures checks the signature of the organization, and the one of the owner once given, with the signing
+// keys they published on the ledger
+// Documents are submitted signed with the current keys; the keys retired since only verify the stored documents.
+func (s *SmartContract) verifySignatures(ctx contractapi.TransactionContextInterface, document *Document) error {
+	org, err := s.ReadUser(ctx, document.OrgID)
+	if err != nil {
+		return fmt.Errorf("can not check the signature of the organization: %w", err)
+	}
+	if err := VerifyDocumentWithKey(document, OrgRole, org.SigningKey); err != nil {
+		return fmt.Errorf("organization %s: %w", document.OrgID, err)
+	}
+
+	if document.OwnerSignature == "" {
+		return nil
+	}
+	owner, err := s.ReadUser(ctx, document.OwnerID)
+	if err != nil {
+		return fmt.Errorf("can not check the signature of the owner: %w", err)
+	}
+	if err := VerifyDocumentWithKey(document, OwnerRole, owner.SigningKey); err != nil {
+		return fmt.Errorf("owner %s: %w", document.OwnerID, err)
+	}
+	return nil
+}
+
 // getID generates and returns a unique ID for a document
 func (d *Document) getID() (string, error) {
 	return d.OwnerID + d.Title + d.Time.String(), nil // todo
@@ -223,16 +288,52 @@ type User struct {
 	CreatedAt   time.Time `json:"Time"`
 	DateOfBirth time.Time `json:"DateOfBirth"`
 
-	GovSignature string `json:"GovSignature"`
-	PublicKey    string `json:"PublicKey"`
+	GovSignature  string `json:"GovSignature"`
+	PublicKey     string `json:"PublicKey"`
+	SigningKey    string `json:"SigningKey"`
+	EncryptionKey string `json:"EncryptionKey"`
+
+	// SigningKeys holds every signing key the user published, by SigningKeyID, SigningKey being the current one
+	SigningKeys map[string]string `json:"SigningKeys,omitempty"`
+
+	// ClientID is the Fabric identity that registered the user, the only one allowed to replace its keys
+	ClientID string `json:"ClientID"`
+}
+
+// SigningKeyByID returns the signing key of a user with the given ID, current or retired
+// The current key is also found in records published before the history was kept.
+func (u *User) SigningKeyByID(keyID string) (string, error) {
+	if signingKey, ok := u.SigningKeys[keyID]; ok {
+		return signingKey, nil
+	}
+	if pub, err := parseSigningKey(u.SigningKey); err == nil {
+		if current, err := SigningKeyID(pub); err == nil && current == keyID {
+			return u.SigningKey, nil
+		}
+	}
+	return "", fmt.Errorf("the user %s has not published the signing key %s", u.ID, keyID)
 }
 
+// CreateUser registers a user, bound to the identity of the submitting client
 func (s *SmartContract) CreateUser(ctx contractapi.TransactionContextInterface, userID string, name string, govSignature string, publicKey string) (string, error) {
+	existing, err := ctx.GetStub().GetState(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return "", fmt.Errorf("the user %s already exists", userID)
+	}
+	clientID, err := submittingClient(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	user := User{
 		ID:           userID,
 		Name:         name,
 		GovSignature: govSignature,
 		PublicKey:    publicKey,
+		ClientID:     clientID,
 	}
 
 	userJSON, err := json.Marshal(user)
@@ -263,3 +364,266 @@ func (s *SmartContract) ReadUser(ctx contractapi.TransactionContextInterface, us
 	}
 	return &user, nil
 }
+
+// UpdateUserKey replaces the homomorphic public key, the signing key and the hybrid encryption key a user
+// published on the ledger. The replaced signing key stays in the history of the user.
+func (s *SmartContract) UpdateUserKey(ctx contractapi.TransactionContextInterface, userID string, publicKey string, signingKey string, encryptionKey string) error {
+	user, err := s.readOwnUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	user.PublicKey = publicKey
+	if err := user.setSigningKey(signingKey); err != nil {
+		return err
+	}
+	user.EncryptionKey = encryptionKey
+	return s.putUser(ctx, user)
+}
+
+// UpdateSigningKey replaces the signing key a user published on the ledger and leaves its other keys untouched, for
+// users that only sign, such as organizations
+func (s *SmartContract) UpdateSigningKey(ctx contractapi.TransactionContextInterface, userID string, signingKey string) error {
+	user, err := s.readOwnUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := user.setSigningKey(signingKey); err != nil {
+		return err
+	}
+	return s.putUser(ctx, user)
+}
+
+// ClaimUser binds a user registered before the users were bound to their clients to the submitting client, which
+// may then update it. Like CreateUser, the first client to submit it gets the user; it can only happen once.
+func (s *SmartContract) ClaimUser(ctx contractapi.TransactionContextInterface, userID string) error {
+	user, err := s.ReadUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.ClientID != "" {
+		return fmt.Errorf("the user %s is already bound to a client", userID)
+	}
+	if user.ClientID, err = submittingClient(ctx); err != nil {
+		return err
+	}
+	return s.putUser(ctx, user)
+}
+
+// readOwnUser reads a user record the submitting client may update: the one it registered or claimed
+func (s *SmartContract) readOwnUser(ctx contractapi.TransactionContextInterface, userID string) (*User, error) {
+	user, err := s.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ClientID == "" {
+		return nil, fmt.Errorf("the user %s is not bound to a client yet and must be claimed first", userID)
+	}
+	clientID, err := submittingClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.ClientID != clientID {
+		return nil, fmt.Errorf("the client is not allowed to update the user %s", userID)
+	}
+	return user, nil
+}
+
+// submittingClient returns the identity of the client submitting the transaction, qualified by its MSP
+func submittingClient(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the client identity: %v", err)
+	}
+	id, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the client identity: %v", err)
+	}
+	return mspID + "/" + id, nil
+}
+
+// setSigningKey makes a signing key the current one of the user and adds it to the history
+func (u *User) setSigningKey(signingKey string) error {
+	if signingKey != "" {
+		pub, err := parseSigningKey(signingKey)
+		if err != nil {
+			return err
+		}
+		keyID, err := SigningKeyID(pub)
+		if err != nil {
+			return err
+		}
+		if u.SigningKeys == nil {
+			u.SigningKeys = make(map[string]string)
+		}
+		u.SigningKeys[keyID] = signingKey
+	}
+	u.SigningKey = signingKey
+	return nil
+}
+
+// putUser writes a user record to the world state
+func (s *SmartContract) putUser(ctx contractapi.TransactionContextInterface, user *User) error {
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(user.ID, userJSON)
+}
+
+///////////////////////////////////// consents /////////////////////////////////////
+
+// consentObjectType prefixes the composite keys of the consents, which keeps them out of the document range queries
+const consentObjectType = "consent"
+
+// Consent records that an owner allows a lender to learn the results computed on one of the owner's resources
+type Consent struct {
+	OwnerID    string `json:"OwnerID"`
+	LenderID   string `json:"LenderID"`
+	ResourceID string `json:"ResourceID"`
+
+	GrantedAt time.Time `json:"GrantedAt"`
+	ExpiresAt time.Time `json:"ExpiresAt"`
+	Revoked   bool      `json:"Revoked"`
+
+	// Sequence counts the grants and revocations of the consent, the last one signed by the owner
+	Sequence            uint64 `json:"Sequence"`
+	OwnerSignature      string `json:"OwnerSignature"`
+	RevocationSignature string `json:"RevocationSignature,omitempty"`
+}
+
+// GrantConsent records the consent of an owner to share the results on a resource with a lender until expiresAt
+// A zero expiresAt grants the consent until it is revoked. The owner signs the grant with the next sequence of the
+// consent, one for a first grant, with the signing key published on the ledger.
+func (s *SmartContract) GrantConsent(ctx contractapi.TransactionContextInterface, ownerID string, lenderID string, resourceID string, expiresAt time.Time, ownerSignature string) error {
+	owner, err := s.ReadUser(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+
+	key, err := consentKey(ctx, ownerID, lenderID, resourceID)
+	if err != nil {
+		return err
+	}
+	existing, err := s.readConsent(ctx, key)
+	if err != nil {
+		return err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	consent := Consent{
+		OwnerID:        ownerID,
+		LenderID:       lenderID,
+		ResourceID:     resourceID,
+		GrantedAt:      timestamp.AsTime(),
+		ExpiresAt:      expiresAt,
+		Sequence:       1,
+		OwnerSignature: ownerSignature,
+	}
+	if existing != nil {
+		consent.Sequence = existing.Sequence + 1
+	}
+	if err := VerifyConsentWithKey(&consent, GrantAction, ownerSignature, owner.SigningKey); err != nil {
+		return fmt.Errorf("owner %s: %w", ownerID, err)
+	}
+	consentJSON, err := json.Marshal(consent)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, consentJSON)
+}
+
+// RevokeConsent withdraws a consent, which is kept on the ledger as revoked
+// The owner signs the revocation with the next sequence of the consent.
+func (s *SmartContract) RevokeConsent(ctx contractapi.TransactionContextInterface, ownerID string, lenderID string, resourceID string, ownerSignature string) error {
+	owner, err := s.ReadUser(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	consent, err := s.ReadConsent(ctx, ownerID, lenderID, resourceID)
+	if err != nil {
+		return err
+	}
+	key, err := consentKey(ctx, ownerID, lenderID, resourceID)
+	if err != nil {
+		return err
+	}
+
+	consent.Sequence++
+	if err := VerifyConsentWithKey(consent, RevokeAction, ownerSignature, owner.SigningKey); err != nil {
+		return fmt.Errorf("owner %s: %w", ownerID, err)
+	}
+	consent.Revoked = true
+	consent.RevocationSignature = ownerSignature
+	consentJSON, err := json.Marshal(consent)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, consentJSON)
+}
+
+// ReadConsent returns the consent of an owner to share the results on a resource with a lender
+func (s *SmartContract) ReadConsent(ctx contractapi.TransactionContextInterface, ownerID string, lenderID string, resourceID string) (*Consent, error) {
+	key, err := consentKey(ctx, ownerID, lenderID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	consent, err := s.readConsent(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if consent == nil {
+		return nil, fmt.Errorf("the consent of %s to %s for %s does not exist", ownerID, lenderID, resourceID)
+	}
+	return consent, nil
+}
+
+// HasConsent returns true when the owner granted the lender a consent on the resource that is neither revoked nor expired
+func (s *SmartContract) HasConsent(ctx contractapi.TransactionContextInterface, ownerID string, lenderID string, resourceID string) (bool, error) {
+	key, err := consentKey(ctx, ownerID, lenderID, resourceID)
+	if err != nil {
+		return false, err
+	}
+	consent, err := s.readConsent(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if consent == nil || consent.Revoked {
+		return false, nil
+	}
+
+	// Expiry is checked against the transaction time, so that every peer reaches the same answer
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return false, err
+	}
+	return consent.ExpiresAt.IsZero() || timestamp.AsTime().Before(consent.ExpiresAt), nil
+}
+
+// readConsent returns the consent stored under a key, nil when there is none
+func (s *SmartContract) readConsent(ctx contractapi.TransactionContextInterface, key string) (*Consent, error) {
+	consentJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if consentJSON == nil {
+		return nil, nil
+	}
+
+	var consent Consent
+	if err := json.Unmarshal(consentJSON, &consent); err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// consentKey returns the world state key of a consent
+func consentKey(ctx contractapi.TransactionContextInterface, ownerID string, lenderID string, resourceID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(consentObjectType, []string{ownerID, lenderID, resourceID})
+}
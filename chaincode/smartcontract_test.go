@@ -0,0 +1,170 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// testStub keeps the world state in memory; the stub methods the contract does not use are left unimplemented
+type testStub struct {
+	shim.ChaincodeStubInterface
+	state map[string][]byte
+}
+
+func (s *testStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	s.state[key] = value
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	delete(s.state, key)
+	return nil
+}
+
+// testClient is the identity of the client submitting the transactions
+type testClient struct {
+	cid.ClientIdentity
+	mspID string
+	id    string
+}
+
+func (c testClient) GetMSPID() (string, error) {
+	return c.mspID, nil
+}
+
+func (c testClient) GetID() (string, error) {
+	return c.id, nil
+}
+
+// newTestContext returns a transaction context with an empty world state, submitted by the given client
+func newTestContext(mspID string, id string) *contractapi.TransactionContext {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(&testStub{state: make(map[string][]byte)})
+	ctx.SetClientIdentity(testClient{mspID: mspID, id: id})
+	return ctx
+}
+
+// putTestUser stores a user record with a published signing key
+func putTestUser(t *testing.T, ctx *contractapi.TransactionContext, userID string, key testSigner) {
+	signingKey, err := encodeTestKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userJSON, err := json.Marshal(User{ID: userID, SigningKey: signingKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.GetStub().PutState(userID, userJSON); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCreateDocumentReplay ensures that submitting the first version of a document again does not replace the
+// updates that followed it
+func TestCreateDocumentReplay(t *testing.T) {
+	contract := new(SmartContract)
+	ctx := newTestContext("BankMSP", "bank-client")
+	orgKey := newTestSigner(t)
+	putTestUser(t, ctx, "bank", orgKey)
+	orgKeyID, err := SigningKeyID(&orgKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := Document{
+		OrgID:   "bank",
+		OwnerID: "alice",
+		Title:   "salary statement",
+		Time:    time.Date(2024, 5, 17, 8, 30, 0, 0, time.UTC),
+		Data:    map[string]string{"Salary": "ct-salary"},
+	}
+	if err := SignDocument(&created, OrgRole, orgKeyID, orgKey.signHash); err != nil {
+		t.Fatal(err)
+	}
+	create := func() (string, error) {
+		return contract.CreateDocument(ctx, created.OrgID, created.OwnerID, created.Title, created.Time, created.Data,
+			created.Commitments, created.OrgSignature, created.OwnerSignature, created.OrgKeyID, created.OwnerKeyID)
+	}
+	id, err := create()
+	if err != nil {
+		t.Fatalf("CreateDocument failed: %v", err)
+	}
+	if expected, _ := created.getID(); id != expected {
+		t.Errorf("CreateDocument returned the ID %s, expected %s", id, expected)
+	}
+
+	previous, err := created.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := created
+	updated.Data = map[string]string{"Salary": "ct-raised-salary"}
+	updated.Previous = previous
+	if err := SignDocument(&updated, OrgRole, orgKeyID, orgKey.signHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := contract.UpdateDocument(ctx, id, updated.Title, updated.Time, updated.Data, updated.Commitments,
+		updated.OrgSignature, updated.OwnerSignature, updated.OrgKeyID, updated.OwnerKeyID); err != nil {
+		t.Fatalf("UpdateDocument failed: %v", err)
+	}
+
+	if _, err := create(); err == nil {
+		t.Error("CreateDocument replayed the first version over the update")
+	}
+	read, err := contract.ReadDocument(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Previous != previous || read.Data["Salary"] != "ct-raised-salary" {
+		t.Errorf("ReadDocument returned %+v, expected the update", read)
+	}
+}
+
+// TestClaimUser ensures that a user registered before the users were bound to their clients is claimed once, by the
+// first client, which is then the only one allowed to replace its keys
+func TestClaimUser(t *testing.T) {
+	contract := new(SmartContract)
+	ctx := newTestContext("PersonaMSP", "alice-client")
+	other := newTestContext("PersonaMSP", "mallory-client")
+	other.SetStub(ctx.GetStub())
+	putTestUser(t, ctx, "alice", newTestSigner(t))
+
+	signingKey, err := encodeTestKey(&newTestSigner(t).PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := contract.UpdateSigningKey(ctx, "alice", signingKey); err == nil {
+		t.Error("UpdateSigningKey replaced the key of a user bound to no client")
+	}
+
+	if err := contract.ClaimUser(ctx, "alice"); err != nil {
+		t.Fatalf("ClaimUser failed: %v", err)
+	}
+	if err := contract.ClaimUser(other, "alice"); err == nil {
+		t.Error("ClaimUser bound a claimed user to another client")
+	}
+	if err := contract.UpdateSigningKey(other, "alice", signingKey); err == nil {
+		t.Error("UpdateSigningKey let another client replace the key")
+	}
+	if err := contract.UpdateSigningKey(ctx, "alice", signingKey); err != nil {
+		t.Errorf("UpdateSigningKey failed after the claim: %v", err)
+	}
+
+	user, err := contract.ReadUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ClientID != "PersonaMSP/alice-client" || user.SigningKey != signingKey {
+		t.Errorf("ReadUser returned the client %s and the key %s, expected PersonaMSP/alice-client and %s",
+			user.ClientID, user.SigningKey, signingKey)
+	}
+}
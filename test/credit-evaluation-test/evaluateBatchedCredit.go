@@ -0,0 +1,142 @@
+package main
+
+import (
+	"credit-evaluation/application-gateway/encryption"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func processCSVBatched(filename string, helper *encryption.CKKSHelper) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	records = records[1:]
+
+	creditScores := make([]float64, len(records))
+	dtis := make([]float64, len(records))
+	for i, record := range records {
+		creditScore, err := strconv.Atoi(record[0])
+		if err != nil {
+			return err
+		}
+
+		dti, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return err
+		}
+
+		creditScores[i] = float64(creditScore)
+		dtis[i] = dti
+	}
+
+	// Per-record evaluation: one ciphertext and one circuit per applicant
+	start := time.Now()
+	perRecordScores := make([]float64, len(records))
+	for i := range records {
+		resultEnc, err := helper.CreditEvaluation(nil, nil, helper.EncryptPu(creditScores[i]), helper.EncryptPu(dtis[i]))
+		if err != nil {
+			return err
+		}
+		scores, err := helper.DecryptShared(resultEnc, 1, disclosureFlooding)
+		if err != nil {
+			return err
+		}
+		perRecordScores[i] = scores[0]
+	}
+	perRecordElapsed := time.Since(start)
+
+	// Batched evaluation: every slot of a ciphertext holds a different applicant
+	start = time.Now()
+	creditScoresEnc, err := helper.EncryptBatchPu(creditScores)
+	if err != nil {
+		return err
+	}
+	dtisEnc, err := helper.EncryptBatchPu(dtis)
+	if err != nil {
+		return err
+	}
+	resultsEnc, err := helper.CreditEvaluationBatch(nil, nil, creditScoresEnc, dtisEnc)
+	if err != nil {
+		return err
+	}
+	batchedScores, err := helper.DecryptSharedBatch(resultsEnc, len(records), disclosureFlooding)
+	if err != nil {
+		return err
+	}
+	batchedElapsed := time.Since(start)
+
+	// Turn the batched scores into probabilities without decrypting them
+	probabilitiesEnc := make([]*rlwe.Ciphertext, len(resultsEnc))
+	for i, resultEnc := range resultsEnc {
+		if probabilitiesEnc[i], err = helper.Sigmoid(resultEnc, encryption.DefaultSigmoidPolicy()); err != nil {
+			return err
+		}
+	}
+	batchedResults, err := helper.DecryptSharedBatch(probabilitiesEnc, len(records), disclosureFlooding)
+	if err != nil {
+		return err
+	}
+
+	outputFile, err := os.Create("./test/credit-evaluation-test/credit_evaluation_batched_test_data.csv")
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	writer := csv.NewWriter(outputFile)
+	defer writer.Flush()
+
+	headers := []string{"Credit Score", "DTI",
+		"Per-record Evaluation Score",
+		"Batched Evaluation Score",
+		"Batched Evaluation Result"}
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		newRecord := []string{record[0], record[1],
+			strconv.FormatFloat(perRecordScores[i], 'f', 2, 64),
+			strconv.FormatFloat(batchedScores[i], 'f', 2, 64),
+			strconv.FormatFloat(batchedResults[i], 'f', 2, 64),
+		}
+		if err := writer.Write(newRecord); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Per-record: %d records in %v (%.2f records/s)\n",
+		len(records), perRecordElapsed, float64(len(records))/perRecordElapsed.Seconds())
+	fmt.Printf("Batched: %d records in %d ciphertexts in %v (%.2f records/s)\n",
+		len(records), len(resultsEnc), batchedElapsed, float64(len(records))/batchedElapsed.Seconds())
+	fmt.Printf("Speedup: %.2fx\n", perRecordElapsed.Seconds()/batchedElapsed.Seconds())
+
+	return nil
+}
+
+func mainbtch() {
+	// The score and the sigmoid together need more levels than the default parameter set provides
+	helper, err := encryption.NewCKKSHelperWithOptions(encryption.HelperOptions{ParameterSet: encryption.PN15QP880})
+	if err != nil {
+		panic(err)
+	}
+	filename := "./test/credit-evaluation-test/credit_evaluation_test_data.csv"
+	if err := processCSVBatched(filename, helper); err != nil {
+		panic(err)
+	}
+
+	println("Results saved to credit_evaluation_batched_test_data.csv")
+}
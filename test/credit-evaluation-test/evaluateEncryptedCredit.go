@@ -1,7 +1,6 @@
 package main
 
 import (
-	credit_evaluation "credit-evaluation/application-gateway/credit-evaluation"
 	"credit-evaluation/application-gateway/encryption"
 	"encoding/csv"
 	"fmt"
@@ -10,6 +9,10 @@ import (
 	"time"
 )
 
+// disclosureFlooding floods the decrypted results written out, within the two decimals they are reported with
+// PN15QP880 leaves enough room below that precision for the default flooding security.
+var disclosureFlooding = encryption.NoiseFlooding{Precision: 0.05}
+
 func processCSV2(filename string, helper *encryption.CKKSHelper) error {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -57,13 +60,28 @@ func processCSV2(filename string, helper *encryption.CKKSHelper) error {
 
 		start := time.Now()
 		// Perform homomorphic credit evaluation
-		resultEnc, _ := helper.CreditEvaluation(helper, nil, nil, creditScoreEnc, dtiEnc)
+		scoreEnc, err := helper.CreditEvaluation(nil, nil, creditScoreEnc, dtiEnc)
+		if err != nil {
+			return err
+		}
 
-		// Decrypt the result
-		score := helper.Decrypt(resultEnc)
-		elapsed := time.Since(start).Nanoseconds()
+		// Turn the score into a probability without decrypting it
+		resultEnc, err := helper.Sigmoid(scoreEnc, encryption.DefaultSigmoidPolicy())
+		if err != nil {
+			return err
+		}
 
-		result := credit_evaluation.Sigmoid(score)
+		// Decrypt the results, flooded since they leave the key owner
+		scores, err := helper.DecryptShared(scoreEnc, 1, disclosureFlooding)
+		if err != nil {
+			return err
+		}
+		results, err := helper.DecryptShared(resultEnc, 1, disclosureFlooding)
+		if err != nil {
+			return err
+		}
+		score, result := scores[0], results[0]
+		elapsed := time.Since(start).Nanoseconds()
 
 		newRecord := append(record,
 			strconv.FormatFloat(score, 'f', 2, 64),
@@ -75,14 +93,18 @@ func processCSV2(filename string, helper *encryption.CKKSHelper) error {
 			return err
 		}
 
-		fmt.Printf("Processed record %d: Credit Score = %d, DTI = %.2f, Result = %s\n", i+1, creditScore, dti, result)
+		fmt.Printf("Processed record %d: Credit Score = %d, DTI = %.2f, Result = %.2f\n", i+1, creditScore, dti, result)
 	}
 
 	return nil
 }
 
 func mainfds() {
-	helper := encryption.NewCKKSHelper()
+	// The score and the sigmoid together need more levels than the default parameter set provides
+	helper, err := encryption.NewCKKSHelperWithOptions(encryption.HelperOptions{ParameterSet: encryption.PN15QP880})
+	if err != nil {
+		panic(err)
+	}
 	filename := "./test/credit-evaluation-test/credit_evaluation_test_data.csv"
 	if err := processCSV2(filename, helper); err != nil {
 		panic(err)